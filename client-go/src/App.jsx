@@ -7,53 +7,47 @@ function App() {
   const [users, setUsers] = useState([]);
   const [currentUser, setCurrentUser] = useState(null);
   const [username, setUsername] = useState('');
+  const [password, setPassword] = useState('');
   const [linkToken, setLinkToken] = useState(null);
   const [linkedItems, setLinkedItems] = useState([]);
   const [loading, setLoading] = useState(false);
   const [error, setError] = useState(null);
   const [mode, setMode] = useState('normal'); // 'normal' or 'update'
   const [selectedItemId, setSelectedItemId] = useState(null);
+  const [accountSelection, setAccountSelection] = useState(false);
   const [transactions, setTransactions] = useState([]);
+  const [transactionsTotal, setTransactionsTotal] = useState(0);
+  const [transactionsCursor, setTransactionsCursor] = useState(null);
   const [showTransactions, setShowTransactions] = useState(false);
+  const [searchQuery, setSearchQuery] = useState('');
+  const [searchResults, setSearchResults] = useState(null);
 
-  // Create a new user or load existing user by ID
+  // Log in, signing the user up first if the username doesn't exist yet
   const handleCreateUser = async (e) => {
     e.preventDefault();
-    if (!username.trim()) return;
+    if (!username.trim() || !password) return;
 
     setLoading(true);
     setError(null);
     try {
-      const isNumeric = /^\d+$/.test(username.trim());
       let user;
+      try {
+        user = await api.login(username.trim(), password);
+      } catch (err) {
+        if (err.response?.status !== 401) throw err;
+        await api.createUser(username.trim(), password);
+        user = await api.login(username.trim(), password);
+      }
 
-      if (isNumeric) {
-        // Try to load user by ID
-        try {
-          user = await api.getUser(parseInt(username));
-          // Check if user already in list, if not add them
-          const userExists = users.some(u => u.id === user.id);
-          if (!userExists) {
-            setUsers([...users, user]);
-          }
-          setCurrentUser(user);
-          setUsername('');
-        } catch (err) {
-          // If not found by ID, create as new user with this username
-          user = await api.createUser(username);
-          setUsers([...users, user]);
-          setCurrentUser(user);
-          setUsername('');
-        }
-      } else {
-        // Create user with given username
-        user = await api.createUser(username);
+      // Check if user already in list, if not add them
+      if (!users.some(u => u.id === user.id)) {
         setUsers([...users, user]);
-        setCurrentUser(user);
-        setUsername('');
       }
+      setCurrentUser(user);
+      setUsername('');
+      setPassword('');
     } catch (err) {
-      setError(`Failed to create user: ${err.message}`);
+      setError(`Failed to log in: ${err.response?.data?.error || err.message}`);
     } finally {
       setLoading(false);
     }
@@ -70,7 +64,7 @@ function App() {
     setError(null);
     try {
       const itemId = mode === 'update' ? selectedItemId : null;
-      const token = await api.getLinkToken(currentUser.id, itemId);
+      const token = await api.getLinkToken(itemId, mode === 'update' && accountSelection);
       setLinkToken(token);
     } catch (err) {
       setError(`Failed to get link token: ${err.message}`);
@@ -81,18 +75,40 @@ function App() {
 
   // Handle successful Plaid Link completion
   const handlePlaidSuccess = async (publicToken, metadata) => {
+    api.logLinkEvent('success', metadata);
     setLoading(true);
     setError(null);
     try {
-      const result = await api.exchangePublicToken(publicToken, currentUser.id);
+      if (mode === 'update') {
+        const result = await api.completeRelink(selectedItemId);
+        setLinkedItems(linkedItems.map((item) =>
+          item.id === selectedItemId ? { ...item, accounts: result.accounts || [] } : item
+        ));
+        setLinkToken(null);
+        setMode('normal');
+        setSelectedItemId(null);
+        return;
+      }
+
+      let result;
+      try {
+        result = await api.exchangePublicToken(publicToken, metadata);
+      } catch (err) {
+        const duplicate = err.response?.status === 409 && err.response.data?.code === 'DUPLICATE_ITEM';
+        const institution = metadata.institution?.name || 'this institution';
+        if (!duplicate || !window.confirm(`${institution} is already linked. Replace the existing connection?`)) {
+          throw err;
+        }
+        result = await api.exchangePublicToken(publicToken, metadata, err.response.data.item.id);
+      }
 
-      // Add to linked items
+      // Add to linked items, dropping the one it replaced
       const newItem = {
         id: result.item_id,
         institution: metadata.institution?.name || 'Unknown Institution',
         accounts: result.accounts || [],
       };
-      setLinkedItems([...linkedItems, newItem]);
+      setLinkedItems([...linkedItems.filter((item) => item.id !== result.replaced_item_id), newItem]);
 
       // Reset
       setLinkToken(null);
@@ -101,13 +117,14 @@ function App() {
 
       setError(null); // Clear any previous errors
     } catch (err) {
-      setError(`Failed to exchange token: ${err.message}`);
+      setError(`Failed to exchange token: ${err.response?.data?.error || err.message}`);
     } finally {
       setLoading(false);
     }
   };
 
-  const handlePlaidExit = (err) => {
+  const handlePlaidExit = (err, metadata) => {
+    api.logLinkEvent('exit', metadata, err);
     if (err) {
       setError(`Plaid Link closed with error: ${err.message}`);
     } else {
@@ -115,8 +132,15 @@ function App() {
     }
   };
 
-  // Get transactions for current user
-  const handleGetTransactions = async () => {
+  const handlePlaidEvent = (eventName, metadata) => {
+    api.logLinkEvent(eventName, metadata, {
+      error_type: metadata.error_type,
+      error_code: metadata.error_code,
+    });
+  };
+
+  // Get transactions for current user, the next page when more is true
+  const handleGetTransactions = async (more = false) => {
     if (!currentUser) {
       setError('Please create or select a user first');
       return;
@@ -125,8 +149,10 @@ function App() {
     setLoading(true);
     setError(null);
     try {
-      const data = await api.getUserTransactions(currentUser.id);
-      setTransactions(data.transactions || []);
+      const data = await api.getUserTransactions(currentUser.id, more ? transactionsCursor : null);
+      setTransactions([...(more ? transactions : []), ...(data.transactions || [])]);
+      setTransactionsTotal(data.total_count || 0);
+      setTransactionsCursor(data.next_cursor);
       setShowTransactions(true);
     } catch (err) {
       setError(`Failed to get transactions: ${err.message}`);
@@ -135,6 +161,26 @@ function App() {
     }
   };
 
+  // Search transactions by name, merchant and notes
+  const handleSearchTransactions = async (e) => {
+    e.preventDefault();
+    if (!searchQuery.trim()) return;
+
+    setLoading(true);
+    setError(null);
+    try {
+      const data = await api.searchTransactions(currentUser.id, searchQuery.trim());
+      setSearchResults(data.results || []);
+    } catch (err) {
+      setError(`Failed to search transactions: ${err.response?.data?.error || err.message}`);
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  // Render a search snippet, the server escapes the text and marks matches with <mark></mark>
+  const renderSnippet = (snippet) => <span dangerouslySetInnerHTML={{ __html: snippet }} />;
+
   return (
     <div className="app">
       <header className="header">
@@ -147,7 +193,7 @@ function App() {
 
         {/* User Creation Section */}
         <section className="card">
-          <h2>1. Create New User (or select from existing)</h2>
+          <h2>1. Log In (new usernames are signed up)</h2>
           <form onSubmit={handleCreateUser} className="form">
             <input
               type="text"
@@ -156,20 +202,26 @@ function App() {
               placeholder="Enter username"
               disabled={loading}
             />
+            <input
+              type="password"
+              value={password}
+              onChange={(e) => setPassword(e.target.value)}
+              placeholder="Password (8+ characters)"
+              disabled={loading}
+            />
             <button type="submit" disabled={loading}>
-              {loading ? 'Creating...' : 'Create User'}
+              {loading ? 'Logging in...' : 'Log In'}
             </button>
           </form>
 
           {users.length > 0 && (
             <div className="user-list">
-              <h3>Users Created:</h3>
+              <h3>Users Logged In (log in again to switch):</h3>
               <ul>
                 {users.map((user) => (
                   <li
                     key={user.id}
                     className={currentUser?.id === user.id ? 'active' : ''}
-                    onClick={() => setCurrentUser(user)}
                   >
                     {user.username} (ID: {user.id})
                     {currentUser?.id === user.id && ' ✓'}
@@ -233,6 +285,14 @@ function App() {
                     ))}
                   </select>
                 </label>
+                <label>
+                  <input
+                    type="checkbox"
+                    checked={accountSelection}
+                    onChange={(e) => setAccountSelection(e.target.checked)}
+                  />
+                  Share newly opened accounts
+                </label>
               </div>
             )}
 
@@ -261,6 +321,7 @@ function App() {
               token={linkToken}
               onSuccess={handlePlaidSuccess}
               onExit={handlePlaidExit}
+              onEvent={handlePlaidEvent}
             >
               {({ open, ready }) => (
                 <button
@@ -310,13 +371,39 @@ function App() {
         {currentUser && (
           <section className="card">
             <h2>5. View Transactions</h2>
-            <button onClick={handleGetTransactions} disabled={loading}>
-              {loading ? 'Loading...' : 'Get All Transactions'}
+            <button onClick={() => handleGetTransactions()} disabled={loading}>
+              {loading ? 'Loading...' : 'Get Transactions'}
             </button>
 
+            <form onSubmit={handleSearchTransactions} className="form">
+              <input
+                type="text"
+                value={searchQuery}
+                onChange={(e) => setSearchQuery(e.target.value)}
+                placeholder="Search by name, merchant or notes"
+                disabled={loading}
+              />
+              <button type="submit" disabled={loading}>Search</button>
+            </form>
+
+            {searchResults && (
+              <div className="transactions-list">
+                <h3>Search Results ({searchResults.length})</h3>
+                <ul>
+                  {searchResults.map((result) => (
+                    <li key={result.transaction.id}>
+                      {result.transaction.date}{' '}
+                      {renderSnippet(result.snippet)}{' '}
+                      (${Math.abs(result.transaction.amount).toFixed(2)})
+                    </li>
+                  ))}
+                </ul>
+              </div>
+            )}
+
             {showTransactions && transactions.length > 0 && (
               <div className="transactions-list">
-                <h3>Transactions ({transactions.length})</h3>
+                <h3>Transactions ({transactions.length} of {transactionsTotal})</h3>
                 <div className="transactions-table">
                   <table>
                     <thead>
@@ -332,7 +419,7 @@ function App() {
                     <tbody>
                       {transactions.map((tx) => (
                         <tr key={tx.id}>
-                          <td>{new Date(tx.date).toLocaleDateString()}</td>
+                          <td>{tx.date}</td>
                           <td>{tx.name}</td>
                           <td>${Math.abs(tx.amount).toFixed(2)}</td>
                           <td>{tx.type}</td>
@@ -343,6 +430,11 @@ function App() {
                     </tbody>
                   </table>
                 </div>
+                {transactionsCursor && (
+                  <button onClick={() => handleGetTransactions(true)} disabled={loading}>
+                    {loading ? 'Loading...' : 'Load More'}
+                  </button>
+                )}
               </div>
             )}
 
@@ -7,37 +7,76 @@ const api = axios.create({
   headers: {
     'Content-Type': 'application/json',
   },
+  // send the session cookie set by POST /api/sessions
+  withCredentials: true,
 });
 
 // User endpoints
-export const createUser = async (username) => {
-  const response = await api.post('/api/users', { username });
+export const createUser = async (username, password) => {
+  const response = await api.post('/api/users', { username, password });
   return response.data;
 };
 
+// Session endpoints
+export const login = async (username, password) => {
+  const response = await api.post('/api/sessions', { username, password });
+  return response.data.user;
+};
+
+export const logout = async () => {
+  await api.delete('/api/sessions');
+};
+
 export const getUser = async (userId) => {
   const response = await api.get(`/api/users/${userId}`);
   return response.data;
 };
 
 // Link token endpoint
-export const getLinkToken = async (userId, itemId = null) => {
+// accountSelection opens update mode with account selection, for items flagged new_accounts_available
+export const getLinkToken = async (itemId = null, accountSelection = false) => {
   const response = await api.post('/api/link-token', {
-    userId,
     itemId,
+    accountSelection,
   });
   return response.data.link_token;
 };
 
+// Link event logging, failures here never interrupt the Link flow
+export const logLinkEvent = async (type, metadata = {}, error = null) => {
+  try {
+    await api.post('/api/link-events', {
+      type,
+      link_session_id: metadata.link_session_id,
+      request_id: metadata.request_id,
+      error_type: error?.error_type,
+      error_code: error?.error_code,
+      status: metadata.status,
+    });
+  } catch (err) {
+    console.warn('Failed to log link event', err);
+  }
+};
+
 // Item endpoints (token exchange)
-export const exchangePublicToken = async (publicToken, userId) => {
+// metadata is the Link onSuccess metadata, the server uses it to refuse linking an institution twice
+// (409 with code DUPLICATE_ITEM) unless replaceItemId names the item to replace
+export const exchangePublicToken = async (publicToken, metadata = {}, replaceItemId = null) => {
   const response = await api.post('/api/items', {
     publicToken,
-    userId,
+    institutionId: metadata.institution?.institution_id,
+    accounts: (metadata.accounts || []).map((account) => ({ mask: account.mask })),
+    replaceItemId,
   });
   return response.data;
 };
 
+// Finish Link update mode for an item, the public token from update mode is never exchanged
+export const completeRelink = async (itemId) => {
+  const response = await api.post(`/api/items/${itemId}/relink-complete`);
+  return response.data;
+};
+
 // Get user's items
 export const getUserItems = async (userId) => {
   const response = await api.get(`/api/users/${userId}/items`);
@@ -50,9 +89,16 @@ export const getItemAccounts = async (itemId) => {
   return response.data;
 };
 
-// Get user's transactions
-export const getUserTransactions = async (userId) => {
-  const response = await api.get(`/api/transactions/${userId}`);
+// Get a page of the user's transactions, pass the previous page's next_cursor to get the next one
+export const getUserTransactions = async (userId, cursor = null) => {
+  const params = cursor ? { cursor } : {};
+  const response = await api.get(`/api/transactions/${userId}`, { params });
+  return response.data;
+};
+
+// Full-text search the user's transactions
+export const searchTransactions = async (userId, q) => {
+  const response = await api.get(`/api/users/${userId}/transactions/search`, { params: { q } });
   return response.data;
 };
 
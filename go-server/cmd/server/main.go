@@ -4,11 +4,21 @@ import (
 	"compound/go-server/internal/db"
 	"compound/go-server/internal/handlers"
 	plaidpkg "compound/go-server/internal/plaid"
+	"compound/go-server/internal/redact"
+	"compound/go-server/internal/secrets"
+	"compound/go-server/internal/services"
 	"context"
+	"crypto/ecdsa"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -22,8 +32,30 @@ var (
 	PLAID_PRODUCTS      = ""
 	PLAID_COUNTRY_CODES = ""
 	PLAID_REDIRECT_URI  = ""
+	PLAID_WEBHOOK_URL   = ""
 	DATABASE_URL        = "" // remove later
 	APP_PORT            = ""
+	DB_MAX_CONNS        = 0
+	DB_MIN_CONNS        = 0
+	DB_QUERY_TIMEOUT    = time.Duration(0)
+	PLAID_FAKE_SEED     = ""
+	DB_AUTO_MIGRATE     = true
+	ENCRYPTION_KEYS     = ""
+	ENCRYPTION_KEY_FILE = ""
+	ENCRYPTION_KEY_ID   = ""
+	SESSION_TTL         = time.Duration(0)
+	SESSION_SECURE      = true
+	SYNC_SCHEDULER      = true
+	SYNC_INTERVAL       = time.Duration(0)
+	SYNC_CONCURRENCY    = 0
+	SYNC_JITTER         = time.Duration(0)
+	NET_WORTH_CURRENCY  = ""
+	FX_RATES            = ""
+)
+
+var (
+	aggregator  plaidpkg.Aggregator
+	webhookKeys plaidpkg.KeyFetcher
 )
 
 func init() {
@@ -46,8 +78,27 @@ func init() {
 	PLAID_PRODUCTS = os.Getenv("PLAID_PRODUCTS")
 	PLAID_COUNTRY_CODES = os.Getenv("PLAID_COUNTRY_CODES")
 	PLAID_REDIRECT_URI = os.Getenv("PLAID_REDIRECT_URI")
+	PLAID_WEBHOOK_URL = os.Getenv("PLAID_WEBHOOK_URL")
 	DATABASE_URL = os.Getenv("DATABASE_URL")
 	APP_PORT = os.Getenv("APP_PORT")
+	DB_MAX_CONNS = envInt("DB_MAX_CONNS")
+	DB_MIN_CONNS = envInt("DB_MIN_CONNS")
+	DB_QUERY_TIMEOUT = envDuration("DB_QUERY_TIMEOUT")
+	PLAID_FAKE_SEED = os.Getenv("PLAID_FAKE_SEED")
+	DB_AUTO_MIGRATE = os.Getenv("DB_AUTO_MIGRATE") != "false"
+	ENCRYPTION_KEYS = os.Getenv("ENCRYPTION_KEYS")
+	ENCRYPTION_KEY_FILE = os.Getenv("ENCRYPTION_KEY_FILE")
+	ENCRYPTION_KEY_ID = os.Getenv("ENCRYPTION_KEY_ID")
+	SESSION_TTL = envDuration("SESSION_TTL")
+	// only set SESSION_COOKIE_SECURE=false for local development over http
+	SESSION_SECURE = os.Getenv("SESSION_COOKIE_SECURE") != "false"
+	SYNC_SCHEDULER = os.Getenv("SYNC_SCHEDULER") != "false"
+	SYNC_INTERVAL = envDuration("SYNC_INTERVAL")
+	SYNC_CONCURRENCY = envInt("SYNC_CONCURRENCY")
+	SYNC_JITTER = envDuration("SYNC_JITTER")
+	// net worth is reported in NET_WORTH_CURRENCY, FX_RATES converts other currencies to it, e.g. "EUR=1.08,GBP=1.27"
+	NET_WORTH_CURRENCY = os.Getenv("NET_WORTH_CURRENCY")
+	FX_RATES = os.Getenv("FX_RATES")
 
 	// set defaults if env not present
 	if PLAID_PRODUCTS == "" {
@@ -62,6 +113,23 @@ func init() {
 	if APP_PORT == "" {
 		APP_PORT = "8000"
 	}
+	if SESSION_TTL == 0 {
+		SESSION_TTL = 7 * 24 * time.Hour
+	}
+	if SYNC_JITTER == 0 {
+		SYNC_JITTER = 5 * time.Second
+	}
+}
+
+// initAggregator sets up the Plaid client (or the fake) used by the API server
+// Every real Plaid API call is recorded through recorder
+func initAggregator(recorder plaidpkg.EventRecorder) {
+	// PLAID_ENV=fake runs against an in-memory aggregator, no Plaid credentials needed
+	if PLAID_ENV == "fake" {
+		initFakeAggregator()
+		return
+	}
+
 	if PLAID_CLIENT_ID == "" {
 		log.Fatal("PLAID_CLIENT_ID is not set. Make sure to fill out the .env file")
 	}
@@ -70,21 +138,129 @@ func init() {
 	}
 
 	// initialize Plaid client
-	err = plaidpkg.Initialize(PLAID_CLIENT_ID, PLAID_SECRET_ID, PLAID_ENV)
+	client, err := plaidpkg.NewClient(PLAID_CLIENT_ID, PLAID_SECRET_ID, PLAID_ENV)
 	if err != nil {
 		log.Fatal("Failed to initialize Plaid client:", err)
 	}
+	client.SetEventRecorder(recorder)
+	aggregator = client
+	webhookKeys = plaidpkg.NewAPIKeyFetcher(client)
+}
+
+// initFakeAggregator sets up the in-memory aggregator, seeded from PLAID_FAKE_SEED
+// (a saved /transactions/sync response like transaction_sync_sample.json) if set
+func initFakeAggregator() {
+	fake := plaidpkg.NewFake()
+	if PLAID_FAKE_SEED != "" {
+		if err := fake.SeedFromSyncSampleFile(PLAID_FAKE_SEED); err != nil {
+			log.Fatal("Failed to seed fake Plaid client:", err)
+		}
+	}
+	aggregator = fake
+
+	// nothing can sign webhooks in fake mode, so every webhook is rejected
+	webhookKeys = plaidpkg.KeyFetcherFunc(func(ctx context.Context, keyID string) (*ecdsa.PublicKey, error) {
+		return nil, fmt.Errorf("webhooks are not supported with PLAID_ENV=fake")
+	})
+}
+
+// loadKeyring loads the access token encryption keys from ENCRYPTION_KEYS or ENCRYPTION_KEY_FILE
+// Keys are "id:base64key" pairs, generate one with `openssl rand -base64 32`
+// Returns nil if neither is set
+func loadKeyring() *secrets.Keyring {
+	var keyring *secrets.Keyring
+	var err error
+
+	switch {
+	case ENCRYPTION_KEY_FILE != "":
+		keyring, err = secrets.LoadKeyringFile(ENCRYPTION_KEY_FILE, ENCRYPTION_KEY_ID)
+	case ENCRYPTION_KEYS != "":
+		keyring, err = secrets.ParseKeyring(ENCRYPTION_KEYS, ENCRYPTION_KEY_ID)
+	default:
+		return nil
+	}
+
+	if err != nil {
+		log.Fatal("Failed to load encryption keys:", err)
+	}
+	return keyring
 }
 
 func main() {
-	fmt.Printf("Plaid client initialized successfully, using environment: %s \n", PLAID_ENV)
+	keyring := loadKeyring()
 
-	// initialize the DB
-	err := db.Connect(DATABASE_URL)
+	// initialize the DB connection pool
+	store, err := db.NewStore(context.Background(), db.Config{
+		DatabaseURL:  DATABASE_URL,
+		MaxConns:     int32(DB_MAX_CONNS),
+		MinConns:     int32(DB_MIN_CONNS),
+		QueryTimeout: DB_QUERY_TIMEOUT,
+		Keyring:      keyring,
+	})
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
-	defer db.CloseConnection(context.Background())
+	defer store.Close()
+
+	// `server migrate ...` manages the schema and exits without starting the API
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrateCommand(store, os.Args[2:]); err != nil {
+			store.Close()
+			log.Fatal("Migration failed:", err)
+		}
+		return
+	}
+
+	// migrate before rotate-keys too, rotating needs the key ID columns of a migrated schema
+	if DB_AUTO_MIGRATE {
+		applied, err := store.MigrateUp(context.Background())
+		if err != nil {
+			store.Close()
+			log.Fatal("Failed to migrate database:", err)
+		}
+		if len(applied) > 0 {
+			fmt.Printf("Applied database migrations: %v\n", applied)
+		}
+	}
+
+	// `server rotate-keys` re-encrypts every access token with the current key and exits
+	// With DB_AUTO_MIGRATE=false it refuses to run until `server migrate` has been run
+	if len(os.Args) > 1 && os.Args[1] == "rotate-keys" {
+		if err := runRotateKeysCommand(store); err != nil {
+			store.Close()
+			log.Fatal("Key rotation failed:", err)
+		}
+		return
+	}
+
+	if keyring == nil {
+		store.Close()
+		log.Fatal("ENCRYPTION_KEYS or ENCRYPTION_KEY_FILE must be set to store Plaid access tokens. Make sure to fill out the .env file")
+	}
+
+	// tokens linked before encryption was added stay in plaintext until they're rotated
+	plaintextTokens, err := store.CountPlaintextAccessTokens(context.Background())
+	if err != nil {
+		log.Printf("failed to count plaintext access tokens: %v", err)
+	} else if plaintextTokens > 0 {
+		log.Printf("WARNING: %d Plaid access tokens are stored in plaintext, run `server rotate-keys` to encrypt them", plaintextTokens)
+	}
+
+	initAggregator(store)
+	fmt.Printf("Plaid client initialized successfully, using environment: %s \n", PLAID_ENV)
+
+	syncer := services.NewTransactionSyncer(store, aggregator)
+	h := handlers.New(store, aggregator, syncer)
+
+	fxRates, err := services.ParseExchangeRates(FX_RATES)
+	if err != nil {
+		store.Close()
+		log.Fatal("Invalid FX_RATES:", err)
+	}
+	netWorth := services.NewNetWorthCalculator(store, services.NetWorthConfig{
+		Currency: NET_WORTH_CURRENCY,
+		Rates:    fxRates,
+	})
 
 	// // test db connection with username query
 	// user, err := db.GetUserByUsername(context.Background(), "browak")
@@ -93,13 +269,20 @@ func main() {
 	// }
 	// fmt.Printf("User found! ID: %d, Username: %s, Created at: %s\n", user.ID, user.Username, user.CreatedAt)
 
+	// redact Plaid tokens from every log line, ours and Gin's
+	log.SetOutput(redact.NewWriter(os.Stderr))
+	gin.DefaultWriter = redact.NewWriter(os.Stdout)
+	gin.DefaultErrorWriter = redact.NewWriter(os.Stderr)
+
 	// create a Gin router with default middleware (logger and recovery)
 	// test
 	router := gin.Default()
 	router.Use(cors.New(cors.Config{
 		AllowOrigins: []string{"http://localhost:3001", "http://localhost:5173"},
-		AllowMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders: []string{"Content-Type"},
+		AllowMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders: []string{"Content-Type", "Authorization"},
+		// the session cookie is sent cross-origin from the client dev servers
+		AllowCredentials: true,
 	}))
 
 	// -------------------------------------------------
@@ -109,21 +292,70 @@ func main() {
 	// define a simple test endpoint - pointing to a func
 	router.GET("/api/ping", returnPong)
 
+	// Signup and login, the only user endpoints that don't need a session
+	router.POST("/api/users", h.CreateUser)
+	router.POST("/api/sessions", h.MakeCreateSessionHandler(SESSION_TTL, SESSION_SECURE))
+
+	// Webhook endpoints, authenticated by Plaid's signature instead of a session
+	webhookVerifier := plaidpkg.NewWebhookVerifier(webhookKeys)
+	router.POST("/api/webhooks/plaid", h.MakePlaidWebhookHandler(webhookVerifier))
+
+	// everything below requires a logged in user
+	authed := router.Group("/api", h.RequireSession)
+
+	// routes addressed by a user, item, account or asset ID only reach the current user's own resources,
+	// anything else is a 404
+	self := h.RequireSelf("id")
+	ownsItem := h.RequireItemOwner("id")
+	ownsAccount := h.RequireAccountOwner("id")
+	ownsAsset := h.RequireAssetOwner("id")
+
+	// Session endpoints
+	authed.GET("/sessions/current", h.GetCurrentSession)
+	authed.DELETE("/sessions", h.MakeDeleteSessionHandler(SESSION_SECURE))
+
 	// User endpoints
-	router.POST("/api/users", handlers.CreateUser)
-	router.GET("/api/users/:id", handlers.GetUser)
-	router.GET("/api/users/username/:username", handlers.GetUserByUsername)
+	authed.GET("/users/:id", self, h.GetUser)
+	authed.GET("/users/:id/net-worth", self, h.MakeNetWorthHandler(netWorth))
+	authed.GET("/users/:id/transactions/search", self, h.SearchUserTransactions)
+	authed.GET("/users/username/:username", h.GetUserByUsername)
 
-	// Link Token endpoint
-	router.POST("/api/link-token", handlers.MakeLinkTokenHandler(PLAID_PRODUCTS, PLAID_COUNTRY_CODES, PLAID_REDIRECT_URI))
+	// Link event logging, for Plaid Link onSuccess/onExit/onEvent callbacks
+	authed.POST("/link-events", h.CreateLinkEvent)
+
+	// Link Token endpoint (update mode checks item ownership itself, the item ID is in the body)
+	authed.POST("/link-token", h.MakeLinkTokenHandler(PLAID_PRODUCTS, PLAID_COUNTRY_CODES, PLAID_REDIRECT_URI, PLAID_WEBHOOK_URL))
 
 	// Item endpoints
-	router.POST("/api/items", handlers.ExchangeToken)
-	router.GET("/api/items/:id/accounts", handlers.GetItemAccounts)
+	authed.POST("/items", h.ExchangeToken)
+	authed.GET("/items", h.GetItems)
+	authed.DELETE("/items/:id", ownsItem, h.DeleteItem)
+	authed.GET("/items/:id/accounts", ownsItem, h.GetItemAccounts)
+	authed.GET("/items/:id/sync-runs", ownsItem, h.GetItemSyncRuns)
+	authed.POST("/items/:id/relink-complete", ownsItem, h.CompleteRelink)
+	authed.POST("/items/:id/balances/refresh", ownsItem, h.RefreshItemBalances)
+
+	// Account endpoints
+	authed.GET("/accounts/:id/transactions", ownsAccount, h.GetAccountTransactions)
+	authed.GET("/accounts/:id/balance-history", ownsAccount, h.GetAccountBalanceHistory)
+
+	// Manual asset endpoints
+	authed.POST("/assets", h.CreateAsset)
+	authed.GET("/assets", h.GetAssets)
+	authed.GET("/assets/:id", ownsAsset, h.GetAsset)
+	authed.PATCH("/assets/:id", ownsAsset, h.UpdateAsset)
+	authed.DELETE("/assets/:id", ownsAsset, h.DeleteAsset)
+	authed.GET("/assets/:id/values", ownsAsset, h.GetAssetValues)
 
 	// Transaction endpoints
-	router.POST("/api/items/:itemID/sync-transactions", handlers.SyncTransactionsForItem)
-	router.GET("/api/transactions/:userID", handlers.GetUserTransactions)
+	authed.POST("/items/:id/sync-transactions", ownsItem, h.SyncTransactionsForItem)
+	authed.GET("/transactions/:userID", h.RequireSelf("userID"), h.GetUserTransactions)
+	authed.PATCH("/transactions/:id", h.RequireTransactionOwner("id"), h.UpdateTransaction)
+
+	// Admin endpoints, for debugging any user's items
+	admin := authed.Group("/admin", h.RequireAdmin)
+	admin.GET("/items/:id/plaid-api-failures", h.GetItemPlaidAPIFailures)
+	admin.GET("/link-events", h.GetLinkEvents)
 
 	// -------------------------------------------------
 	// end API endpoints
@@ -138,12 +370,72 @@ func main() {
 	// }
 	// fmt.Println(user)
 
+	// stop the scheduler and drain in-flight requests on Ctrl-C or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// background sync of every linked item, only one replica schedules at a time
+	var background sync.WaitGroup
+	if SYNC_SCHEDULER {
+		scheduler := services.NewSyncScheduler(store, syncer, services.SchedulerConfig{
+			Interval:    SYNC_INTERVAL,
+			Concurrency: SYNC_CONCURRENCY,
+			Jitter:      SYNC_JITTER,
+		})
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			scheduler.Run(ctx)
+		}()
+	}
+
 	// start server on port 8000 w/ error handling
-	err = router.Run(":" + APP_PORT)
+	server := &http.Server{Addr: ":" + APP_PORT, Handler: router}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("unable to start server: %v", err)
+			stop()
+		}
+	}()
+
+	<-ctx.Done()
+	fmt.Println("Shutting down...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("server shutdown: %v", err)
+	}
+	background.Wait()
+
+	// webhook and relink syncs hold pooled connections, store.Close would wait on them
+	syncer.Close()
+}
+
+// envInt reads an optional integer env var, exiting on a malformed value
+func envInt(key string) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(value)
 	if err != nil {
-		panic("unable to start server")
+		log.Fatalf("%s must be an integer: %v", key, err)
 	}
+	return n
+}
 
+// envDuration reads an optional duration env var (e.g. "5s"), exiting on a malformed value
+func envDuration(key string) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("%s must be a duration like 5s: %v", key, err)
+	}
+	return d
 }
 
 // Handler functions for testing
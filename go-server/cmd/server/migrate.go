@@ -0,0 +1,68 @@
+package main
+
+import (
+	"compound/go-server/internal/db"
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// runMigrateCommand handles `server migrate [up | down [steps] | status]`
+func runMigrateCommand(store *db.Store, args []string) error {
+	ctx := context.Background()
+
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "up":
+		applied, err := store.MigrateUp(ctx)
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("Database is up to date")
+		}
+		for _, version := range applied {
+			fmt.Printf("Applied migration %d\n", version)
+		}
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("down steps must be a positive integer")
+			}
+			steps = n
+		}
+
+		reverted, err := store.MigrateDown(ctx, steps)
+		if err != nil {
+			return err
+		}
+		for _, version := range reverted {
+			fmt.Printf("Reverted migration %d\n", version)
+		}
+
+	case "status":
+		statuses, err := store.MigrationStatuses(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			if status.AppliedAt != nil {
+				fmt.Printf("%04d_%s\tapplied %s\n", status.Version, status.Name, status.AppliedAt.Format("2006-01-02 15:04:05"))
+			} else {
+				fmt.Printf("%04d_%s\tpending\n", status.Version, status.Name)
+			}
+		}
+
+	default:
+		return fmt.Errorf("unknown migrate command %q (expected up, down or status)", command)
+	}
+
+	return nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"compound/go-server/internal/db"
+	"context"
+	"fmt"
+)
+
+// runRotateKeysCommand handles `server rotate-keys`
+// Add the new key to ENCRYPTION_KEYS (keeping the old ones), point ENCRYPTION_KEY_ID at it,
+// run this, then the old keys can be removed. The schema must be fully migrated first, older
+// schemas have nowhere to record which key encrypted a token.
+func runRotateKeysCommand(store *db.Store) error {
+	ctx := context.Background()
+
+	statuses, err := store.MigrationStatuses(ctx)
+	if err != nil {
+		return err
+	}
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			return fmt.Errorf("migration %04d_%s is pending, run `server migrate` first", status.Version, status.Name)
+		}
+	}
+
+	rotated, err := store.RotateAccessTokenKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Re-encrypted %d access tokens\n", rotated)
+	return nil
+}
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MinPasswordLength is the shortest password accepted at signup
+const MinPasswordLength = 8
+
+// MaxPasswordLength is the longest password bcrypt can hash without truncating it
+const MaxPasswordLength = 72
+
+// sessionTokenBytes is how much randomness goes into a session token
+const sessionTokenBytes = 32
+
+// ErrInvalidPassword is returned when a password is too short or too long
+var ErrInvalidPassword = fmt.Errorf("password must be between %d and %d bytes", MinPasswordLength, MaxPasswordLength)
+
+// dummyHash is compared against when a login names an unknown user, so a failed
+// login takes the same time whether or not the username exists
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not-a-real-password"), bcrypt.DefaultCost)
+
+// HashPassword hashes a password with bcrypt for storage in users_table.password_hash
+func HashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength || len(password) > MaxPasswordLength {
+		return "", ErrInvalidPassword
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	return string(hash), nil
+}
+
+// CheckPassword reports whether password matches hash
+// A nil hash (a user without a password) never matches, but still costs one bcrypt comparison
+func CheckPassword(hash *string, password string) bool {
+	if hash == nil {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return false
+	}
+
+	return bcrypt.CompareHashAndPassword([]byte(*hash), []byte(password)) == nil
+}
+
+// NewSessionToken generates a random session token
+// Returns the token to hand to the client and the hash to store
+func NewSessionToken() (string, string, error) {
+	b := make([]byte, sessionTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("failed to generate session token: %w", err)
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, HashSessionToken(token), nil
+}
+
+// HashSessionToken returns the value stored in sessions_table.token_hash for a token
+// Tokens carry 256 bits of randomness, so a plain SHA-256 is enough (no salt or stretching)
+func HashSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestHashPassword(t *testing.T) {
+	tests := []struct {
+		password string
+		valid    bool
+	}{
+		{strings.Repeat("a", MinPasswordLength-1), false},
+		{strings.Repeat("a", MinPasswordLength), true},
+		{strings.Repeat("a", MaxPasswordLength), true},
+		{strings.Repeat("a", MaxPasswordLength+1), false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		hash, err := HashPassword(tt.password)
+		if !tt.valid {
+			if !errors.Is(err, ErrInvalidPassword) {
+				t.Errorf("%d byte password: got %v, want ErrInvalidPassword", len(tt.password), err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%d byte password: %v", len(tt.password), err)
+		}
+		if hash == tt.password || !CheckPassword(&hash, tt.password) {
+			t.Errorf("%d byte password doesn't check against its hash", len(tt.password))
+		}
+	}
+}
+
+func TestCheckPassword(t *testing.T) {
+	hash, err := HashPassword("correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !CheckPassword(&hash, "correct horse battery staple") {
+		t.Error("the right password doesn't match")
+	}
+	if CheckPassword(&hash, "correct horse battery stapler") {
+		t.Error("a wrong password matches")
+	}
+	if CheckPassword(nil, "correct horse battery staple") {
+		t.Error("a user without a password can log in")
+	}
+	garbage := "not a bcrypt hash"
+	if CheckPassword(&garbage, garbage) {
+		t.Error("a malformed hash matches")
+	}
+}
+
+func TestNewSessionToken(t *testing.T) {
+	seen := map[string]bool{}
+	for range 100 {
+		token, hash, err := NewSessionToken()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen[token] {
+			t.Fatalf("token %s generated twice", token)
+		}
+		seen[token] = true
+
+		if hash != HashSessionToken(token) {
+			t.Fatalf("NewSessionToken's hash differs from HashSessionToken's")
+		}
+		if strings.Contains(hash, token) || len(hash) != 64 {
+			t.Fatalf("hash %q isn't a SHA-256 hex digest of the token", hash)
+		}
+	}
+}
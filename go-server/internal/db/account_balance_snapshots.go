@@ -0,0 +1,135 @@
+package db
+
+import (
+	"compound/go-server/pkg/models"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// balanceSnapshotColumns is selected by every balance snapshot query, in the order scanBalanceSnapshot expects
+const balanceSnapshotColumns = `id, account_id, date, current_balance, available_balance, iso_currency_code,
+	                            unofficial_currency_code, created_at, updated_at`
+
+// scanBalanceSnapshot scans a row selected with balanceSnapshotColumns
+func scanBalanceSnapshot(row pgx.Row) (*models.AccountBalanceSnapshot, error) {
+	snapshot := &models.AccountBalanceSnapshot{}
+	err := row.Scan(
+		&snapshot.ID,
+		&snapshot.AccountID,
+		&snapshot.Date,
+		&snapshot.CurrentBalance,
+		&snapshot.AvailableBalance,
+		&snapshot.IsoCurrencyCode,
+		&snapshot.UnofficialCurrencyCode,
+		&snapshot.CreatedAt,
+		&snapshot.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// RecordAccountBalanceSnapshot stores an account's balances as the snapshot of date, normally Today()
+// A later call on the same day overwrites it, so each day keeps the last balances fetched
+func (s *Store) RecordAccountBalanceSnapshot(ctx context.Context, accountID int, date time.Time, balances AccountBalances) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `INSERT INTO account_balance_snapshots_table
+	            (account_id, date, current_balance, available_balance, iso_currency_code, unofficial_currency_code)
+	          VALUES ($1, $2::date, $3, $4, $5, $6)
+	          ON CONFLICT (account_id, date) DO UPDATE SET
+	            current_balance = EXCLUDED.current_balance,
+	            available_balance = EXCLUDED.available_balance,
+	            iso_currency_code = EXCLUDED.iso_currency_code,
+	            unofficial_currency_code = EXCLUDED.unofficial_currency_code`
+
+	_, err := s.q.Exec(ctx, query,
+		accountID,
+		date,
+		balances.Current,
+		balances.Available,
+		balances.IsoCurrencyCode,
+		balances.UnofficialCurrencyCode,
+	)
+	if err != nil {
+		return fmt.Errorf("query failed: %w", err)
+	}
+
+	return nil
+}
+
+// GetAccountBalanceSnapshots retrieves an account's daily balances from a date on, oldest first
+func (s *Store) GetAccountBalanceSnapshots(ctx context.Context, accountID int, from time.Time) ([]*models.AccountBalanceSnapshot, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + balanceSnapshotColumns + `
+	          FROM account_balance_snapshots_table
+	          WHERE account_id=$1 AND date >= $2::date
+	          ORDER BY date`
+
+	rows, err := s.q.Query(ctx, query, accountID, from)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var snapshots []*models.AccountBalanceSnapshot
+	for rows.Next() {
+		snapshot, err := scanBalanceSnapshot(rows)
+		if err != nil {
+			return nil, fmt.Errorf("row scan failed: %w", err)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration failed: %w", err)
+	}
+
+	return snapshots, nil
+}
+
+// GetBalanceSnapshotsByUserID retrieves the snapshots of every account of a user from a date on,
+// plus each account's last snapshot before it, ordered by account then date
+// The earlier snapshot gives the account's balance on from when it wasn't fetched that day.
+func (s *Store) GetBalanceSnapshotsByUserID(ctx context.Context, userID int, from time.Time) ([]*models.AccountBalanceSnapshot, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + balanceSnapshotColumns + ` FROM (
+	            SELECT s.* FROM account_balance_snapshots_table s JOIN accounts a ON a.id = s.account_id
+	            WHERE a.user_id=$1 AND s.date >= $2
+	            UNION ALL
+	            (SELECT DISTINCT ON (s.account_id) s.* FROM account_balance_snapshots_table s JOIN accounts a ON a.id = s.account_id
+	             WHERE a.user_id=$1 AND s.date < $2
+	             ORDER BY s.account_id, s.date DESC)
+	          ) snapshots
+	          ORDER BY account_id, date`
+
+	rows, err := s.q.Query(ctx, query, userID, from)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var snapshots []*models.AccountBalanceSnapshot
+	for rows.Next() {
+		snapshot, err := scanBalanceSnapshot(rows)
+		if err != nil {
+			return nil, fmt.Errorf("row scan failed: %w", err)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration failed: %w", err)
+	}
+
+	return snapshots, nil
+}
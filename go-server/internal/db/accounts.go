@@ -4,33 +4,85 @@ import (
 	"compound/go-server/pkg/models"
 	"context"
 	"fmt"
+
+	"github.com/jackc/pgx/v5"
 )
 
-// CreateOrUpdateAccount creates or updates an account in the database
-func CreateOrUpdateAccount(ctx context.Context, itemID int, plaidAccountID, name, mask, accountType, subtype string) (*models.Account, error) {
-	query := `INSERT INTO accounts_table (item_id, plaid_account_id, name, mask, type, subtype, created_at, updated_at)
-	          VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
-	          ON CONFLICT (plaid_account_id) DO UPDATE SET
-	            name = EXCLUDED.name,
-	            mask = EXCLUDED.mask,
-	            type = EXCLUDED.type,
-	            subtype = EXCLUDED.subtype,
-	            updated_at = NOW()
-	          RETURNING id, item_id, plaid_account_id, name, mask, type, subtype, created_at, updated_at`
+// accountColumns is selected by every account query, in the order scanAccount expects
+const accountColumns = `id, item_id, plaid_account_id, name, mask, official_name, current_balance, available_balance,
+	                    iso_currency_code, unofficial_currency_code, type, subtype, created_at, updated_at, closed_at`
 
+// AccountBalances are an account's balances as reported by Plaid, nil where Plaid reports none
+type AccountBalances struct {
+	Current                *models.Money
+	Available              *models.Money
+	IsoCurrencyCode        *string
+	UnofficialCurrencyCode *string
+}
+
+// scanAccount scans a row selected with accountColumns
+func scanAccount(row pgx.Row) (*models.Account, error) {
 	account := &models.Account{}
-	err := conn.QueryRow(ctx, query, itemID, plaidAccountID, name, mask, accountType, subtype).Scan(
+	err := row.Scan(
 		&account.ID,
 		&account.ItemID,
 		&account.PlaidAccountID,
 		&account.Name,
 		&account.Mask,
+		&account.OfficialName,
+		&account.CurrentBalance,
+		&account.AvailableBalance,
+		&account.IsoCurrencyCode,
+		&account.UnofficialCurrencyCode,
 		&account.Type,
 		&account.Subtype,
 		&account.CreatedAt,
 		&account.UpdatedAt,
+		&account.ClosedAt,
 	)
+	if err != nil {
+		return nil, err
+	}
+	return account, nil
+}
 
+// CreateOrUpdateAccount creates or updates an account in the database
+// Balances are overwritten with the ones given, updating a closed account reopens it
+func (s *Store) CreateOrUpdateAccount(ctx context.Context, itemID int, plaidAccountID, name, mask string, officialName *string, accountType, subtype string, balances AccountBalances) (*models.Account, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `INSERT INTO accounts_table
+	            (item_id, plaid_account_id, name, mask, official_name, current_balance, available_balance,
+	             iso_currency_code, unofficial_currency_code, type, subtype, created_at, updated_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NOW(), NOW())
+	          ON CONFLICT (plaid_account_id) DO UPDATE SET
+	            name = EXCLUDED.name,
+	            mask = EXCLUDED.mask,
+	            official_name = EXCLUDED.official_name,
+	            current_balance = EXCLUDED.current_balance,
+	            available_balance = EXCLUDED.available_balance,
+	            iso_currency_code = EXCLUDED.iso_currency_code,
+	            unofficial_currency_code = EXCLUDED.unofficial_currency_code,
+	            type = EXCLUDED.type,
+	            subtype = EXCLUDED.subtype,
+	            closed_at = NULL,
+	            updated_at = NOW()
+	          RETURNING ` + accountColumns
+
+	account, err := scanAccount(s.q.QueryRow(ctx, query,
+		itemID,
+		plaidAccountID,
+		name,
+		mask,
+		officialName,
+		balances.Current,
+		balances.Available,
+		balances.IsoCurrencyCode,
+		balances.UnofficialCurrencyCode,
+		accountType,
+		subtype,
+	))
 	if err != nil {
 		return nil, fmt.Errorf("query failed: %w", err)
 	}
@@ -39,11 +91,45 @@ func CreateOrUpdateAccount(ctx context.Context, itemID int, plaidAccountID, name
 }
 
 // GetAccountsByItemID retrieves all accounts for a specific item
-func GetAccountsByItemID(ctx context.Context, itemID int) ([]*models.Account, error) {
-	query := `SELECT id, item_id, plaid_account_id, name, mask, type, subtype, created_at, updated_at
+func (s *Store) GetAccountsByItemID(ctx context.Context, itemID int) ([]*models.Account, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + accountColumns + `
 	          FROM accounts_table WHERE item_id=$1`
 
-	rows, err := conn.Query(ctx, query, itemID)
+	rows, err := s.q.Query(ctx, query, itemID)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var accounts []*models.Account
+	for rows.Next() {
+		account, err := scanAccount(rows)
+		if err != nil {
+			return nil, fmt.Errorf("row scan failed: %w", err)
+		}
+		accounts = append(accounts, account)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration failed: %w", err)
+	}
+
+	return accounts, nil
+}
+
+// GetAccountsByUserID retrieves all accounts across a user's items, closed ones included
+func (s *Store) GetAccountsByUserID(ctx context.Context, userID int) ([]*models.Account, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + accountColumns + `
+	          FROM accounts WHERE user_id=$1
+	          ORDER BY id`
+
+	rows, err := s.q.Query(ctx, query, userID)
 	if err != nil {
 		return nil, fmt.Errorf("query failed: %w", err)
 	}
@@ -51,18 +137,7 @@ func GetAccountsByItemID(ctx context.Context, itemID int) ([]*models.Account, er
 
 	var accounts []*models.Account
 	for rows.Next() {
-		account := &models.Account{}
-		err := rows.Scan(
-			&account.ID,
-			&account.ItemID,
-			&account.PlaidAccountID,
-			&account.Name,
-			&account.Mask,
-			&account.Type,
-			&account.Subtype,
-			&account.CreatedAt,
-			&account.UpdatedAt,
-		)
+		account, err := scanAccount(rows)
 		if err != nil {
 			return nil, fmt.Errorf("row scan failed: %w", err)
 		}
@@ -77,23 +152,14 @@ func GetAccountsByItemID(ctx context.Context, itemID int) ([]*models.Account, er
 }
 
 // GetAccountByID retrieves a single account by ID
-func GetAccountByID(ctx context.Context, accountID int) (*models.Account, error) {
-	query := `SELECT id, item_id, plaid_account_id, name, mask, type, subtype, created_at, updated_at
-	          FROM accounts_table WHERE id=$1`
+func (s *Store) GetAccountByID(ctx context.Context, accountID int) (*models.Account, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 
-	account := &models.Account{}
-	err := conn.QueryRow(ctx, query, accountID).Scan(
-		&account.ID,
-		&account.ItemID,
-		&account.PlaidAccountID,
-		&account.Name,
-		&account.Mask,
-		&account.Type,
-		&account.Subtype,
-		&account.CreatedAt,
-		&account.UpdatedAt,
-	)
+	query := `SELECT ` + accountColumns + `
+	          FROM accounts_table WHERE id=$1`
 
+	account, err := scanAccount(s.q.QueryRow(ctx, query, accountID))
 	if err != nil {
 		return nil, fmt.Errorf("query failed: %w", err)
 	}
@@ -101,11 +167,46 @@ func GetAccountByID(ctx context.Context, accountID int) (*models.Account, error)
 	return account, nil
 }
 
+// GetAccountOwnerID returns the ID of the user who owns an account
+func (s *Store) GetAccountOwnerID(ctx context.Context, accountID int) (int, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := "SELECT user_id FROM accounts WHERE id=$1"
+
+	var userID int
+	if err := s.q.QueryRow(ctx, query, accountID).Scan(&userID); err != nil {
+		return 0, fmt.Errorf("query failed: %w", err)
+	}
+
+	return userID, nil
+}
+
+// CloseMissingAccounts marks an item's open accounts that aren't in plaidAccountIDs as closed
+// Returns the number of accounts closed
+func (s *Store) CloseMissingAccounts(ctx context.Context, itemID int, plaidAccountIDs []string) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE accounts_table SET closed_at=NOW()
+	          WHERE item_id=$1 AND closed_at IS NULL AND NOT (plaid_account_id = ANY($2))`
+
+	result, err := s.q.Exec(ctx, query, itemID, plaidAccountIDs)
+	if err != nil {
+		return 0, fmt.Errorf("query failed: %w", err)
+	}
+
+	return result.RowsAffected(), nil
+}
+
 // DeleteAccount deletes an account from the database
-func DeleteAccount(ctx context.Context, accountID int) error {
+func (s *Store) DeleteAccount(ctx context.Context, accountID int) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	query := `DELETE FROM accounts_table WHERE id=$1`
 
-	result, err := conn.Exec(ctx, query, accountID)
+	result, err := s.q.Exec(ctx, query, accountID)
 	if err != nil {
 		return fmt.Errorf("query failed: %w", err)
 	}
@@ -117,24 +218,15 @@ func DeleteAccount(ctx context.Context, accountID int) error {
 	return nil
 }
 
-// Query accounts_table by plaidAccountID, return Account object
-func GetAccountByPlaidAccountID(ctx context.Context, plaidAccountID string) (*models.Account, error) {
-	query := `SELECT id, item_id, plaid_account_id, name, mask, type, subtype, created_at, updated_at
-            FROM accounts_table WHERE plaid_account_id=$1`
+// Query accounts_table by plaidAccountID within an item, return Account object
+func (s *Store) GetAccountByPlaidAccountID(ctx context.Context, itemID int, plaidAccountID string) (*models.Account, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 
-	account := &models.Account{}
+	query := `SELECT ` + accountColumns + `
+            FROM accounts_table WHERE item_id=$1 AND plaid_account_id=$2`
 
-	err := conn.QueryRow(ctx, query, plaidAccountID).Scan(
-		&account.ID,
-		&account.ItemID,
-		&account.PlaidAccountID,
-		&account.Name,
-		&account.Mask,
-		&account.Type,
-		&account.Subtype,
-		&account.CreatedAt,
-		&account.UpdatedAt,
-	)
+	account, err := scanAccount(s.q.QueryRow(ctx, query, itemID, plaidAccountID))
 	if err != nil {
 		return nil, fmt.Errorf("query failed: %w", err)
 	}
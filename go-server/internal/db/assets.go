@@ -0,0 +1,249 @@
+package db
+
+import (
+	"compound/go-server/pkg/models"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// assetColumns is selected by every asset query, in the order scanAsset expects
+const assetColumns = `id, user_id, value, description, created_at, updated_at`
+
+// assetValueColumns is selected by every asset value query, in the order scanAssetValue expects
+const assetValueColumns = `id, asset_id, date, value, created_at, updated_at`
+
+// scanAsset scans a row selected with assetColumns
+func scanAsset(row pgx.Row) (*models.Asset, error) {
+	asset := &models.Asset{}
+	err := row.Scan(
+		&asset.ID,
+		&asset.UserID,
+		&asset.Value,
+		&asset.Description,
+		&asset.CreatedAt,
+		&asset.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return asset, nil
+}
+
+// scanAssetValue scans a row selected with assetValueColumns
+func scanAssetValue(row pgx.Row) (*models.AssetValue, error) {
+	value := &models.AssetValue{}
+	err := row.Scan(
+		&value.ID,
+		&value.AssetID,
+		&value.Date,
+		&value.Value,
+		&value.CreatedAt,
+		&value.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// CreateAsset creates a manual asset for a user
+// Record its value history with RecordAssetValue in the same transaction
+func (s *Store) CreateAsset(ctx context.Context, userID int, description string, value models.Money) (*models.Asset, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `INSERT INTO assets_table (user_id, description, value)
+	          VALUES ($1, $2, $3)
+	          RETURNING ` + assetColumns
+
+	asset, err := scanAsset(s.q.QueryRow(ctx, query, userID, description, value))
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+
+	return asset, nil
+}
+
+// GetAssetByID retrieves a single asset by ID
+func (s *Store) GetAssetByID(ctx context.Context, assetID int) (*models.Asset, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + assetColumns + `
+	          FROM assets WHERE id=$1`
+
+	asset, err := scanAsset(s.q.QueryRow(ctx, query, assetID))
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+
+	return asset, nil
+}
+
+// GetAssetOwnerID returns the ID of the user who owns an asset
+func (s *Store) GetAssetOwnerID(ctx context.Context, assetID int) (int, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := "SELECT user_id FROM assets WHERE id=$1"
+
+	var userID int
+	if err := s.q.QueryRow(ctx, query, assetID).Scan(&userID); err != nil {
+		return 0, fmt.Errorf("query failed: %w", err)
+	}
+
+	return userID, nil
+}
+
+// UpdateAssetDescription updates the description of an asset
+func (s *Store) UpdateAssetDescription(ctx context.Context, assetID int, description string) (*models.Asset, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE assets_table SET description=$1 WHERE id=$2
+	          RETURNING ` + assetColumns
+
+	asset, err := scanAsset(s.q.QueryRow(ctx, query, description, assetID))
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+
+	return asset, nil
+}
+
+// RecordAssetValue stores an asset's value as of date, overwriting any value already recorded
+// that day. The asset's current value is then reset to its latest recorded value, so backfilling
+// an old value leaves it alone. Run it in a transaction.
+// Values recorded for the current day are dated Today().
+func (s *Store) RecordAssetValue(ctx context.Context, assetID int, date time.Time, value models.Money) (*models.Asset, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `INSERT INTO asset_values_table (asset_id, date, value)
+	          VALUES ($1, $2::date, $3)
+	          ON CONFLICT (asset_id, date) DO UPDATE SET value = EXCLUDED.value`
+
+	if _, err := s.q.Exec(ctx, query, assetID, date, value); err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+
+	query = `UPDATE assets_table SET value = (
+	            SELECT value FROM asset_values_table WHERE asset_id=$1 ORDER BY date DESC LIMIT 1
+	          ) WHERE id=$1
+	          RETURNING ` + assetColumns
+
+	asset, err := scanAsset(s.q.QueryRow(ctx, query, assetID))
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+
+	return asset, nil
+}
+
+// DeleteAsset deletes an asset along with its value history
+func (s *Store) DeleteAsset(ctx context.Context, assetID int) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `DELETE FROM assets_table WHERE id=$1`
+
+	result, err := s.q.Exec(ctx, query, assetID)
+	if err != nil {
+		return fmt.Errorf("query failed: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("asset not found")
+	}
+
+	return nil
+}
+
+// GetAssetValues retrieves an asset's value history, oldest first
+func (s *Store) GetAssetValues(ctx context.Context, assetID int) ([]*models.AssetValue, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + assetValueColumns + `
+	          FROM asset_values_table WHERE asset_id=$1
+	          ORDER BY date`
+
+	return s.queryAssetValues(ctx, query, assetID)
+}
+
+// GetAssetValuesByUserID retrieves the values of every asset of a user recorded from a date on,
+// plus each asset's last value before it, ordered by asset then date
+// The earlier value is the asset's value on from when none was recorded that day.
+func (s *Store) GetAssetValuesByUserID(ctx context.Context, userID int, from time.Time) ([]*models.AssetValue, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + assetValueColumns + ` FROM (
+	            SELECT v.* FROM asset_values_table v JOIN assets a ON a.id = v.asset_id
+	            WHERE a.user_id=$1 AND v.date >= $2
+	            UNION ALL
+	            (SELECT DISTINCT ON (v.asset_id) v.* FROM asset_values_table v JOIN assets a ON a.id = v.asset_id
+	             WHERE a.user_id=$1 AND v.date < $2
+	             ORDER BY v.asset_id, v.date DESC)
+	          ) asset_values
+	          ORDER BY asset_id, date`
+
+	return s.queryAssetValues(ctx, query, userID, from)
+}
+
+func (s *Store) queryAssetValues(ctx context.Context, query string, args ...any) ([]*models.AssetValue, error) {
+	rows, err := s.q.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var values []*models.AssetValue
+	for rows.Next() {
+		value, err := scanAssetValue(rows)
+		if err != nil {
+			return nil, fmt.Errorf("row scan failed: %w", err)
+		}
+		values = append(values, value)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration failed: %w", err)
+	}
+
+	return values, nil
+}
+
+// GetAssetsByUserID retrieves all of a user's manual assets, oldest first
+func (s *Store) GetAssetsByUserID(ctx context.Context, userID int) ([]*models.Asset, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + assetColumns + `
+	          FROM assets WHERE user_id=$1
+	          ORDER BY id`
+
+	rows, err := s.q.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var assets []*models.Asset
+	for rows.Next() {
+		asset, err := scanAsset(rows)
+		if err != nil {
+			return nil, fmt.Errorf("row scan failed: %w", err)
+		}
+		assets = append(assets, asset)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration failed: %w", err)
+	}
+
+	return assets, nil
+}
@@ -1,40 +1,179 @@
 package db
 
 import (
+	"compound/go-server/internal/secrets"
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-// Global connection variable
-var conn *pgx.Conn
+// defaultQueryTimeout bounds a single query when Config.QueryTimeout is not set
+const defaultQueryTimeout = 5 * time.Second
 
-// TODO - add some context driven timeouts (for connections and queries)
+// Config controls how the Store connects to PostgreSQL
+// Zero values fall back to pgxpool defaults (and defaultQueryTimeout)
+type Config struct {
+	DatabaseURL     string
+	MaxConns        int32
+	MinConns        int32
+	MaxConnLifetime time.Duration
+	MaxConnIdleTime time.Duration
+	ConnectTimeout  time.Duration
+	QueryTimeout    time.Duration
+	// Keyring encrypts Plaid access tokens at rest, required to create items or read encrypted tokens
+	Keyring *secrets.Keyring
+}
+
+// errNoKeyring is returned when access tokens are read or written without a keyring
+var errNoKeyring = errors.New("no encryption keyring configured")
+
+// Today returns midnight UTC of the current day, the date stored for anything recorded today
+// Dates are passed to queries rather than read from CURRENT_DATE, which is in the session's
+// time zone and can be a different day from the one the caller worked with
+func Today() time.Time {
+	now := time.Now().UTC()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
 
-// Connect establishes a connection to the PostgreSQL database
-func Connect(databaseURL string) error {
-	var err error
-	// Connect to the database
-	conn, err = pgx.Connect(context.Background(), databaseURL)
+// querier is satisfied by both the pool and an open transaction
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// Store is the entry point for every database query
+// A Store is safe for concurrent use, each query borrows a connection from the pool
+type Store struct {
+	pool         *pgxpool.Pool
+	q            querier
+	queryTimeout time.Duration
+	keyring      *secrets.Keyring
+}
+
+// NewStore opens a connection pool and verifies it with a ping
+func NewStore(ctx context.Context, cfg Config) (*Store, error) {
+	poolConfig, err := pgxpool.ParseConfig(cfg.DatabaseURL)
 	if err != nil {
-		return fmt.Errorf("unable to connect to database: %w", err)
+		return nil, fmt.Errorf("invalid database url: %w", err)
 	}
 
-	// Test the connection
-	err = conn.Ping(context.Background())
+	if cfg.MaxConns > 0 {
+		poolConfig.MaxConns = cfg.MaxConns
+	}
+	if cfg.MinConns > 0 {
+		poolConfig.MinConns = cfg.MinConns
+	}
+	if cfg.MaxConnLifetime > 0 {
+		poolConfig.MaxConnLifetime = cfg.MaxConnLifetime
+	}
+	if cfg.MaxConnIdleTime > 0 {
+		poolConfig.MaxConnIdleTime = cfg.MaxConnIdleTime
+	}
+	if cfg.ConnectTimeout > 0 {
+		poolConfig.ConnConfig.ConnectTimeout = cfg.ConnectTimeout
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
-		return fmt.Errorf("failed to ping database: %w", err)
+		return nil, fmt.Errorf("unable to connect to database: %w", err)
+	}
+
+	// Test the connection
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	queryTimeout := cfg.QueryTimeout
+	if queryTimeout <= 0 {
+		queryTimeout = defaultQueryTimeout
 	}
 
 	fmt.Println("Connected to database successfully")
-	return nil
+	return &Store{pool: pool, q: pool, queryTimeout: queryTimeout, keyring: cfg.Keyring}, nil
+}
+
+// Close closes every connection in the pool
+func (s *Store) Close() {
+	if s.pool != nil {
+		s.pool.Close()
+	}
+}
+
+// MaxConns returns the most connections the pool opens at once
+func (s *Store) MaxConns() int {
+	if s == nil || s.pool == nil {
+		return 0
+	}
+	return int(s.pool.Config().MaxConns)
+}
+
+// withTimeout derives the per-query context
+func (s *Store) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, s.queryTimeout)
 }
 
-// CloseConnection closes the database connection
-func CloseConnection(ctx context.Context) error {
-	if conn != nil {
-		return conn.Close(ctx)
+// WithTx runs fn inside a database transaction
+// fn receives a Store bound to the transaction, every query made through it is committed
+// if fn returns nil and rolled back otherwise. Calling WithTx on a transaction-bound Store
+// joins the existing transaction.
+func (s *Store) WithTx(ctx context.Context, fn func(tx *Store) error) error {
+	if s.pool == nil {
+		return fn(s)
 	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	// Rollback is a no-op once the transaction has been committed
+	defer tx.Rollback(ctx)
+
+	if err := fn(&Store{q: tx, queryTimeout: s.queryTimeout, keyring: s.keyring}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
 	return nil
 }
+
+// encryptAccessToken seals a Plaid access token, returning the ciphertext and key ID to store
+func (s *Store) encryptAccessToken(accessToken string) (string, string, error) {
+	if s.keyring == nil {
+		return "", "", errNoKeyring
+	}
+
+	ciphertext, keyID, err := s.keyring.Encrypt(accessToken)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encrypt access token: %w", err)
+	}
+
+	return ciphertext, keyID, nil
+}
+
+// decryptAccessToken opens a stored access token
+// A NULL key ID marks a legacy plaintext token written before encryption was added
+func (s *Store) decryptAccessToken(stored string, keyID *string) (string, error) {
+	if keyID == nil {
+		return stored, nil
+	}
+	if s.keyring == nil {
+		return "", errNoKeyring
+	}
+
+	accessToken, err := s.keyring.Decrypt(stored, *keyID)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt access token: %w", err)
+	}
+
+	return accessToken, nil
+}
@@ -0,0 +1,114 @@
+package db
+
+import (
+	"compound/go-server/pkg/models"
+	"context"
+	"fmt"
+)
+
+// CreateItemRemoval records that an item was removed
+// A removal with a PlaidAccessToken is pending: the item still has to be removed from Plaid, the
+// token is encrypted and kept until CompleteItemRemoval. Any other removal is already complete.
+// removal.ID, removal.RemovedAt and removal.PlaidRemovedAt are filled in from the new row
+func (s *Store) CreateItemRemoval(ctx context.Context, removal *models.ItemRemoval) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var encryptedToken, keyID *string
+	if removal.PlaidAccessToken != "" {
+		ciphertext, id, err := s.encryptAccessToken(removal.PlaidAccessToken)
+		if err != nil {
+			return err
+		}
+		encryptedToken, keyID = &ciphertext, &id
+	}
+
+	query := `INSERT INTO item_removals_table
+	            (item_id, user_id, plaid_item_id, plaid_institution_id, plaid_already_removed,
+	             plaid_access_token, plaid_access_token_key_id, removed_at, plaid_removed_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, NOW(), CASE WHEN $6::text IS NULL THEN NOW() END)
+	          RETURNING id, removed_at, plaid_removed_at`
+
+	err := s.q.QueryRow(ctx, query,
+		removal.ItemID,
+		removal.UserID,
+		removal.PlaidItemID,
+		removal.PlaidInstitutionID,
+		removal.PlaidAlreadyRemoved,
+		encryptedToken,
+		keyID,
+	).Scan(&removal.ID, &removal.RemovedAt, &removal.PlaidRemovedAt)
+	if err != nil {
+		return fmt.Errorf("query failed: %w", err)
+	}
+
+	return nil
+}
+
+// GetPendingItemRemovals retrieves the removals still waiting on Plaid, oldest first, with their
+// access tokens decrypted
+func (s *Store) GetPendingItemRemovals(ctx context.Context) ([]*models.ItemRemoval, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT id, item_id, user_id, plaid_item_id, plaid_institution_id, plaid_already_removed,
+	                 plaid_access_token, plaid_access_token_key_id, removed_at
+	          FROM item_removals_table WHERE plaid_removed_at IS NULL
+	          ORDER BY id`
+
+	rows, err := s.q.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var removals []*models.ItemRemoval
+	for rows.Next() {
+		removal := &models.ItemRemoval{}
+		var keyID *string
+		err := rows.Scan(
+			&removal.ID,
+			&removal.ItemID,
+			&removal.UserID,
+			&removal.PlaidItemID,
+			&removal.PlaidInstitutionID,
+			&removal.PlaidAlreadyRemoved,
+			&removal.PlaidAccessToken,
+			&keyID,
+			&removal.RemovedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("row scan failed: %w", err)
+		}
+
+		removal.PlaidAccessToken, err = s.decryptAccessToken(removal.PlaidAccessToken, keyID)
+		if err != nil {
+			return nil, fmt.Errorf("item removal %d: %w", removal.ID, err)
+		}
+		removals = append(removals, removal)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration failed: %w", err)
+	}
+
+	return removals, nil
+}
+
+// CompleteItemRemoval marks a pending removal done once Plaid has removed the item, and forgets
+// its access token
+func (s *Store) CompleteItemRemoval(ctx context.Context, removalID int, plaidAlreadyRemoved bool) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE item_removals_table
+	          SET plaid_removed_at=NOW(), plaid_already_removed=$2,
+	              plaid_access_token=NULL, plaid_access_token_key_id=NULL
+	          WHERE id=$1`
+
+	if _, err := s.q.Exec(ctx, query, removalID, plaidAlreadyRemoved); err != nil {
+		return fmt.Errorf("query failed: %w", err)
+	}
+
+	return nil
+}
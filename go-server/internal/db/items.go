@@ -4,26 +4,85 @@ import (
 	"compound/go-server/pkg/models"
 	"context"
 	"fmt"
+
+	"github.com/jackc/pgx/v5"
 )
 
-// GetItemByID retrieves an item from the database by ID
-func GetItemByID(ctx context.Context, id int) (*models.Item, error) {
-	query := `SELECT id, user_id, plaid_access_token, plaid_item_id, plaid_institution_id,
-	                 status, created_at, updated_at, transactions_cursor
-	          FROM items WHERE id=$1`
+// itemColumns is selected by every item query, in the order scanItem expects
+const itemColumns = `id, user_id, plaid_access_token, plaid_access_token_key_id, plaid_item_id,
+	                 plaid_institution_id, status, created_at, updated_at, transactions_cursor, last_synced_at,
+	                 new_accounts_available`
 
+// scanItem scans a row selected with itemColumns and decrypts its access token
+func (s *Store) scanItem(row pgx.Row) (*models.Item, error) {
 	item := &models.Item{}
-	err := conn.QueryRow(ctx, query, id).Scan(
+	var keyID *string
+	err := row.Scan(
 		&item.ID,
 		&item.UserID,
 		&item.PlaidAccessToken,
+		&keyID,
 		&item.PlaidItemID,
 		&item.PlaidInstitutionID,
 		&item.Status,
 		&item.CreatedAt,
 		&item.UpdatedAt,
 		&item.TransactionsCursor,
+		&item.LastSyncedAt,
+		&item.NewAccountsAvailable,
 	)
+	if err != nil {
+		return nil, err
+	}
+
+	item.PlaidAccessToken, err = s.decryptAccessToken(item.PlaidAccessToken, keyID)
+	if err != nil {
+		return nil, fmt.Errorf("item %d: %w", item.ID, err)
+	}
+
+	return item, nil
+}
+
+// GetItemByID retrieves an item from the database by ID
+func (s *Store) GetItemByID(ctx context.Context, id int) (*models.Item, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + itemColumns + `
+	          FROM items WHERE id=$1`
+
+	item, err := s.scanItem(s.q.QueryRow(ctx, query, id))
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+
+	return item, nil
+}
+
+// GetItemOwnerID returns the ID of the user who owns an item
+func (s *Store) GetItemOwnerID(ctx context.Context, itemID int) (int, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := "SELECT user_id FROM items WHERE id=$1"
+
+	var userID int
+	if err := s.q.QueryRow(ctx, query, itemID).Scan(&userID); err != nil {
+		return 0, fmt.Errorf("query failed: %w", err)
+	}
+
+	return userID, nil
+}
+
+// GetItemByPlaidItemID retrieves an item from the database by its Plaid item ID
+func (s *Store) GetItemByPlaidItemID(ctx context.Context, plaidItemID string) (*models.Item, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + itemColumns + `
+	          FROM items WHERE plaid_item_id=$1`
+
+	item, err := s.scanItem(s.q.QueryRow(ctx, query, plaidItemID))
 	if err != nil {
 		return nil, fmt.Errorf("query failed: %w", err)
 	}
@@ -32,12 +91,14 @@ func GetItemByID(ctx context.Context, id int) (*models.Item, error) {
 }
 
 // GetItemsByUserID retrieves all items for a user
-func GetItemsByUserID(ctx context.Context, userID int) ([]*models.Item, error) {
-	query := `SELECT id, user_id, plaid_access_token, plaid_item_id, plaid_institution_id,
-	                 status, created_at, updated_at, transactions_cursor
+func (s *Store) GetItemsByUserID(ctx context.Context, userID int) ([]*models.Item, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + itemColumns + `
 	          FROM items WHERE user_id=$1`
 
-	rows, err := conn.Query(ctx, query, userID)
+	rows, err := s.q.Query(ctx, query, userID)
 	if err != nil {
 		return nil, fmt.Errorf("query failed: %w", err)
 	}
@@ -45,18 +106,7 @@ func GetItemsByUserID(ctx context.Context, userID int) ([]*models.Item, error) {
 
 	var items []*models.Item
 	for rows.Next() {
-		item := &models.Item{}
-		err := rows.Scan(
-			&item.ID,
-			&item.UserID,
-			&item.PlaidAccessToken,
-			&item.PlaidItemID,
-			&item.PlaidInstitutionID,
-			&item.Status,
-			&item.CreatedAt,
-			&item.UpdatedAt,
-			&item.TransactionsCursor,
-		)
+		item, err := s.scanItem(rows)
 		if err != nil {
 			return nil, fmt.Errorf("row scan failed: %w", err)
 		}
@@ -70,25 +120,73 @@ func GetItemsByUserID(ctx context.Context, userID int) ([]*models.Item, error) {
 	return items, nil
 }
 
+// GetItemsByPlaidInstitutionID retrieves a user's items at one institution, oldest first
+func (s *Store) GetItemsByPlaidInstitutionID(ctx context.Context, userID int, plaidInstitutionID string) ([]*models.Item, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + itemColumns + `
+	          FROM items WHERE user_id=$1 AND plaid_institution_id=$2
+	          ORDER BY id`
+
+	rows, err := s.q.Query(ctx, query, userID, plaidInstitutionID)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var items []*models.Item
+	for rows.Next() {
+		item, err := s.scanItem(rows)
+		if err != nil {
+			return nil, fmt.Errorf("row scan failed: %w", err)
+		}
+		items = append(items, item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration failed: %w", err)
+	}
+
+	return items, nil
+}
+
+// GetItemIDsByStatus returns the IDs of every item in one of the given statuses, oldest first
+func (s *Store) GetItemIDsByStatus(ctx context.Context, statuses ...string) ([]int, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := "SELECT id FROM items WHERE status = ANY($1) ORDER BY id"
+
+	rows, err := s.q.Query(ctx, query, statuses)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return nil, fmt.Errorf("row scan failed: %w", err)
+	}
+
+	return ids, nil
+}
+
 // CreateItem creates a new item in the database
-func CreateItem(ctx context.Context, userID int, plaidAccessToken, plaidItemID, plaidInstitutionID, status string) (*models.Item, error) {
-	query := `INSERT INTO items (user_id, plaid_access_token, plaid_item_id, plaid_institution_id, status, created_at, updated_at)
-	          VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
-	          RETURNING id, user_id, plaid_access_token, plaid_item_id, plaid_institution_id, status, created_at, updated_at, transactions_cursor`
+// The access token is encrypted with the store's current key before it is written
+func (s *Store) CreateItem(ctx context.Context, userID int, plaidAccessToken, plaidItemID, plaidInstitutionID, status string) (*models.Item, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 
-	item := &models.Item{}
-	err := conn.QueryRow(ctx, query, userID, plaidAccessToken, plaidItemID, plaidInstitutionID, status).Scan(
-		&item.ID,
-		&item.UserID,
-		&item.PlaidAccessToken,
-		&item.PlaidItemID,
-		&item.PlaidInstitutionID,
-		&item.Status,
-		&item.CreatedAt,
-		&item.UpdatedAt,
-		&item.TransactionsCursor,
-	)
+	encryptedToken, keyID, err := s.encryptAccessToken(plaidAccessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `INSERT INTO items (user_id, plaid_access_token, plaid_access_token_key_id, plaid_item_id, plaid_institution_id, status, created_at, updated_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
+	          RETURNING ` + itemColumns
 
+	item, err := s.scanItem(s.q.QueryRow(ctx, query, userID, encryptedToken, keyID, plaidItemID, plaidInstitutionID, status))
 	if err != nil {
 		return nil, fmt.Errorf("query failed: %w", err)
 	}
@@ -97,10 +195,14 @@ func CreateItem(ctx context.Context, userID int, plaidAccessToken, plaidItemID,
 }
 
 // UpdateItemTransactionsCursor updates the transactions cursor for an item
-func UpdateItemTransactionsCursor(ctx context.Context, itemID int, cursor string) error {
-	query := `UPDATE items SET transactions_cursor=$1, updated_at=NOW() WHERE id=$2`
+// Only a completed sync moves the cursor, so this also stamps the item's last_synced_at
+func (s *Store) UpdateItemTransactionsCursor(ctx context.Context, itemID int, cursor string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 
-	result, err := conn.Exec(ctx, query, cursor, itemID)
+	query := `UPDATE items SET transactions_cursor=$1, last_synced_at=NOW(), updated_at=NOW() WHERE id=$2`
+
+	result, err := s.q.Exec(ctx, query, cursor, itemID)
 	if err != nil {
 		return fmt.Errorf("query failed: %w", err)
 	}
@@ -113,10 +215,32 @@ func UpdateItemTransactionsCursor(ctx context.Context, itemID int, cursor string
 }
 
 // UpdateItemStatus updates the status of an item
-func UpdateItemStatus(ctx context.Context, itemID int, status string) error {
+func (s *Store) UpdateItemStatus(ctx context.Context, itemID int, status string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	query := `UPDATE items SET status=$1, updated_at=NOW() WHERE id=$2`
 
-	result, err := conn.Exec(ctx, query, status, itemID)
+	result, err := s.q.Exec(ctx, query, status, itemID)
+	if err != nil {
+		return fmt.Errorf("query failed: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("item not found")
+	}
+
+	return nil
+}
+
+// UpdateItemNewAccountsAvailable sets whether the item has accounts the user hasn't shared yet
+func (s *Store) UpdateItemNewAccountsAvailable(ctx context.Context, itemID int, available bool) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE items SET new_accounts_available=$1, updated_at=NOW() WHERE id=$2`
+
+	result, err := s.q.Exec(ctx, query, available, itemID)
 	if err != nil {
 		return fmt.Errorf("query failed: %w", err)
 	}
@@ -129,10 +253,13 @@ func UpdateItemStatus(ctx context.Context, itemID int, status string) error {
 }
 
 // DeleteItem deletes an item from the database
-func DeleteItem(ctx context.Context, itemID int) error {
+func (s *Store) DeleteItem(ctx context.Context, itemID int) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	query := `DELETE FROM items WHERE id=$1`
 
-	result, err := conn.Exec(ctx, query, itemID)
+	result, err := s.q.Exec(ctx, query, itemID)
 	if err != nil {
 		return fmt.Errorf("query failed: %w", err)
 	}
@@ -143,3 +270,97 @@ func DeleteItem(ctx context.Context, itemID int) error {
 
 	return nil
 }
+
+// CountPlaintextAccessTokens returns the number of items whose access token is still stored in
+// plaintext, written before encryption was added
+func (s *Store) CountPlaintextAccessTokens(ctx context.Context) (int, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT COUNT(*) FROM items_table WHERE plaid_access_token_key_id IS NULL`
+
+	var count int
+	if err := s.q.QueryRow(ctx, query).Scan(&count); err != nil {
+		return 0, fmt.Errorf("query failed: %w", err)
+	}
+
+	return count, nil
+}
+
+// RotateAccessTokenKeys re-encrypts every access token not sealed with the current key
+// Legacy plaintext tokens and the tokens of pending item removals are encrypted too. Runs in one
+// transaction, returns the number of rows rewritten
+func (s *Store) RotateAccessTokenKeys(ctx context.Context) (int, error) {
+	if s.keyring == nil {
+		return 0, errNoKeyring
+	}
+
+	rotated := 0
+	err := s.WithTx(ctx, func(tx *Store) error {
+		for _, table := range []string{"items_table", "item_removals_table"} {
+			n, err := tx.rotateAccessTokenKeys(ctx, table)
+			if err != nil {
+				return fmt.Errorf("%s: %w", table, err)
+			}
+			rotated += n
+		}
+		return nil
+	})
+
+	return rotated, err
+}
+
+// rotateAccessTokenKeys re-encrypts the access tokens in table, which has plaid_access_token and
+// plaid_access_token_key_id columns, the caller must be in a transaction
+func (s *Store) rotateAccessTokenKeys(ctx context.Context, table string) (int, error) {
+	type storedToken struct {
+		id         int
+		ciphertext string
+		keyID      *string
+	}
+
+	rows, err := s.q.Query(ctx,
+		`SELECT id, plaid_access_token, plaid_access_token_key_id FROM `+table+`
+		 WHERE plaid_access_token IS NOT NULL AND plaid_access_token_key_id IS DISTINCT FROM $1
+		 FOR UPDATE`,
+		s.keyring.CurrentKeyID())
+	if err != nil {
+		return 0, fmt.Errorf("query failed: %w", err)
+	}
+
+	// collect first, the connection can't run updates while rows are still open
+	var tokens []storedToken
+	for rows.Next() {
+		var t storedToken
+		if err := rows.Scan(&t.id, &t.ciphertext, &t.keyID); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("row scan failed: %w", err)
+		}
+		tokens = append(tokens, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("rows iteration failed: %w", err)
+	}
+
+	for _, t := range tokens {
+		plaintext, err := s.decryptAccessToken(t.ciphertext, t.keyID)
+		if err != nil {
+			return 0, fmt.Errorf("row %d: %w", t.id, err)
+		}
+
+		ciphertext, keyID, err := s.encryptAccessToken(plaintext)
+		if err != nil {
+			return 0, fmt.Errorf("row %d: %w", t.id, err)
+		}
+
+		_, err = s.q.Exec(ctx,
+			`UPDATE `+table+` SET plaid_access_token=$1, plaid_access_token_key_id=$2 WHERE id=$3`,
+			ciphertext, keyID, t.id)
+		if err != nil {
+			return 0, fmt.Errorf("query failed: %w", err)
+		}
+	}
+
+	return len(tokens), nil
+}
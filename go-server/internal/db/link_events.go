@@ -0,0 +1,103 @@
+package db
+
+import (
+	"compound/go-server/pkg/models"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// LinkEventFilter narrows GetLinkEvents, zero fields other than Limit don't filter
+type LinkEventFilter struct {
+	UserID        int
+	LinkSessionID string
+	ErrorsOnly    bool
+	Limit         int
+}
+
+// CreateLinkEvent records a Plaid Link callback
+// Every callback is stored, several callbacks of one Link request share its request ID
+func (s *Store) CreateLinkEvent(ctx context.Context, event *models.LinkEvent) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `INSERT INTO link_events_table
+	            (type, user_id, link_session_id, request_id, error_type, error_code, status, created_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())`
+
+	_, err := s.q.Exec(ctx, query,
+		event.Type,
+		event.UserID,
+		event.LinkSessionID,
+		event.RequestID,
+		event.ErrorType,
+		event.ErrorCode,
+		event.Status,
+	)
+	if err != nil {
+		return fmt.Errorf("query failed: %w", err)
+	}
+
+	return nil
+}
+
+// GetLinkEvents retrieves the most recent link events matching filter, newest first
+func (s *Store) GetLinkEvents(ctx context.Context, filter LinkEventFilter) ([]*models.LinkEvent, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var conditions []string
+	var args []any
+	if filter.UserID != 0 {
+		args = append(args, filter.UserID)
+		conditions = append(conditions, "user_id=$"+strconv.Itoa(len(args)))
+	}
+	if filter.LinkSessionID != "" {
+		args = append(args, filter.LinkSessionID)
+		conditions = append(conditions, "link_session_id=$"+strconv.Itoa(len(args)))
+	}
+	if filter.ErrorsOnly {
+		conditions = append(conditions, "(error_code IS NOT NULL OR error_type IS NOT NULL)")
+	}
+
+	query := `SELECT id, type, user_id, link_session_id, request_id, error_type, error_code, status, created_at
+	          FROM link_events_table`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += " ORDER BY created_at DESC, id DESC LIMIT $" + strconv.Itoa(len(args))
+
+	rows, err := s.q.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*models.LinkEvent
+	for rows.Next() {
+		event := &models.LinkEvent{}
+		err := rows.Scan(
+			&event.ID,
+			&event.Type,
+			&event.UserID,
+			&event.LinkSessionID,
+			&event.RequestID,
+			&event.ErrorType,
+			&event.ErrorCode,
+			&event.Status,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("row scan failed: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration failed: %w", err)
+	}
+
+	return events, nil
+}
@@ -0,0 +1,77 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Advisory lock classes, the first key of the two-key form of pg_advisory_lock
+// Each class is its own namespace of object IDs (the second key)
+const (
+	// LockClassSyncScheduler is held by the one replica running the background sync scheduler
+	LockClassSyncScheduler int32 = 1
+	// LockClassItemSync is held while an item's transactions sync, the object ID is the item ID
+	LockClassItemSync int32 = 2
+)
+
+// AdvisoryLock is a session-level Postgres advisory lock
+// The lock lives as long as the connection it was taken on, so the connection is held
+// out of the pool until Release is called
+type AdvisoryLock struct {
+	conn     *pgxpool.Conn
+	class    int32
+	objectID int32
+}
+
+// TryAdvisoryLock takes the advisory lock (class, objectID) without waiting
+// Returns nil and no error if another session already holds it
+func (s *Store) TryAdvisoryLock(ctx context.Context, class, objectID int32) (*AdvisoryLock, error) {
+	return s.advisoryLock(ctx, `SELECT pg_try_advisory_lock($1, $2)`, class, objectID)
+}
+
+// advisoryLock runs a locking query returning whether the lock was taken, on a connection held for the lock
+func (s *Store) advisoryLock(ctx context.Context, query string, class, objectID int32) (*AdvisoryLock, error) {
+	if s.pool == nil {
+		return nil, fmt.Errorf("advisory locks cannot be taken inside a transaction")
+	}
+
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+
+	var acquired bool
+	err = conn.QueryRow(ctx, query, class, objectID).Scan(&acquired)
+	if err != nil {
+		// a cancelled query may leave the lock request behind, closing the session drops it
+		conn.Conn().Close(context.Background())
+		conn.Release()
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	if !acquired {
+		conn.Release()
+		return nil, nil
+	}
+
+	return &AdvisoryLock{conn: conn, class: class, objectID: objectID}, nil
+}
+
+// Held reports whether the lock is still held, i.e. its connection is still alive
+func (l *AdvisoryLock) Held(ctx context.Context) bool {
+	return l.conn.Ping(ctx) == nil
+}
+
+// Release unlocks and returns the connection to the pool
+// If the unlock fails the connection is closed instead, which drops the lock with the session
+func (l *AdvisoryLock) Release() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := l.conn.Exec(ctx, `SELECT pg_advisory_unlock($1, $2)`, l.class, l.objectID); err != nil {
+		l.conn.Conn().Close(ctx)
+	}
+	l.conn.Release()
+}
@@ -0,0 +1,291 @@
+package db
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// migrationFiles holds every schema migration, named NNNN_description.up.sql / .down.sql
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID is the pg_advisory_lock key held while migrating, so replicas
+// starting at the same time don't race to apply the same migration
+const migrationLockID = 7_245_401_119
+
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is one versioned schema change
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus reports whether a migration has been applied
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// LoadMigrations reads the embedded migrations, sorted by version
+func LoadMigrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected migration file name: %s", entry.Name())
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		body, err := migrationFiles.ReadFile("migrations/" + entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %s and %s", version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// MigrateUp applies every migration that hasn't been applied yet
+// Returns the versions that were applied, in order
+func (s *Store) MigrateUp(ctx context.Context) ([]int, error) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []int
+	err = s.withMigrationLock(ctx, func(conn *pgxpool.Conn) error {
+		done, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range migrations {
+			if _, ok := done[m.Version]; ok {
+				continue
+			}
+
+			err := runMigration(ctx, conn, m.Up,
+				`INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, NOW())`,
+				m.Version, m.Name)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s failed: %w", m.Version, m.Name, err)
+			}
+			applied = append(applied, m.Version)
+		}
+
+		return nil
+	})
+
+	return applied, err
+}
+
+// MigrateDown reverts the most recent `steps` applied migrations
+// Returns the versions that were reverted, newest first
+func (s *Store) MigrateDown(ctx context.Context, steps int) ([]int, error) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var reverted []int
+	err = s.withMigrationLock(ctx, func(conn *pgxpool.Conn) error {
+		done, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			m := migrations[i]
+			if _, ok := done[m.Version]; !ok {
+				continue
+			}
+			if m.Down == "" {
+				return fmt.Errorf("migration %d_%s has no down file", m.Version, m.Name)
+			}
+
+			err := runMigration(ctx, conn, m.Down,
+				`DELETE FROM schema_migrations WHERE version=$1 AND name=$2`,
+				m.Version, m.Name)
+			if err != nil {
+				return fmt.Errorf("reverting migration %d_%s failed: %w", m.Version, m.Name, err)
+			}
+			reverted = append(reverted, m.Version)
+		}
+
+		return nil
+	})
+
+	return reverted, err
+}
+
+// MigrationStatuses lists every known migration and when it was applied
+func (s *Store) MigrationStatuses(ctx context.Context) ([]MigrationStatus, error) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	err = s.withMigrationLock(ctx, func(conn *pgxpool.Conn) error {
+		done, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range migrations {
+			status := MigrationStatus{Version: m.Version, Name: m.Name}
+			if appliedAt, ok := done[m.Version]; ok {
+				status.AppliedAt = &appliedAt
+			}
+			statuses = append(statuses, status)
+		}
+		return nil
+	})
+
+	return statuses, err
+}
+
+// withMigrationLock runs fn on a single connection holding the migration advisory lock
+// Advisory locks belong to a session, so everything must go through the same connection
+func (s *Store) withMigrationLock(ctx context.Context, fn func(conn *pgxpool.Conn) error) error {
+	if s.pool == nil {
+		return fmt.Errorf("migrations cannot run inside a transaction")
+	}
+
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockID)
+
+	if err := ensureMigrationsTable(ctx, conn); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+// ensureMigrationsTable creates schema_migrations on first run
+// Databases created from database/init/create.sql before migrations existed already have
+// the 0001 schema, so they are baselined at version 1 instead of re-running it
+func ensureMigrationsTable(ctx context.Context, conn *pgxpool.Conn) error {
+	var exists bool
+	err := conn.QueryRow(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("query failed: %w", err)
+	}
+	if exists {
+		return nil
+	}
+
+	return pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `CREATE TABLE schema_migrations
+		(
+		  version integer PRIMARY KEY,
+		  name text NOT NULL,
+		  applied_at timestamptz NOT NULL default now()
+		)`)
+		if err != nil {
+			return fmt.Errorf("failed to create schema_migrations: %w", err)
+		}
+
+		var legacySchema bool
+		err = tx.QueryRow(ctx, `SELECT to_regclass('users_table') IS NOT NULL`).Scan(&legacySchema)
+		if err != nil {
+			return fmt.Errorf("query failed: %w", err)
+		}
+		if legacySchema {
+			_, err = tx.Exec(ctx, `INSERT INTO schema_migrations (version, name) VALUES (1, 'create_schema')`)
+			if err != nil {
+				return fmt.Errorf("failed to baseline existing schema: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// appliedMigrations returns applied versions mapped to when they were applied
+func appliedMigrations(ctx context.Context, conn *pgxpool.Conn) (map[int]time.Time, error) {
+	rows, err := conn.Query(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	done := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("row scan failed: %w", err)
+		}
+		done[version] = appliedAt
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration failed: %w", err)
+	}
+
+	return done, nil
+}
+
+// runMigration executes a migration script and its bookkeeping statement in one transaction
+func runMigration(ctx context.Context, conn *pgxpool.Conn, script, bookkeeping string, version int, name string) error {
+	return pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		// no arguments, so pgx uses the simple protocol and the script may hold many statements
+		if _, err := tx.Exec(ctx, script); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, bookkeeping, version, name)
+		return err
+	})
+}
@@ -0,0 +1,185 @@
+package db_test
+
+import (
+	"compound/go-server/internal/db"
+	"compound/go-server/internal/db/dbtest"
+	"compound/go-server/pkg/models"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// migrateTestSchema is the schema the migration tests build, apart from the one dbtest.Open empties
+const migrateTestSchema = "migrate_test"
+
+// openEmptySchema returns a Store whose connections only see a new, empty schema
+// The schema is dropped when the test ends
+func openEmptySchema(t *testing.T) *db.Store {
+	t.Helper()
+
+	databaseURL := dbtest.URL(t)
+	dbtest.Exec(t, `DROP SCHEMA IF EXISTS `+migrateTestSchema+` CASCADE`)
+	dbtest.Exec(t, `CREATE SCHEMA `+migrateTestSchema)
+	t.Cleanup(func() { dbtest.Exec(t, `DROP SCHEMA IF EXISTS `+migrateTestSchema+` CASCADE`) })
+
+	// unknown URL parameters are sent as run-time settings of every connection
+	sep := "?"
+	if strings.Contains(databaseURL, "?") {
+		sep = "&"
+	}
+	store, err := db.NewStore(context.Background(), db.Config{
+		DatabaseURL: databaseURL + sep + "search_path=" + migrateTestSchema,
+		Keyring:     dbtest.Keyring(t),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(store.Close)
+
+	return store
+}
+
+// allVersions returns the version of every embedded migration, oldest first
+func allVersions(t *testing.T) []int {
+	t.Helper()
+
+	migrations, err := db.LoadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var versions []int
+	for _, m := range migrations {
+		versions = append(versions, m.Version)
+	}
+	return versions
+}
+
+// assertApplied fails the test unless exactly the migrations in want are recorded as applied
+func assertApplied(t *testing.T, store *db.Store, want []int) {
+	t.Helper()
+
+	statuses, err := store.MigrationStatuses(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var applied []int
+	for _, status := range statuses {
+		if status.AppliedAt != nil {
+			applied = append(applied, status.Version)
+		}
+	}
+	if fmt.Sprint(applied) != fmt.Sprint(want) {
+		t.Fatalf("applied migrations = %v, want %v", applied, want)
+	}
+}
+
+// assertSchemaWorks fails the test unless users can be stored in the migrated schema
+func assertSchemaWorks(t *testing.T, store *db.Store) {
+	t.Helper()
+
+	user, err := store.CreateUser(context.Background(), "alice", "not-a-real-hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GetUserByID(context.Background(), user.ID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrateUpFromEmptySchema(t *testing.T) {
+	store := openEmptySchema(t)
+	ctx := context.Background()
+	versions := allVersions(t)
+
+	applied, err := store.MigrateUp(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(applied) != fmt.Sprint(versions) {
+		t.Fatalf("applied %v, want %v", applied, versions)
+	}
+	assertApplied(t, store, versions)
+	assertSchemaWorks(t, store)
+
+	applied, err = store.MigrateUp(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 0 {
+		t.Fatalf("second run applied %v, want nothing", applied)
+	}
+}
+
+// TestMigrateBaselinesCreateSQLSchema checks a database built by database/init/create.sql, which
+// is the 0001 script, is recorded as already at version 1 rather than having it re-run
+func TestMigrateBaselinesCreateSQLSchema(t *testing.T) {
+	store := openEmptySchema(t)
+	ctx := context.Background()
+	versions := allVersions(t)
+
+	migrations, err := db.LoadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbtest.Exec(t, `SET search_path TO `+migrateTestSchema+`;`+migrations[0].Up)
+
+	applied, err := store.MigrateUp(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(applied) != fmt.Sprint(versions[1:]) {
+		t.Fatalf("applied %v, want %v", applied, versions[1:])
+	}
+	assertApplied(t, store, versions)
+	assertSchemaWorks(t, store)
+}
+
+// TestMigrateDownUpRoundTrip checks every down script reverts its up script, so the whole schema
+// can be dropped and rebuilt
+func TestMigrateDownUpRoundTrip(t *testing.T) {
+	store := openEmptySchema(t)
+	ctx := context.Background()
+	versions := allVersions(t)
+
+	if _, err := store.MigrateUp(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// repeats of a Link request ID have to be dropped to put its unique constraint back
+	requestID := "req-1"
+	for _, eventType := range []string{"ERROR", "exit"} {
+		if err := store.CreateLinkEvent(ctx, &models.LinkEvent{Type: eventType, RequestID: &requestID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reverted, err := store.MigrateDown(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := versions[len(versions)-1:]; fmt.Sprint(reverted) != fmt.Sprint(want) {
+		t.Fatalf("reverted %v, want %v", reverted, want)
+	}
+	assertApplied(t, store, versions[:len(versions)-1])
+
+	reverted, err = store.MigrateDown(ctx, len(versions))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reverted) != len(versions)-1 || reverted[len(reverted)-1] != versions[0] {
+		t.Fatalf("reverted %v, want every remaining migration newest first", reverted)
+	}
+	assertApplied(t, store, nil)
+
+	applied, err := store.MigrateUp(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(applied) != fmt.Sprint(versions) {
+		t.Fatalf("applied %v after reverting, want %v", applied, versions)
+	}
+	assertSchemaWorks(t, store)
+}
@@ -0,0 +1,22 @@
+-- Reverts 0001_create_schema: drops every table, view and trigger function it created,
+-- in reverse dependency order.
+
+DROP TABLE IF EXISTS plaid_api_events_table;
+DROP TABLE IF EXISTS link_events_table;
+
+DROP VIEW IF EXISTS transactions;
+DROP TABLE IF EXISTS transactions_table;
+
+DROP VIEW IF EXISTS accounts;
+DROP TABLE IF EXISTS accounts_table;
+
+DROP VIEW IF EXISTS assets;
+DROP TABLE IF EXISTS assets_table;
+
+DROP VIEW IF EXISTS items;
+DROP TABLE IF EXISTS items_table;
+
+DROP VIEW IF EXISTS users;
+DROP TABLE IF EXISTS users_table;
+
+DROP FUNCTION IF EXISTS trigger_set_timestamp();
@@ -0,0 +1,21 @@
+-- Access tokens are stored envelope-encrypted. plaid_access_token_key_id names the master key
+-- that wraps each token's data key; NULL marks a legacy plaintext token that has not been
+-- encrypted yet (run `server rotate-keys` to encrypt them).
+
+ALTER TABLE items_table ADD COLUMN plaid_access_token_key_id text;
+
+CREATE OR REPLACE VIEW items
+AS
+  SELECT
+    id,
+    plaid_item_id,
+    user_id,
+    plaid_access_token,
+    plaid_institution_id,
+    status,
+    created_at,
+    updated_at,
+    transactions_cursor,
+    plaid_access_token_key_id
+  FROM
+    items_table;
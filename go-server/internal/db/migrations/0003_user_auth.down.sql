@@ -0,0 +1,17 @@
+-- Views can't drop columns in place, so the users view is rebuilt.
+
+DROP TABLE sessions_table;
+
+DROP VIEW users;
+
+ALTER TABLE users_table DROP COLUMN password_hash;
+
+CREATE VIEW users
+AS
+  SELECT
+    id,
+    username,
+    created_at,
+    updated_at
+  FROM
+    users_table;
@@ -0,0 +1,32 @@
+-- Users log in with a password and get a server-side session. password_hash holds a bcrypt
+-- hash; it is NULL for users created before passwords existed, and those users can't log in.
+
+ALTER TABLE users_table ADD COLUMN password_hash text;
+
+CREATE OR REPLACE VIEW users
+AS
+  SELECT
+    id,
+    username,
+    created_at,
+    updated_at,
+    password_hash
+  FROM
+    users_table;
+
+
+-- SESSIONS
+-- One row per logged in session. Only the SHA-256 of the session token is stored, so a copy of
+-- this table can't be used to log in as anyone.
+
+CREATE TABLE sessions_table
+(
+  id SERIAL PRIMARY KEY,
+  user_id integer NOT NULL REFERENCES users_table(id) ON DELETE CASCADE,
+  token_hash text UNIQUE NOT NULL,
+  expires_at timestamptz NOT NULL,
+  created_at timestamptz default now()
+);
+
+CREATE INDEX sessions_user_id_idx ON sessions_table (user_id);
+CREATE INDEX sessions_expires_at_idx ON sessions_table (expires_at);
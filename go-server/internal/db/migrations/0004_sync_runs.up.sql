@@ -0,0 +1,44 @@
+-- SYNC RUNS
+-- One row per transactions sync of an item, written when the sync starts and completed when it
+-- finishes. A row with no finished_at is still running (or its server died mid-sync); a row with
+-- an error_message failed and changed nothing.
+
+CREATE TABLE sync_runs_table
+(
+  id SERIAL PRIMARY KEY,
+  item_id integer NOT NULL REFERENCES items_table(id) ON DELETE CASCADE,
+  trigger text NOT NULL,
+  started_at timestamptz NOT NULL default now(),
+  finished_at timestamptz,
+  pages integer NOT NULL default 0,
+  added_count integer NOT NULL default 0,
+  modified_count integer NOT NULL default 0,
+  removed_count integer NOT NULL default 0,
+  cursor_before text,
+  cursor_after text,
+  error_code text,
+  error_message text
+);
+
+CREATE INDEX sync_runs_item_id_started_at_idx ON sync_runs_table (item_id, started_at DESC);
+
+-- last_synced_at is when the item last finished a successful sync, set with its cursor.
+
+ALTER TABLE items_table ADD COLUMN last_synced_at timestamptz;
+
+CREATE OR REPLACE VIEW items
+AS
+  SELECT
+    id,
+    plaid_item_id,
+    user_id,
+    plaid_access_token,
+    plaid_institution_id,
+    status,
+    created_at,
+    updated_at,
+    transactions_cursor,
+    plaid_access_token_key_id,
+    last_synced_at
+  FROM
+    items_table;
@@ -0,0 +1,21 @@
+-- Views can't drop columns in place, so the users view is rebuilt.
+
+DROP VIEW users;
+
+ALTER TABLE users_table DROP COLUMN is_admin;
+
+CREATE VIEW users
+AS
+  SELECT
+    id,
+    username,
+    created_at,
+    updated_at,
+    password_hash
+  FROM
+    users_table;
+
+DROP INDEX plaid_api_events_item_id_created_at_idx;
+
+ALTER TABLE plaid_api_events_table DROP COLUMN error_message;
+ALTER TABLE plaid_api_events_table DROP COLUMN duration_ms;
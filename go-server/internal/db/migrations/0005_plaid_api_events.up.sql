@@ -0,0 +1,25 @@
+-- Every call the Go server makes to the Plaid API is recorded in plaid_api_events_table (see
+-- internal/plaid). These columns add what the Node server didn't record: how long the call took
+-- and Plaid's error message.
+
+ALTER TABLE plaid_api_events_table ADD COLUMN duration_ms integer;
+ALTER TABLE plaid_api_events_table ADD COLUMN error_message text;
+
+CREATE INDEX plaid_api_events_item_id_created_at_idx ON plaid_api_events_table (item_id, created_at DESC);
+
+-- Admins can read other users' Plaid API events to debug failing items. There is no endpoint to
+-- grant it, set it by hand: UPDATE users_table SET is_admin = true WHERE username = '...';
+
+ALTER TABLE users_table ADD COLUMN is_admin boolean NOT NULL default false;
+
+CREATE OR REPLACE VIEW users
+AS
+  SELECT
+    id,
+    username,
+    created_at,
+    updated_at,
+    password_hash,
+    is_admin
+  FROM
+    users_table;
@@ -0,0 +1,2 @@
+DROP INDEX link_events_link_session_id_idx;
+DROP INDEX link_events_user_id_created_at_idx;
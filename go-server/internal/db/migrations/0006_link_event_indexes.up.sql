@@ -0,0 +1,4 @@
+-- Link events are looked up by user and by Link session when diagnosing failed connections.
+
+CREATE INDEX link_events_user_id_created_at_idx ON link_events_table (user_id, created_at DESC);
+CREATE INDEX link_events_link_session_id_idx ON link_events_table (link_session_id);
@@ -0,0 +1,17 @@
+-- ITEM REMOVALS
+-- One row per item a user removed. The item, its accounts and its transactions are deleted, so
+-- this keeps what was removed, by whom and whether Plaid still knew about it. item_id has no
+-- foreign key, the item row is gone by the time this is read.
+
+CREATE TABLE item_removals_table
+(
+  id SERIAL PRIMARY KEY,
+  item_id integer NOT NULL,
+  user_id integer NOT NULL,
+  plaid_item_id text NOT NULL,
+  plaid_institution_id text,
+  plaid_already_removed boolean NOT NULL default false,
+  removed_at timestamptz NOT NULL default now()
+);
+
+CREATE INDEX item_removals_user_id_removed_at_idx ON item_removals_table (user_id, removed_at DESC);
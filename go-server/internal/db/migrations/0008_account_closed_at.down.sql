@@ -0,0 +1,54 @@
+-- Views can't drop columns in place, so the views depending on accounts are rebuilt.
+
+DROP VIEW transactions;
+DROP VIEW accounts;
+
+ALTER TABLE accounts_table DROP COLUMN closed_at;
+
+CREATE VIEW accounts
+AS
+  SELECT
+    a.id,
+    a.plaid_account_id,
+    a.item_id,
+    i.plaid_item_id,
+    i.user_id,
+    a.name,
+    a.mask,
+    a.official_name,
+    a.current_balance,
+    a.available_balance,
+    a.iso_currency_code,
+    a.unofficial_currency_code,
+    a.type,
+    a.subtype,
+    a.created_at,
+    a.updated_at
+  FROM
+    accounts_table a
+    LEFT JOIN items i ON i.id = a.item_id;
+
+CREATE VIEW transactions
+AS
+  SELECT
+    t.id,
+    t.plaid_transaction_id,
+    t.account_id,
+    a.plaid_account_id,
+    a.item_id,
+    a.plaid_item_id,
+    a.user_id,
+    t.category,
+    t.type,
+    t.name,
+    t.amount,
+    t.iso_currency_code,
+    t.unofficial_currency_code,
+    t.date,
+    t.pending,
+    t.account_owner,
+    t.created_at,
+    t.updated_at
+  FROM
+    transactions_table t
+    LEFT JOIN accounts a ON t.account_id = a.id;
@@ -0,0 +1,28 @@
+-- closed_at is set when an account stops coming back from Plaid, e.g. the user un-shared it in
+-- Link update mode. Closed accounts keep their transactions; an account that comes back reopens.
+
+ALTER TABLE accounts_table ADD COLUMN closed_at timestamptz;
+
+CREATE OR REPLACE VIEW accounts
+AS
+  SELECT
+    a.id,
+    a.plaid_account_id,
+    a.item_id,
+    i.plaid_item_id,
+    i.user_id,
+    a.name,
+    a.mask,
+    a.official_name,
+    a.current_balance,
+    a.available_balance,
+    a.iso_currency_code,
+    a.unofficial_currency_code,
+    a.type,
+    a.subtype,
+    a.created_at,
+    a.updated_at,
+    a.closed_at
+  FROM
+    accounts_table a
+    LEFT JOIN items i ON i.id = a.item_id;
@@ -0,0 +1,23 @@
+-- new_accounts_available is set by the NEW_ACCOUNTS_AVAILABLE webhook, when the user opened an
+-- account at the institution that isn't shared with us yet. It's cleared once the user finishes
+-- Link update mode with account selection.
+
+ALTER TABLE items_table ADD COLUMN new_accounts_available boolean NOT NULL default false;
+
+CREATE OR REPLACE VIEW items
+AS
+  SELECT
+    id,
+    plaid_item_id,
+    user_id,
+    plaid_access_token,
+    plaid_institution_id,
+    status,
+    created_at,
+    updated_at,
+    transactions_cursor,
+    plaid_access_token_key_id,
+    last_synced_at,
+    new_accounts_available
+  FROM
+    items_table;
@@ -0,0 +1 @@
+DROP TABLE account_balance_snapshots_table;
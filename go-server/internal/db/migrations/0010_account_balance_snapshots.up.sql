@@ -0,0 +1,22 @@
+-- ACCOUNT BALANCE SNAPSHOTS
+-- One row per account per day, holding the last balances fetched from Plaid that day. Balances
+-- are written every time accounts are fetched, so a day's row is overwritten until the day ends.
+
+CREATE TABLE account_balance_snapshots_table
+(
+  id SERIAL PRIMARY KEY,
+  account_id integer NOT NULL REFERENCES accounts_table(id) ON DELETE CASCADE,
+  date date NOT NULL,
+  current_balance numeric(28,10),
+  available_balance numeric(28,10),
+  iso_currency_code text,
+  unofficial_currency_code text,
+  created_at timestamptz default now(),
+  updated_at timestamptz default now(),
+  UNIQUE (account_id, date)
+);
+
+CREATE TRIGGER account_balance_snapshots_updated_at_timestamp
+BEFORE UPDATE ON account_balance_snapshots_table
+FOR EACH ROW
+EXECUTE PROCEDURE trigger_set_timestamp();
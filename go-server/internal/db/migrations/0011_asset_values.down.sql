@@ -0,0 +1,3 @@
+DROP TABLE asset_values_table;
+
+ALTER TABLE assets_table ALTER COLUMN value DROP NOT NULL;
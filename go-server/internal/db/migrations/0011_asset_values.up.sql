@@ -0,0 +1,26 @@
+-- ASSET VALUES
+-- The value history of manual assets, one row per asset per day. assets_table.value always holds
+-- the value of the asset's latest row. Assets created before the history existed start with one
+-- row on the day they were created.
+
+UPDATE assets_table SET value = 0 WHERE value IS NULL;
+ALTER TABLE assets_table ALTER COLUMN value SET NOT NULL;
+
+CREATE TABLE asset_values_table
+(
+  id SERIAL PRIMARY KEY,
+  asset_id integer NOT NULL REFERENCES assets_table(id) ON DELETE CASCADE,
+  date date NOT NULL,
+  value numeric(28,2) NOT NULL,
+  created_at timestamptz default now(),
+  updated_at timestamptz default now(),
+  UNIQUE (asset_id, date)
+);
+
+CREATE TRIGGER asset_values_updated_at_timestamp
+BEFORE UPDATE ON asset_values_table
+FOR EACH ROW
+EXECUTE PROCEDURE trigger_set_timestamp();
+
+INSERT INTO asset_values_table (asset_id, date, value)
+SELECT id, created_at::date, value FROM assets_table;
@@ -0,0 +1,38 @@
+-- Views can't drop columns in place, so the transactions view is rebuilt.
+
+DROP VIEW transactions;
+
+DROP TRIGGER transactions_search_vector ON transactions_table;
+DROP FUNCTION trigger_set_transaction_search_vector();
+
+ALTER TABLE transactions_table
+  DROP COLUMN search_vector,
+  DROP COLUMN notes,
+  DROP COLUMN merchant_name;
+
+DROP FUNCTION transaction_search_vector(text, text, text);
+
+CREATE VIEW transactions
+AS
+  SELECT
+    t.id,
+    t.plaid_transaction_id,
+    t.account_id,
+    a.plaid_account_id,
+    a.item_id,
+    a.plaid_item_id,
+    a.user_id,
+    t.category,
+    t.type,
+    t.name,
+    t.amount,
+    t.iso_currency_code,
+    t.unofficial_currency_code,
+    t.date,
+    t.pending,
+    t.account_owner,
+    t.created_at,
+    t.updated_at
+  FROM
+    transactions_table t
+    LEFT JOIN accounts a ON t.account_id = a.id;
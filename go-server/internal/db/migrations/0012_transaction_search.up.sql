@@ -0,0 +1,66 @@
+-- TRANSACTION SEARCH
+-- merchant_name comes from Plaid, notes are written by the user. search_vector indexes both along
+-- with the name for full-text search; name and merchant name weigh more than notes.
+--
+-- search_vector is kept up to date by a trigger rather than being a generated column, which the
+-- PostgreSQL 11 database in docker-compose.yml doesn't support. transaction_search_vector is
+-- immutable so the column can become GENERATED ALWAYS AS (transaction_search_vector(...)) later.
+
+ALTER TABLE transactions_table
+  ADD COLUMN merchant_name text,
+  ADD COLUMN notes text,
+  ADD COLUMN search_vector tsvector;
+
+CREATE FUNCTION transaction_search_vector(name text, merchant_name text, notes text)
+RETURNS tsvector AS $$
+  SELECT setweight(to_tsvector('english'::regconfig, coalesce(name, '')), 'A') ||
+         setweight(to_tsvector('english'::regconfig, coalesce(merchant_name, '')), 'A') ||
+         setweight(to_tsvector('english'::regconfig, coalesce(notes, '')), 'B');
+$$ LANGUAGE sql IMMUTABLE;
+
+CREATE OR REPLACE FUNCTION trigger_set_transaction_search_vector()
+RETURNS TRIGGER AS $$
+BEGIN
+  NEW.search_vector = transaction_search_vector(NEW.name, NEW.merchant_name, NEW.notes);
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER transactions_search_vector
+BEFORE INSERT OR UPDATE OF name, merchant_name, notes ON transactions_table
+FOR EACH ROW
+EXECUTE PROCEDURE trigger_set_transaction_search_vector();
+
+-- backfill without touching updated_at
+ALTER TABLE transactions_table DISABLE TRIGGER transactions_updated_at_timestamp;
+UPDATE transactions_table SET search_vector = transaction_search_vector(name, merchant_name, notes);
+ALTER TABLE transactions_table ENABLE TRIGGER transactions_updated_at_timestamp;
+
+CREATE INDEX transactions_search_vector_idx ON transactions_table USING GIN (search_vector);
+
+CREATE OR REPLACE VIEW transactions
+AS
+  SELECT
+    t.id,
+    t.plaid_transaction_id,
+    t.account_id,
+    a.plaid_account_id,
+    a.item_id,
+    a.plaid_item_id,
+    a.user_id,
+    t.category,
+    t.type,
+    t.name,
+    t.amount,
+    t.iso_currency_code,
+    t.unofficial_currency_code,
+    t.date,
+    t.pending,
+    t.account_owner,
+    t.created_at,
+    t.updated_at,
+    t.merchant_name,
+    t.notes
+  FROM
+    transactions_table t
+    LEFT JOIN accounts a ON t.account_id = a.id;
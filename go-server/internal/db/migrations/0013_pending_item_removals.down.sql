@@ -0,0 +1,6 @@
+DROP INDEX item_removals_pending_idx;
+
+ALTER TABLE item_removals_table
+  DROP COLUMN plaid_removed_at,
+  DROP COLUMN plaid_access_token_key_id,
+  DROP COLUMN plaid_access_token;
@@ -0,0 +1,15 @@
+-- PENDING ITEM REMOVALS
+-- A replaced item is deleted here before it's removed from Plaid, so a failure part way through
+-- never leaves the user with neither item. Until Plaid confirms the removal the item's access
+-- token is kept here, encrypted like items_table's, so the removal can be retried.
+-- plaid_removed_at is NULL while the removal is pending.
+
+ALTER TABLE item_removals_table
+  ADD COLUMN plaid_access_token text,
+  ADD COLUMN plaid_access_token_key_id text,
+  ADD COLUMN plaid_removed_at timestamptz;
+
+-- every removal so far was made at Plaid before the item was deleted
+UPDATE item_removals_table SET plaid_removed_at = removed_at;
+
+CREATE INDEX item_removals_pending_idx ON item_removals_table (id) WHERE plaid_removed_at IS NULL;
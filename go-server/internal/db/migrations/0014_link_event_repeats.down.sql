@@ -0,0 +1,6 @@
+-- keep only the first event of each request, the constraint can't be added back over repeats
+DELETE FROM link_events_table e
+USING link_events_table first
+WHERE e.request_id = first.request_id AND e.id > first.id;
+
+ALTER TABLE link_events_table ADD CONSTRAINT link_events_table_request_id_key UNIQUE (request_id);
@@ -0,0 +1,5 @@
+-- LINK EVENT REPEATS
+-- Link sends several callbacks for one request (an onEvent ERROR then the onExit, say) and they
+-- share its request ID. Each callback is worth keeping, so request_id is no longer unique.
+
+ALTER TABLE link_events_table DROP CONSTRAINT link_events_table_request_id_key;
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"compound/go-server/pkg/models"
+	"context"
+	"fmt"
+)
+
+// CreatePlaidAPIEvent records one call to the Plaid API
+// event.ID and event.CreatedAt are filled in from the new row
+func (s *Store) CreatePlaidAPIEvent(ctx context.Context, event *models.PlaidAPIEvent) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `INSERT INTO plaid_api_events_table
+	            (item_id, user_id, plaid_method, arguments, request_id, error_type, error_code, error_message, duration_ms, created_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW())
+	          RETURNING id, created_at`
+
+	err := s.q.QueryRow(ctx, query,
+		event.ItemID,
+		event.UserID,
+		event.PlaidMethod,
+		event.Arguments,
+		event.RequestID,
+		event.ErrorType,
+		event.ErrorCode,
+		event.ErrorMessage,
+		event.DurationMs,
+	).Scan(&event.ID, &event.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("query failed: %w", err)
+	}
+
+	return nil
+}
+
+// GetFailedPlaidAPIEventsByItemID retrieves an item's most recent failed Plaid API calls, newest first
+func (s *Store) GetFailedPlaidAPIEventsByItemID(ctx context.Context, itemID int, limit int) ([]*models.PlaidAPIEvent, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT id, item_id, user_id, plaid_method, arguments, request_id, error_type, error_code, error_message, duration_ms, created_at
+	          FROM plaid_api_events_table
+	          WHERE item_id=$1 AND (error_code IS NOT NULL OR error_message IS NOT NULL)
+	          ORDER BY created_at DESC, id DESC
+	          LIMIT $2`
+
+	rows, err := s.q.Query(ctx, query, itemID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*models.PlaidAPIEvent
+	for rows.Next() {
+		event := &models.PlaidAPIEvent{}
+		err := rows.Scan(
+			&event.ID,
+			&event.ItemID,
+			&event.UserID,
+			&event.PlaidMethod,
+			&event.Arguments,
+			&event.RequestID,
+			&event.ErrorType,
+			&event.ErrorCode,
+			&event.ErrorMessage,
+			&event.DurationMs,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("row scan failed: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration failed: %w", err)
+	}
+
+	return events, nil
+}
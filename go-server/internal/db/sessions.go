@@ -0,0 +1,90 @@
+package db
+
+import (
+	"compound/go-server/pkg/models"
+	"context"
+	"fmt"
+	"time"
+)
+
+// CreateSession stores a new session for a user
+// tokenHash is the hash of the session token, the token itself is never stored
+func (s *Store) CreateSession(ctx context.Context, userID int, tokenHash string, expiresAt time.Time) (*models.Session, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `INSERT INTO sessions_table (user_id, token_hash, expires_at, created_at)
+				VALUES ($1, $2, $3, NOW())
+				RETURNING id, user_id, token_hash, expires_at, created_at`
+
+	session := &models.Session{}
+	err := s.q.QueryRow(ctx, query, userID, tokenHash, expiresAt).Scan(
+		&session.ID,
+		&session.UserID,
+		&session.TokenHash,
+		&session.ExpiresAt,
+		&session.CreatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+
+	return session, nil
+}
+
+// GetUserBySessionTokenHash retrieves the user owning an unexpired session
+// Returns pgx.ErrNoRows (wrapped) if the session doesn't exist or has expired
+func (s *Store) GetUserBySessionTokenHash(ctx context.Context, tokenHash string) (*models.User, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT u.id, u.username, u.created_at, u.updated_at, u.password_hash, u.is_admin
+				FROM sessions_table s
+				JOIN users u ON u.id = s.user_id
+				WHERE s.token_hash=$1 AND s.expires_at > NOW()`
+
+	user := &models.User{}
+	err := s.q.QueryRow(ctx, query, tokenHash).Scan(
+		&user.ID,
+		&user.Username,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&user.PasswordHash,
+		&user.IsAdmin,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+
+	return user, nil
+}
+
+// DeleteSession deletes a session by its token hash
+// Deleting a session that doesn't exist is not an error, so logging out twice is harmless
+func (s *Store) DeleteSession(ctx context.Context, tokenHash string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := "DELETE FROM sessions_table WHERE token_hash=$1"
+
+	if _, err := s.q.Exec(ctx, query, tokenHash); err != nil {
+		return fmt.Errorf("query failed: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteExpiredSessions deletes every expired session and returns how many were removed
+func (s *Store) DeleteExpiredSessions(ctx context.Context) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := "DELETE FROM sessions_table WHERE expires_at <= NOW()"
+
+	result, err := s.q.Exec(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("query failed: %w", err)
+	}
+
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,115 @@
+package db
+
+import (
+	"compound/go-server/pkg/models"
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// syncRunColumns is selected by every sync run query, in the order scanSyncRun expects
+const syncRunColumns = `id, item_id, trigger, started_at, finished_at, pages, added_count, modified_count,
+	                    removed_count, cursor_before, cursor_after, error_code, error_message`
+
+// scanSyncRun scans a row selected with syncRunColumns
+func scanSyncRun(row pgx.Row) (*models.SyncRun, error) {
+	run := &models.SyncRun{}
+	err := row.Scan(
+		&run.ID,
+		&run.ItemID,
+		&run.Trigger,
+		&run.StartedAt,
+		&run.FinishedAt,
+		&run.Pages,
+		&run.AddedCount,
+		&run.ModifiedCount,
+		&run.RemovedCount,
+		&run.CursorBefore,
+		&run.CursorAfter,
+		&run.ErrorCode,
+		&run.ErrorMessage,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// CreateSyncRun records the start of a sync of an item from cursorBefore
+func (s *Store) CreateSyncRun(ctx context.Context, itemID int, trigger string, cursorBefore *string) (*models.SyncRun, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `INSERT INTO sync_runs_table (item_id, trigger, cursor_before, started_at)
+	          VALUES ($1, $2, $3, NOW())
+	          RETURNING ` + syncRunColumns
+
+	run, err := scanSyncRun(s.q.QueryRow(ctx, query, itemID, trigger, cursorBefore))
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+
+	return run, nil
+}
+
+// FinishSyncRun records the outcome of a sync started with CreateSyncRun
+// The counts, cursor and error fields of run are written and finished_at is set to now
+func (s *Store) FinishSyncRun(ctx context.Context, run *models.SyncRun) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE sync_runs_table
+	          SET finished_at=NOW(), pages=$1, added_count=$2, modified_count=$3, removed_count=$4,
+	              cursor_after=$5, error_code=$6, error_message=$7
+	          WHERE id=$8
+	          RETURNING finished_at`
+
+	err := s.q.QueryRow(ctx, query,
+		run.Pages,
+		run.AddedCount,
+		run.ModifiedCount,
+		run.RemovedCount,
+		run.CursorAfter,
+		run.ErrorCode,
+		run.ErrorMessage,
+		run.ID,
+	).Scan(&run.FinishedAt)
+	if err != nil {
+		return fmt.Errorf("query failed: %w", err)
+	}
+
+	return nil
+}
+
+// GetSyncRunsByItemID retrieves the most recent sync runs of an item, newest first
+func (s *Store) GetSyncRunsByItemID(ctx context.Context, itemID int, limit int) ([]*models.SyncRun, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + syncRunColumns + `
+	          FROM sync_runs_table WHERE item_id=$1
+	          ORDER BY started_at DESC, id DESC
+	          LIMIT $2`
+
+	rows, err := s.q.Query(ctx, query, itemID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []*models.SyncRun
+	for rows.Next() {
+		run, err := scanSyncRun(rows)
+		if err != nil {
+			return nil, fmt.Errorf("row scan failed: %w", err)
+		}
+		runs = append(runs, run)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration failed: %w", err)
+	}
+
+	return runs, nil
+}
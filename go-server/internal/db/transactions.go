@@ -4,31 +4,71 @@ import (
 	"compound/go-server/pkg/models"
 	"context"
 	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jackc/pgx/v5"
 )
 
-// CreateOrUpdateTransaction creates or updates a transaction in the database
-func CreateOrUpdateTransaction(ctx context.Context, accountID int, plaidTransactionID string, categoryData interface{}, txType, name string, amount float64, isoCurrencyCode, unofficialCurrencyCode string, date string, pending bool, accountOwner *string) (*models.Transaction, error) {
-	query := `INSERT INTO transactions_table (account_id, plaid_transaction_id, category_data, type, name, amount, iso_currency_code, unofficial_currency_code, date, pending, account_owner, created_at, updated_at)
-	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NOW(), NOW())
-	          ON CONFLICT (plaid_transaction_id) DO UPDATE SET
-	            type = EXCLUDED.type,
-	            name = EXCLUDED.name,
-	            amount = EXCLUDED.amount,
-	            category_data = EXCLUDED.category_data,
-	            iso_currency_code = EXCLUDED.iso_currency_code,
-	            unofficial_currency_code = EXCLUDED.unofficial_currency_code,
-	            pending = EXCLUDED.pending,
-	            account_owner = EXCLUDED.account_owner,
-	            updated_at = NOW()
-	          RETURNING id, account_id, plaid_transaction_id, type, name, amount, iso_currency_code, unofficial_currency_code, date, pending, account_owner, created_at, updated_at`
+// transactionColumns is selected by every transaction query, in the order scanTransaction expects
+// Queries alias transactions_table as t
+const transactionColumns = `t.id, t.account_id, t.plaid_transaction_id, t.plaid_category_id, t.category, t.type, t.name,
+	                        t.merchant_name, t.notes, t.amount, t.iso_currency_code, t.unofficial_currency_code, t.date,
+	                        t.pending, t.account_owner, t.created_at, t.updated_at`
+
+// Transaction sort orders for GetTransactions, ties are broken by ID in the same direction
+const (
+	TransactionSortDateDesc   = "date_desc"
+	TransactionSortDateAsc    = "date_asc"
+	TransactionSortAmountDesc = "amount_desc"
+	TransactionSortAmountAsc  = "amount_asc"
+)
 
+// TransactionFilter narrows GetTransactions and CountTransactions to one user's transactions
+// Zero fields other than UserID don't filter. Dates are inclusive, as are amounts, which are
+// compared signed (Plaid reports money leaving an account as positive).
+type TransactionFilter struct {
+	UserID     int
+	AccountIDs []int
+	ItemIDs    []int
+	StartDate  *time.Time
+	EndDate    *time.Time
+	MinAmount  *models.Money
+	MaxAmount  *models.Money
+	Pending    *bool
+	// Category matches the Plaid personal finance category (primary or detailed) or any legacy category
+	Category string
+	// Name matches transactions whose name contains it, ignoring case
+	Name string
+}
+
+// TransactionCursor is the position of the last transaction of a page
+// Only the field the sort order uses is read, along with ID
+type TransactionCursor struct {
+	Date   time.Time
+	Amount models.Money
+	ID     int
+}
+
+// likeEscaper escapes the LIKE wildcards in a user's search text
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// scanTransaction scans a row selected with transactionColumns
+func scanTransaction(row pgx.Row) (*models.Transaction, error) {
 	transaction := &models.Transaction{}
-	err := conn.QueryRow(ctx, query, accountID, plaidTransactionID, categoryData, txType, name, amount, isoCurrencyCode, unofficialCurrencyCode, date, pending, accountOwner).Scan(
+	err := row.Scan(
 		&transaction.ID,
 		&transaction.AccountID,
 		&transaction.PlaidTransactionID,
+		&transaction.PlaidCategoryID,
+		&transaction.Category,
 		&transaction.Type,
 		&transaction.Name,
+		&transaction.MerchantName,
+		&transaction.Notes,
 		&transaction.Amount,
 		&transaction.IsoCurrencyCode,
 		&transaction.UnofficialCurrencyCode,
@@ -38,7 +78,37 @@ func CreateOrUpdateTransaction(ctx context.Context, accountID int, plaidTransact
 		&transaction.CreatedAt,
 		&transaction.UpdatedAt,
 	)
+	if err != nil {
+		return nil, err
+	}
+	return transaction, nil
+}
 
+// CreateOrUpdateTransaction creates or updates a transaction in the database
+// A modified transaction can move to another date or account when it settles, so both are
+// updated too. The user's notes are never overwritten
+func (s *Store) CreateOrUpdateTransaction(ctx context.Context, accountID int, plaidTransactionID string, categoryData interface{}, txType, name string, merchantName *string, amount models.Money, isoCurrencyCode, unofficialCurrencyCode string, date string, pending bool, accountOwner *string) (*models.Transaction, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `INSERT INTO transactions_table AS t (account_id, plaid_transaction_id, category_data, type, name, merchant_name, amount, iso_currency_code, unofficial_currency_code, date, pending, account_owner, created_at, updated_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, NOW(), NOW())
+	          ON CONFLICT (plaid_transaction_id) DO UPDATE SET
+	            account_id = EXCLUDED.account_id,
+	            date = EXCLUDED.date,
+	            type = EXCLUDED.type,
+	            name = EXCLUDED.name,
+	            merchant_name = EXCLUDED.merchant_name,
+	            amount = EXCLUDED.amount,
+	            category_data = EXCLUDED.category_data,
+	            iso_currency_code = EXCLUDED.iso_currency_code,
+	            unofficial_currency_code = EXCLUDED.unofficial_currency_code,
+	            pending = EXCLUDED.pending,
+	            account_owner = EXCLUDED.account_owner,
+	            updated_at = NOW()
+	          RETURNING ` + transactionColumns
+
+	transaction, err := scanTransaction(s.q.QueryRow(ctx, query, accountID, plaidTransactionID, categoryData, txType, name, merchantName, amount, isoCurrencyCode, unofficialCurrencyCode, date, pending, accountOwner))
 	if err != nil {
 		return nil, fmt.Errorf("query failed: %w", err)
 	}
@@ -47,19 +117,249 @@ func CreateOrUpdateTransaction(ctx context.Context, accountID int, plaidTransact
 }
 
 // GetTransactionsByAccountID retrieves all transactions for a specific account
-func GetTransactionsByAccountID(ctx context.Context, accountID int) ([]*models.Transaction, error) {
-	query := `SELECT id, account_id, plaid_transaction_id, plaid_category_id, category, type, name, amount, iso_currency_code, unofficial_currency_code, date, pending, account_owner, created_at, updated_at
-	          FROM transactions_table WHERE account_id=$1`
+func (s *Store) GetTransactionsByAccountID(ctx context.Context, accountID int) ([]*models.Transaction, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + transactionColumns + `
+	          FROM transactions_table t WHERE t.account_id=$1`
+
+	return s.queryTransactions(ctx, query, accountID)
+}
+
+// GetTransactionByID retrieves a single transaction by ID
+func (s *Store) GetTransactionByID(ctx context.Context, transactionID int) (*models.Transaction, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 
-	rows, err := conn.Query(ctx, query, accountID)
+	query := `SELECT ` + transactionColumns + `
+	          FROM transactions_table t WHERE t.id=$1`
+
+	transaction, err := scanTransaction(s.q.QueryRow(ctx, query, transactionID))
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+
+	return transaction, nil
+}
+
+// UpdateTransactionNotes sets the user's notes on a transaction, nil clears them
+func (s *Store) UpdateTransactionNotes(ctx context.Context, transactionID int, notes *string) (*models.Transaction, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE transactions_table AS t SET notes=$1 WHERE t.id=$2
+	          RETURNING ` + transactionColumns
+
+	transaction, err := scanTransaction(s.q.QueryRow(ctx, query, notes, transactionID))
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+
+	return transaction, nil
+}
+
+// GetTransactionOwnerID returns the ID of the user who owns a transaction
+func (s *Store) GetTransactionOwnerID(ctx context.Context, transactionID int) (int, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := "SELECT user_id FROM transactions WHERE id=$1"
+
+	var userID int
+	if err := s.q.QueryRow(ctx, query, transactionID).Scan(&userID); err != nil {
+		return 0, fmt.Errorf("query failed: %w", err)
+	}
+
+	return userID, nil
+}
+
+// GetTransactions retrieves a page of at most limit transactions matching filter in the given sort
+// order, starting after the cursor of the previous page's last transaction (nil for the first page)
+func (s *Store) GetTransactions(ctx context.Context, filter TransactionFilter, sort string, after *TransactionCursor, limit int) ([]*models.Transaction, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	conditions, args := transactionConditions(filter)
+
+	column, direction, err := transactionSortColumn(sort)
+	if err != nil {
+		return nil, err
+	}
+	if after != nil {
+		var key any = after.Date
+		if column == "t.amount" {
+			key = after.Amount
+		}
+		comparison := "<"
+		if direction == "ASC" {
+			comparison = ">"
+		}
+		args = append(args, key, after.ID)
+		conditions = append(conditions, fmt.Sprintf("(%s, t.id) %s ($%d, $%d)", column, comparison, len(args)-1, len(args)))
+	}
+
+	args = append(args, limit)
+	query := `SELECT ` + transactionColumns + `
+	          FROM transactions_table t
+	          JOIN accounts_table a ON t.account_id = a.id
+	          JOIN items_table i ON a.item_id = i.id
+	          WHERE ` + strings.Join(conditions, " AND ") + `
+	          ORDER BY ` + column + " " + direction + ", t.id " + direction + `
+	          LIMIT $` + strconv.Itoa(len(args))
+
+	return s.queryTransactions(ctx, query, args...)
+}
+
+// CountTransactions counts every transaction matching filter
+func (s *Store) CountTransactions(ctx context.Context, filter TransactionFilter) (int, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	conditions, args := transactionConditions(filter)
+	query := `SELECT COUNT(*)
+	          FROM transactions_table t
+	          JOIN accounts_table a ON t.account_id = a.id
+	          JOIN items_table i ON a.item_id = i.id
+	          WHERE ` + strings.Join(conditions, " AND ")
+
+	var count int
+	if err := s.q.QueryRow(ctx, query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("query failed: %w", err)
+	}
+
+	return count, nil
+}
+
+// transactionConditions builds the WHERE conditions for filter and their arguments
+func transactionConditions(filter TransactionFilter) ([]string, []any) {
+	args := []any{filter.UserID}
+	conditions := []string{"i.user_id=$1"}
+	add := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, strings.ReplaceAll(condition, "$?", "$"+strconv.Itoa(len(args))))
+	}
+
+	if len(filter.AccountIDs) > 0 {
+		add("t.account_id = ANY($?)", filter.AccountIDs)
+	}
+	if len(filter.ItemIDs) > 0 {
+		add("a.item_id = ANY($?)", filter.ItemIDs)
+	}
+	if filter.StartDate != nil {
+		add("t.date >= $?", *filter.StartDate)
+	}
+	if filter.EndDate != nil {
+		add("t.date <= $?", *filter.EndDate)
+	}
+	if filter.MinAmount != nil {
+		add("t.amount >= $?", *filter.MinAmount)
+	}
+	if filter.MaxAmount != nil {
+		add("t.amount <= $?", *filter.MaxAmount)
+	}
+	if filter.Pending != nil {
+		add("t.pending = $?", *filter.Pending)
+	}
+	if filter.Category != "" {
+		add(`(t.category = $?::text
+		      OR t.category_data->'personal_finance_category'->>'primary' = $?::text
+		      OR t.category_data->'personal_finance_category'->>'detailed' = $?::text
+		      OR t.category_data->'legacy' ? $?::text)`, filter.Category)
+	}
+	if filter.Name != "" {
+		add(`t.name ILIKE '%' || $? || '%'`, likeEscaper.Replace(filter.Name))
+	}
+
+	return conditions, args
+}
+
+// transactionSortColumn returns the column and direction to order by for a sort order
+func transactionSortColumn(sort string) (string, string, error) {
+	switch sort {
+	case TransactionSortDateDesc:
+		return "t.date", "DESC", nil
+	case TransactionSortDateAsc:
+		return "t.date", "ASC", nil
+	case TransactionSortAmountDesc:
+		return "t.amount", "DESC", nil
+	case TransactionSortAmountAsc:
+		return "t.amount", "ASC", nil
+	}
+	return "", "", fmt.Errorf("invalid transaction sort %q", sort)
+}
+
+// Search result highlighting, SearchTransactions wraps each match in a snippet with these
+const (
+	SearchHighlightStart = "<mark>"
+	SearchHighlightStop  = "</mark>"
+)
+
+// headlineStart and headlineStop delimit matches in ts_headline's output, before it is escaped
+// They're private use characters, which are stripped from the text first so only matches carry them
+const (
+	headlineStart = "\uE000"
+	headlineStop  = "\uE001"
+)
+
+// TransactionSearchResult is a transaction matching a full-text search
+type TransactionSearchResult struct {
+	Transaction *models.Transaction
+	Rank        float64
+	// Snippet is the matching part of the name, merchant name and notes as HTML, the text escaped
+	// and matches wrapped in SearchHighlightStart and SearchHighlightStop
+	Snippet string
+}
+
+// SearchTerms splits a search query into the terms SearchTransactions accepts, runs of letters and digits
+// Apostrophes are dropped rather than split on, so "Macy's" stays one term
+func SearchTerms(query string) []string {
+	query = strings.NewReplacer("'", "", "’", "").Replace(query)
+	return strings.FieldsFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// SearchTransactions full-text searches a user's transactions' names, merchant names and notes
+// for transactions matching every term, each as a prefix, best matches first
+// terms must come from SearchTerms, they're written into the tsquery as they are
+func (s *Store) SearchTransactions(ctx context.Context, userID int, terms []string, limit int) ([]*TransactionSearchResult, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	prefixes := make([]string, len(terms))
+	for i, term := range terms {
+		prefixes[i] = term + ":*"
+	}
+
+	// rank and limit first so snippets, which are slow, are only made for the results
+	query := `SELECT ` + transactionColumns + `, r.rank,
+	                 ts_headline('english', translate(concat_ws(' · ', t.name, t.merchant_name, t.notes), $4, ''), r.query,
+	                             'StartSel=' || $5 || ', StopSel=' || $6 || ', MaxFragments=2')
+	          FROM (
+	            SELECT t.id, t.date, ts_rank(t.search_vector, query) AS rank, query
+	            FROM transactions_table t
+	            JOIN accounts_table a ON t.account_id = a.id
+	            JOIN items_table i ON a.item_id = i.id
+	            CROSS JOIN to_tsquery('english', $2) AS query
+	            WHERE i.user_id=$1 AND t.search_vector @@ query
+	            ORDER BY rank DESC, t.date DESC, t.id DESC
+	            LIMIT $3
+	          ) r
+	          JOIN transactions_table t ON t.id = r.id
+	          ORDER BY r.rank DESC, r.date DESC, r.id DESC`
+
+	rows, err := s.q.Query(ctx, query, userID, strings.Join(prefixes, " & "), limit,
+		headlineStart+headlineStop, headlineStart, headlineStop)
 	if err != nil {
 		return nil, fmt.Errorf("query failed: %w", err)
 	}
 	defer rows.Close()
 
-	var transactions []*models.Transaction
+	var results []*TransactionSearchResult
 	for rows.Next() {
 		transaction := &models.Transaction{}
+		result := &TransactionSearchResult{Transaction: transaction}
 		err := rows.Scan(
 			&transaction.ID,
 			&transaction.AccountID,
@@ -68,6 +368,8 @@ func GetTransactionsByAccountID(ctx context.Context, accountID int) ([]*models.T
 			&transaction.Category,
 			&transaction.Type,
 			&transaction.Name,
+			&transaction.MerchantName,
+			&transaction.Notes,
 			&transaction.Amount,
 			&transaction.IsoCurrencyCode,
 			&transaction.UnofficialCurrencyCode,
@@ -76,61 +378,40 @@ func GetTransactionsByAccountID(ctx context.Context, accountID int) ([]*models.T
 			&transaction.AccountOwner,
 			&transaction.CreatedAt,
 			&transaction.UpdatedAt,
+			&result.Rank,
+			&result.Snippet,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("row scan failed: %w", err)
 		}
-		transactions = append(transactions, transaction)
+		result.Snippet = highlightSnippet(result.Snippet)
+		results = append(results, result)
 	}
 
 	if err = rows.Err(); err != nil {
 		return nil, fmt.Errorf("rows iteration failed: %w", err)
 	}
 
-	return transactions, nil
+	return results, nil
 }
 
-// GetTransactionByID retrieves a single transaction by ID
-func GetTransactionByID(ctx context.Context, transactionID int) (*models.Transaction, error) {
-	query := `SELECT id, account_id, plaid_transaction_id, plaid_category_id, category, type, name, amount, iso_currency_code, unofficial_currency_code, date, pending, account_owner, created_at, updated_at
-	          FROM transactions_table WHERE id=$1`
-
-	transaction := &models.Transaction{}
-	err := conn.QueryRow(ctx, query, transactionID).Scan(
-		&transaction.ID,
-		&transaction.AccountID,
-		&transaction.PlaidTransactionID,
-		&transaction.PlaidCategoryID,
-		&transaction.Category,
-		&transaction.Type,
-		&transaction.Name,
-		&transaction.Amount,
-		&transaction.IsoCurrencyCode,
-		&transaction.UnofficialCurrencyCode,
-		&transaction.Date,
-		&transaction.Pending,
-		&transaction.AccountOwner,
-		&transaction.CreatedAt,
-		&transaction.UpdatedAt,
-	)
-
-	if err != nil {
-		return nil, fmt.Errorf("query failed: %w", err)
+// highlightSnippet converts ts_headline output to HTML, escaping the text and marking each match
+// A note holding markup of its own shows as text, only the matches are highlighted
+func highlightSnippet(headline string) string {
+	var b strings.Builder
+	for i, part := range strings.Split(headline, headlineStart) {
+		if i == 0 {
+			b.WriteString(html.EscapeString(part))
+			continue
+		}
+		match, rest, _ := strings.Cut(part, headlineStop)
+		b.WriteString(SearchHighlightStart + html.EscapeString(match) + SearchHighlightStop + html.EscapeString(rest))
 	}
-
-	return transaction, nil
+	return b.String()
 }
 
-// GetTransactionByUserID retrieves all transactions for a specific user
-func GetTransactionByUserID(ctx context.Context, userID int) ([]*models.Transaction, error) {
-	query := `SELECT t.id, t.account_id, t.plaid_transaction_id, t.plaid_category_id, t.category, t.type, t.name, t.amount, t.iso_currency_code, t.unofficial_currency_code, t.date, t.pending, t.account_owner, t.created_at, t.updated_at
-	          FROM transactions_table t
-	          LEFT JOIN accounts_table a ON t.account_id = a.id
-	          LEFT JOIN items_table i ON a.item_id = i.id
-	          WHERE i.user_id = $1
-	          ORDER BY t.date DESC`
-
-	rows, err := conn.Query(ctx, query, userID)
+func (s *Store) queryTransactions(ctx context.Context, query string, args ...any) ([]*models.Transaction, error) {
+	rows, err := s.q.Query(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("query failed: %w", err)
 	}
@@ -138,24 +419,7 @@ func GetTransactionByUserID(ctx context.Context, userID int) ([]*models.Transact
 
 	var transactions []*models.Transaction
 	for rows.Next() {
-		transaction := &models.Transaction{}
-		err := rows.Scan(
-			&transaction.ID,
-			&transaction.AccountID,
-			&transaction.PlaidTransactionID,
-			&transaction.PlaidCategoryID,
-			&transaction.Category,
-			&transaction.Type,
-			&transaction.Name,
-			&transaction.Amount,
-			&transaction.IsoCurrencyCode,
-			&transaction.UnofficialCurrencyCode,
-			&transaction.Date,
-			&transaction.Pending,
-			&transaction.AccountOwner,
-			&transaction.CreatedAt,
-			&transaction.UpdatedAt,
-		)
+		transaction, err := scanTransaction(rows)
 		if err != nil {
 			return nil, fmt.Errorf("row scan failed: %w", err)
 		}
@@ -170,10 +434,13 @@ func GetTransactionByUserID(ctx context.Context, userID int) ([]*models.Transact
 }
 
 // DeleteTransaction deletes a transaction from the database
-func DeleteTransaction(ctx context.Context, transactionID int) error {
+func (s *Store) DeleteTransaction(ctx context.Context, transactionID int) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	query := `DELETE FROM transactions_table WHERE id=$1`
 
-	result, err := conn.Exec(ctx, query, transactionID)
+	result, err := s.q.Exec(ctx, query, transactionID)
 	if err != nil {
 		return fmt.Errorf("query failed: %w", err)
 	}
@@ -186,10 +453,13 @@ func DeleteTransaction(ctx context.Context, transactionID int) error {
 }
 
 // DeleteTransactionByPlaidID deletes a transaction from the database by its Plaid ID
-func DeleteTransactionByPlaidID(ctx context.Context, plaidTransactionID string) error {
+func (s *Store) DeleteTransactionByPlaidID(ctx context.Context, plaidTransactionID string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	query := `DELETE FROM transactions_table WHERE plaid_transaction_id=$1`
 
-	result, err := conn.Exec(ctx, query, plaidTransactionID)
+	result, err := s.q.Exec(ctx, query, plaidTransactionID)
 	if err != nil {
 		return fmt.Errorf("query failed: %w", err)
 	}
@@ -200,3 +470,22 @@ func DeleteTransactionByPlaidID(ctx context.Context, plaidTransactionID string)
 
 	return nil
 }
+
+// DeleteTransactionsByPlaidIDs deletes the item's transactions matching the given Plaid IDs
+// IDs that were never stored, or belong to another item's accounts, are ignored. Returns the
+// number of rows deleted
+func (s *Store) DeleteTransactionsByPlaidIDs(ctx context.Context, itemID int, plaidTransactionIDs []string) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `DELETE FROM transactions_table
+	          WHERE account_id IN (SELECT id FROM accounts_table WHERE item_id=$1)
+	            AND plaid_transaction_id = ANY($2)`
+
+	result, err := s.q.Exec(ctx, query, itemID, plaidTransactionIDs)
+	if err != nil {
+		return 0, fmt.Errorf("query failed: %w", err)
+	}
+
+	return result.RowsAffected(), nil
+}
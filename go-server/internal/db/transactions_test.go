@@ -0,0 +1,143 @@
+package db
+
+import (
+	"compound/go-server/pkg/models"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTransactionConditions(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)
+	minAmount := models.MustParseMoney("-5")
+	maxAmount := models.MustParseMoney("100.50")
+	pending := true
+
+	tests := []struct {
+		name           string
+		filter         TransactionFilter
+		wantConditions []string
+		wantArgs       []any
+	}{
+		{
+			name:           "user only",
+			filter:         TransactionFilter{UserID: 3},
+			wantConditions: []string{"i.user_id=$1"},
+			wantArgs:       []any{3},
+		},
+		{
+			name: "every filter",
+			filter: TransactionFilter{
+				UserID:     3,
+				AccountIDs: []int{1, 2},
+				ItemIDs:    []int{4},
+				StartDate:  &start,
+				EndDate:    &end,
+				MinAmount:  &minAmount,
+				MaxAmount:  &maxAmount,
+				Pending:    &pending,
+				Category:   "FOOD_AND_DRINK",
+				Name:       "coffee",
+			},
+			wantConditions: []string{
+				"i.user_id=$1",
+				"t.account_id = ANY($2)",
+				"a.item_id = ANY($3)",
+				"t.date >= $4",
+				"t.date <= $5",
+				"t.amount >= $6",
+				"t.amount <= $7",
+				"t.pending = $8",
+				`(t.category = $9::text
+		      OR t.category_data->'personal_finance_category'->>'primary' = $9::text
+		      OR t.category_data->'personal_finance_category'->>'detailed' = $9::text
+		      OR t.category_data->'legacy' ? $9::text)`,
+				`t.name ILIKE '%' || $10 || '%'`,
+			},
+			wantArgs: []any{3, []int{1, 2}, []int{4}, start, end, minAmount, maxAmount, true, "FOOD_AND_DRINK", "coffee"},
+		},
+		{
+			name:   "numbering skips unset filters",
+			filter: TransactionFilter{UserID: 3, EndDate: &end, Pending: &pending, Name: "coffee"},
+			wantConditions: []string{
+				"i.user_id=$1",
+				"t.date <= $2",
+				"t.pending = $3",
+				`t.name ILIKE '%' || $4 || '%'`,
+			},
+			wantArgs: []any{3, end, true, "coffee"},
+		},
+		{
+			name:           "name wildcards are escaped",
+			filter:         TransactionFilter{UserID: 3, Name: `50%_off\`},
+			wantConditions: []string{"i.user_id=$1", `t.name ILIKE '%' || $2 || '%'`},
+			wantArgs:       []any{3, `50\%\_off\\`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conditions, args := transactionConditions(tt.filter)
+			if !reflect.DeepEqual(conditions, tt.wantConditions) {
+				t.Fatalf("conditions = %q, want %q", conditions, tt.wantConditions)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Fatalf("args = %v, want %v", args, tt.wantArgs)
+			}
+			for _, condition := range conditions {
+				if strings.Contains(condition, "$?") {
+					t.Fatalf("condition %q has an unnumbered placeholder", condition)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchTerms(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"amazon", []string{"amazon"}},
+		{"  Whole   Foods ", []string{"Whole", "Foods"}},
+		{"Macy's", []string{"Macys"}},
+		{"Macy’s", []string{"Macys"}},
+		{"7-eleven", []string{"7", "eleven"}},
+		{"café crème", []string{"café", "crème"}},
+		// tsquery syntax splits words instead of reaching to_tsquery
+		{`coffee:* & !tea | (rent) <-> 'x' \ "y"`, []string{"coffee", "tea", "rent", "x", "y"}},
+		{"&|!():*<->", []string{}},
+		{"", []string{}},
+	}
+
+	for _, tt := range tests {
+		if got := SearchTerms(tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SearchTerms(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestHighlightSnippet(t *testing.T) {
+	tests := []struct {
+		headline string
+		want     string
+	}{
+		{"Coffee", "Coffee"},
+		{headlineStart + "Amazon" + headlineStop + " Mktp US · " + headlineStart + "Amazon" + headlineStop,
+			"<mark>Amazon</mark> Mktp US · <mark>Amazon</mark>"},
+		// markup in the text is escaped, only the matches are highlighted
+		{"Groceries · <mark>not a match</mark> " + headlineStart + "cake" + headlineStop,
+			"Groceries · &lt;mark&gt;not a match&lt;/mark&gt; <mark>cake</mark>"},
+		{headlineStart + "AT&T" + headlineStop + ` "bill" <script>`,
+			`<mark>AT&amp;T</mark> &#34;bill&#34; &lt;script&gt;`},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := highlightSnippet(tt.headline); got != tt.want {
+			t.Errorf("highlightSnippet(%q) = %q, want %q", tt.headline, got, tt.want)
+		}
+	}
+}
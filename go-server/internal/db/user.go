@@ -7,15 +7,20 @@ import (
 )
 
 // GetUserByID retrieves a user from the database by ID
-func GetUserByID(ctx context.Context, id int) (*models.User, error) {
-	query := "SELECT id, username, created_at, updated_at FROM users WHERE id=$1"
+func (s *Store) GetUserByID(ctx context.Context, id int) (*models.User, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := "SELECT id, username, created_at, updated_at, password_hash, is_admin FROM users WHERE id=$1"
 
 	user := &models.User{}
-	err := conn.QueryRow(ctx, query, id).Scan(
+	err := s.q.QueryRow(ctx, query, id).Scan(
 		&user.ID,
 		&user.Username,
 		&user.CreatedAt, // time.Time handles TIMESTAMPTZ
 		&user.UpdatedAt,
+		&user.PasswordHash,
+		&user.IsAdmin,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("query failed: %w", err)
@@ -25,15 +30,20 @@ func GetUserByID(ctx context.Context, id int) (*models.User, error) {
 }
 
 // GetUserByUsername retrieves a user from the database by username
-func GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
-	query := "SELECT id, username, created_at, updated_at FROM users WHERE username=$1"
+func (s *Store) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := "SELECT id, username, created_at, updated_at, password_hash, is_admin FROM users WHERE username=$1"
 
 	user := &models.User{}
-	err := conn.QueryRow(ctx, query, username).Scan(
+	err := s.q.QueryRow(ctx, query, username).Scan(
 		&user.ID,
 		&user.Username,
 		&user.CreatedAt, // time.Time handles TIMESTAMPTZ
 		&user.UpdatedAt,
+		&user.PasswordHash,
+		&user.IsAdmin,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("query failed: %w", err)
@@ -43,17 +53,23 @@ func GetUserByUsername(ctx context.Context, username string) (*models.User, erro
 }
 
 // CreateUser creates a new user in the database
-func CreateUser(ctx context.Context, username string) (*models.User, error) {
-	query := `INSERT INTO users (username, created_at, updated_at)
-				VALUES ($1, NOW(), NOW())
-				RETURNING id, username, created_at, updated_at`
+// passwordHash must already be hashed, see auth.HashPassword
+func (s *Store) CreateUser(ctx context.Context, username string, passwordHash string) (*models.User, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `INSERT INTO users (username, password_hash, created_at, updated_at)
+				VALUES ($1, $2, NOW(), NOW())
+				RETURNING id, username, created_at, updated_at, password_hash, is_admin`
 
 	user := &models.User{}
-	err := conn.QueryRow(ctx, query, username).Scan(
+	err := s.q.QueryRow(ctx, query, username, passwordHash).Scan(
 		&user.ID,
 		&user.Username,
 		&user.CreatedAt, // time.Time handles TIMESTAMPTZ
 		&user.UpdatedAt,
+		&user.PasswordHash,
+		&user.IsAdmin,
 	)
 
 	if err != nil {
@@ -64,10 +80,13 @@ func CreateUser(ctx context.Context, username string) (*models.User, error) {
 }
 
 // DeleteUser deletes a user from the database by username
-func DeleteUser(ctx context.Context, username string) error {
+func (s *Store) DeleteUser(ctx context.Context, username string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	query := "DELETE FROM users WHERE username=$1"
 
-	result, err := conn.Exec(ctx, query, username)
+	result, err := s.q.Exec(ctx, query, username)
 	if err != nil {
 		return fmt.Errorf("query failed: %w", err)
 	}
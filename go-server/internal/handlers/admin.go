@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"compound/go-server/internal/db"
+	"compound/go-server/internal/redact"
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Page size limits for admin listings
+const (
+	defaultAdminLimit = 50
+	maxAdminLimit     = 500
+)
+
+// RequireAdmin is middleware that only lets admins through, must run after RequireSession
+func (h *Handler) RequireAdmin(c *gin.Context) {
+	if !currentUser(c).IsAdmin {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": "admin access required",
+		})
+		return
+	}
+
+	c.Next()
+}
+
+// GetItemPlaidAPIFailures handles GET /api/admin/items/:id/plaid-api-failures?limit=50
+// Returns the item's most recent failed Plaid API calls, newest first, for debugging broken items.
+// Works for any user's item, so it must only be registered behind RequireAdmin.
+func (h *Handler) GetItemPlaidAPIFailures(c *gin.Context) {
+	itemID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid item id",
+		})
+		return
+	}
+
+	limit, ok := adminLimit(c)
+	if !ok {
+		return
+	}
+
+	events, err := h.store.GetFailedPlaidAPIEventsByItemID(context.Background(), itemID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to get Plaid API events: " + redact.Error(err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events": newPlaidAPIEventResponses(events),
+	})
+}
+
+// GetLinkEvents handles GET /api/admin/link-events?user_id=&link_session_id=&errors_only=true&limit=50
+// Returns the most recent Plaid Link events, newest first, to diagnose failed bank connections
+func (h *Handler) GetLinkEvents(c *gin.Context) {
+	filter := db.LinkEventFilter{
+		LinkSessionID: c.Query("link_session_id"),
+		ErrorsOnly:    c.Query("errors_only") == "true",
+	}
+
+	if userIDStr := c.Query("user_id"); userIDStr != "" {
+		userID, err := strconv.Atoi(userIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid user id",
+			})
+			return
+		}
+		filter.UserID = userID
+	}
+
+	limit, ok := adminLimit(c)
+	if !ok {
+		return
+	}
+	filter.Limit = limit
+
+	events, err := h.store.GetLinkEvents(context.Background(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to get link events: " + redact.Error(err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events": newLinkEventResponses(events),
+	})
+}
+
+// adminLimit parses the optional limit query parameter, responding 400 if it is out of range
+func adminLimit(c *gin.Context) (int, bool) {
+	limitStr := c.Query("limit")
+	if limitStr == "" {
+		return defaultAdminLimit, true
+	}
+
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 1 || limit > maxAdminLimit {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "limit must be between 1 and " + strconv.Itoa(maxAdminLimit),
+		})
+		return 0, false
+	}
+
+	return limit, true
+}
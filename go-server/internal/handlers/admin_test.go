@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"compound/go-server/internal/db/dbtest"
+	"compound/go-server/pkg/models"
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// makeAdmin grants a user admin access, which is only ever set by hand
+func makeAdmin(t *testing.T, userID int) {
+	t.Helper()
+	dbtest.Exec(t, `UPDATE users_table SET is_admin = true WHERE id=$1`, userID)
+}
+
+// TestGetItemPlaidAPIFailures checks only admins see an item's failed Plaid calls, and only that
+// item's failures
+func TestGetItemPlaidAPIFailures(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+
+	aliceID, alice := s.signUp(t, "alice")
+	adminID, admin := s.signUp(t, "admin")
+	makeAdmin(t, adminID)
+	itemID := s.linkItem(t, alice)
+	otherItemID := s.linkItem(t, admin)
+
+	str := func(s string) *string { return &s }
+	events := []*models.PlaidAPIEvent{
+		{ItemID: &itemID, UserID: &aliceID, PlaidMethod: "transactionsSync", RequestID: str("req-1"),
+			ErrorType: str("ITEM_ERROR"), ErrorCode: str("ITEM_LOGIN_REQUIRED"), ErrorMessage: str("login required")},
+		{ItemID: &itemID, UserID: &aliceID, PlaidMethod: "accountsGet", RequestID: str("req-2")},
+		{ItemID: &itemID, UserID: &aliceID, PlaidMethod: "itemGet", ErrorMessage: str("context deadline exceeded")},
+		{ItemID: &otherItemID, UserID: &adminID, PlaidMethod: "transactionsSync", RequestID: str("req-3"),
+			ErrorType: str("API_ERROR"), ErrorCode: str("INTERNAL_SERVER_ERROR"), ErrorMessage: str("unexpected error")},
+	}
+	for _, event := range events {
+		if err := s.store.CreatePlaidAPIEvent(ctx, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := fmt.Sprintf("/api/admin/items/%d/plaid-api-failures", itemID)
+	s.doExpect(t, http.MethodGet, path, alice, nil, http.StatusForbidden, nil)
+	s.doExpect(t, http.MethodGet, path, "", nil, http.StatusUnauthorized, nil)
+
+	var failures struct {
+		Events []struct {
+			ItemID      int     `json:"item_id"`
+			PlaidMethod string  `json:"plaid_method"`
+			ErrorCode   *string `json:"error_code"`
+		} `json:"events"`
+	}
+	s.doExpect(t, http.MethodGet, path, admin, nil, http.StatusOK, &failures)
+
+	// newest first, the successful call and the other item's failure left out
+	var methods []string
+	for _, event := range failures.Events {
+		if event.ItemID != itemID {
+			t.Fatalf("listed an event of item %d, want only item %d's", event.ItemID, itemID)
+		}
+		methods = append(methods, event.PlaidMethod)
+	}
+	if want := []string{"itemGet", "transactionsSync"}; fmt.Sprint(methods) != fmt.Sprint(want) {
+		t.Fatalf("failures = %v, want %v", methods, want)
+	}
+
+	s.doExpect(t, http.MethodGet, path+"?limit=1", admin, nil, http.StatusOK, &failures)
+	if len(failures.Events) != 1 {
+		t.Fatalf("limit=1 listed %d failures", len(failures.Events))
+	}
+	s.doExpect(t, http.MethodGet, path+"?limit=0", admin, nil, http.StatusBadRequest, nil)
+}
@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"compound/go-server/internal/db"
+	"compound/go-server/internal/redact"
+	"compound/go-server/pkg/models"
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxAssetDescriptionLength is the longest manual asset description accepted
+const maxAssetDescriptionLength = 255
+
+// assetValuePlaces is the scale of manual asset values, which are stored as numeric(28,2)
+// Postgres would round a value with more places, so it's refused rather than stored
+const assetValuePlaces = 2
+
+// maxAssetValue bounds manual asset values to the numeric(28,2) columns
+var maxAssetValue = models.MustParseMoney("100000000000000000000000000")
+
+// CreateAssetRequest represents the request body for creating a manual asset
+type CreateAssetRequest struct {
+	Description string        `json:"description" binding:"required"`
+	Value       *models.Money `json:"value" binding:"required"`
+	Date        string        `json:"date"`
+}
+
+// UpdateAssetRequest represents the request body for updating a manual asset
+// Fields left out are unchanged
+type UpdateAssetRequest struct {
+	Description *string       `json:"description"`
+	Value       *models.Money `json:"value"`
+	Date        string        `json:"date"`
+}
+
+// CreateAsset handles POST /api/assets
+// Creates a manual asset, e.g. a house or a car, for the current user. Values are in the
+// server's net worth currency.
+//
+// Request body:
+// {
+//   "description": "House",
+//   "value": 350000,
+//   "date": "2024-06-01"   // optional, the day the value is as of, defaults to today (UTC)
+// }
+//
+// Response: AssetResponse
+func (h *Handler) CreateAsset(c *gin.Context) {
+	var req CreateAssetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "description and value are required",
+		})
+		return
+	}
+
+	description, date, errMsg := validateAssetRequest(&req.Description, req.Value, req.Date, db.Today())
+	if errMsg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": errMsg,
+		})
+		return
+	}
+
+	var asset *models.Asset
+	err := h.store.WithTx(context.Background(), func(tx *db.Store) error {
+		created, err := tx.CreateAsset(context.Background(), currentUser(c).ID, *description, *req.Value)
+		if err != nil {
+			return fmt.Errorf("failed to create asset: %w", err)
+		}
+
+		asset, err = tx.RecordAssetValue(context.Background(), created.ID, date, *req.Value)
+		if err != nil {
+			return fmt.Errorf("failed to record asset value: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": redact.Error(err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, newAssetResponse(asset))
+}
+
+// GetAssets handles GET /api/assets
+// Retrieves the current user's manual assets
+func (h *Handler) GetAssets(c *gin.Context) {
+	assets, err := h.store.GetAssetsByUserID(context.Background(), currentUser(c).ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to get assets: " + redact.Error(err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"assets": newAssetResponses(assets),
+	})
+}
+
+// GetAsset handles GET /api/assets/:id
+// Retrieves one of the current user's manual assets
+func (h *Handler) GetAsset(c *gin.Context) {
+	asset, err := h.store.GetAssetByID(context.Background(), ownedAssetID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to get asset: " + redact.Error(err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, newAssetResponse(asset))
+}
+
+// UpdateAsset handles PATCH /api/assets/:id
+// Updates one of the current user's manual assets. A new value is added to the asset's value
+// history as of date; a date before the latest recorded value backfills the history and leaves
+// the current value alone.
+//
+// Request body (at least one of description and value):
+// {
+//   "description": "House",
+//   "value": 365000,
+//   "date": "2025-01-01"   // optional, the day the value is as of, defaults to today (UTC)
+// }
+//
+// Response: AssetResponse
+func (h *Handler) UpdateAsset(c *gin.Context) {
+	var req UpdateAssetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid request body",
+		})
+		return
+	}
+	if req.Description == nil && req.Value == nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "description or value is required",
+		})
+		return
+	}
+	if req.Value == nil && req.Date != "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "date is only allowed with a value",
+		})
+		return
+	}
+
+	description, date, errMsg := validateAssetRequest(req.Description, req.Value, req.Date, db.Today())
+	if errMsg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": errMsg,
+		})
+		return
+	}
+
+	assetID := ownedAssetID(c)
+	var asset *models.Asset
+	err := h.store.WithTx(context.Background(), func(tx *db.Store) error {
+		var err error
+		if description != nil {
+			asset, err = tx.UpdateAssetDescription(context.Background(), assetID, *description)
+			if err != nil {
+				return fmt.Errorf("failed to update asset: %w", err)
+			}
+		}
+
+		if req.Value != nil {
+			asset, err = tx.RecordAssetValue(context.Background(), assetID, date, *req.Value)
+			if err != nil {
+				return fmt.Errorf("failed to record asset value: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": redact.Error(err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, newAssetResponse(asset))
+}
+
+// DeleteAsset handles DELETE /api/assets/:id
+// Deletes one of the current user's manual assets along with its value history
+//
+// Response: 204 No Content
+func (h *Handler) DeleteAsset(c *gin.Context) {
+	if err := h.store.DeleteAsset(context.Background(), ownedAssetID(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to delete asset: " + redact.Error(err),
+		})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetAssetValues handles GET /api/assets/:id/values
+// Returns one of the current user's manual assets' value history, oldest first
+//
+// Response:
+// {
+//   "values": [
+//     { "asset_id": 3, "date": "2024-06-01", "value": 350000, "updated_at": "..." },
+//     ...
+//   ]
+// }
+func (h *Handler) GetAssetValues(c *gin.Context) {
+	values, err := h.store.GetAssetValues(context.Background(), ownedAssetID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to get asset values: " + redact.Error(err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"values": newAssetValueResponses(values),
+	})
+}
+
+// validateAssetRequest checks the fields of an asset request, any of which may be nil
+// Returns the trimmed description, the parsed date (today if dateStr is empty) and an error
+// message, which is empty if the request is valid. today is both the default and the latest
+// date allowed, so the check and the stored date can't fall on different days.
+func validateAssetRequest(description *string, value *models.Money, dateStr string, today time.Time) (*string, time.Time, string) {
+	if description != nil {
+		trimmed := strings.TrimSpace(*description)
+		if trimmed == "" {
+			return nil, time.Time{}, "description must not be empty"
+		}
+		if utf8.RuneCountInString(trimmed) > maxAssetDescriptionLength {
+			return nil, time.Time{}, "description must be at most " + strconv.Itoa(maxAssetDescriptionLength) + " characters"
+		}
+		description = &trimmed
+	}
+
+	if value != nil && (value.Sign() < 0 || value.Cmp(maxAssetValue) >= 0) {
+		return nil, time.Time{}, "value must be zero or more and below 10^26"
+	}
+	if value != nil && value.Places() > assetValuePlaces {
+		return nil, time.Time{}, "value must have at most " + strconv.Itoa(assetValuePlaces) + " decimal places"
+	}
+
+	if dateStr == "" {
+		return description, today, ""
+	}
+	date, err := time.Parse(time.DateOnly, dateStr)
+	if err != nil {
+		return nil, time.Time{}, "date must be formatted YYYY-MM-DD"
+	}
+	if date.After(today) {
+		return nil, time.Time{}, "date must not be in the future"
+	}
+	return description, date, ""
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"compound/go-server/internal/db"
+	"compound/go-server/pkg/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateAssetValue(t *testing.T) {
+	tests := []struct {
+		value string
+		valid bool
+	}{
+		{"0", true},
+		{"1250.5", true},
+		{"1250.50", true},
+		{"99999999999999999999999999.99", true},
+		{"1250.505", false},
+		{"0.001", false},
+		{"-1", false},
+		{"100000000000000000000000000", false},
+	}
+
+	for _, tt := range tests {
+		value := models.MustParseMoney(tt.value)
+		_, _, msg := validateAssetRequest(nil, &value, "", db.Today())
+		if valid := msg == ""; valid != tt.valid {
+			t.Errorf("value %s: got %q, want valid=%v", tt.value, msg, tt.valid)
+		}
+	}
+}
+
+// TestValidateAssetDescription checks the length limit counts characters, like the column does,
+// not bytes
+func TestValidateAssetDescription(t *testing.T) {
+	tests := []struct {
+		name        string
+		description string
+		want        string
+	}{
+		{"trimmed", "  House  ", "House"},
+		{"at the limit", strings.Repeat("a", maxAssetDescriptionLength), strings.Repeat("a", maxAssetDescriptionLength)},
+		{"multibyte at the limit", strings.Repeat("é", maxAssetDescriptionLength), strings.Repeat("é", maxAssetDescriptionLength)},
+		{"emoji at the limit", strings.Repeat("🏠", maxAssetDescriptionLength), strings.Repeat("🏠", maxAssetDescriptionLength)},
+		{"over the limit", strings.Repeat("é", maxAssetDescriptionLength+1), ""},
+		{"blank", "   ", ""},
+	}
+
+	for _, tt := range tests {
+		description, _, msg := validateAssetRequest(&tt.description, nil, "", db.Today())
+		if tt.want == "" {
+			if msg == "" {
+				t.Errorf("%s: accepted", tt.name)
+			}
+			continue
+		}
+		if msg != "" || description == nil || *description != tt.want {
+			t.Errorf("%s: got %v %q, want %q", tt.name, description, msg, tt.want)
+		}
+	}
+}
+
+func TestValidateAssetDate(t *testing.T) {
+	today := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		date  string
+		want  time.Time
+		valid bool
+	}{
+		{"", today, true},
+		{"2025-03-01", today, true},
+		{"2025-02-28", time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC), true},
+		{"2025-03-02", time.Time{}, false},
+		{"03/01/2025", time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		_, date, msg := validateAssetRequest(nil, nil, tt.date, today)
+		if valid := msg == ""; valid != tt.valid || !date.Equal(tt.want) {
+			t.Errorf("date %q: got %s %q, want %s valid=%v", tt.date, date, msg, tt.want, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"compound/go-server/internal/auth"
+	"compound/go-server/pkg/models"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// SessionCookieName is the cookie holding the session token for browser clients
+const SessionCookieName = "session"
+
+// Gin context keys set by RequireSession
+const (
+	currentUserKey      = "currentUser"
+	sessionTokenHashKey = "sessionTokenHash"
+)
+
+// RequireSession is middleware that resolves the current user from the session token
+// The token is read from an "Authorization: Bearer <token>" header, falling back to the
+// session cookie. Requests without a valid, unexpired session are rejected with 401.
+func (h *Handler) RequireSession(c *gin.Context) {
+	token := sessionToken(c)
+	if token == "" {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"error": "authentication required",
+		})
+		return
+	}
+
+	tokenHash := auth.HashSessionToken(token)
+	user, err := h.store.GetUserBySessionTokenHash(context.Background(), tokenHash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"error": "session is invalid or has expired",
+		})
+		return
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to load session - check server logs",
+		})
+		return
+	}
+
+	c.Set(currentUserKey, user)
+	c.Set(sessionTokenHashKey, tokenHash)
+	c.Next()
+}
+
+// currentUser returns the user resolved by RequireSession
+// Only call it from handlers registered behind RequireSession
+func currentUser(c *gin.Context) *models.User {
+	return c.MustGet(currentUserKey).(*models.User)
+}
+
+// sessionToken reads the session token from the Authorization header or the session cookie
+func sessionToken(c *gin.Context) string {
+	if header := c.GetHeader("Authorization"); header != "" {
+		scheme, token, ok := strings.Cut(header, " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+		return ""
+	}
+
+	token, err := c.Cookie(SessionCookieName)
+	if err != nil {
+		return ""
+	}
+	return token
+}
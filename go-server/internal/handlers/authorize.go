@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Gin context keys set by the ownership middleware
+const (
+	itemIDKey        = "itemID"
+	accountIDKey     = "accountID"
+	transactionIDKey = "transactionID"
+	assetIDKey       = "assetID"
+)
+
+// ownerLookup returns the ID of the user who owns the resource with the given ID
+type ownerLookup func(ctx context.Context, id int) (int, error)
+
+// RequireItemOwner is middleware that only lets the current user reach items they own
+// The item ID is read from the param path parameter, read it in the handler with ownedItemID.
+// Must run after RequireSession.
+func (h *Handler) RequireItemOwner(param string) gin.HandlerFunc {
+	return h.requireOwner("item", param, itemIDKey, h.store.GetItemOwnerID)
+}
+
+// RequireAccountOwner is middleware that only lets the current user reach accounts they own
+// Read the account ID in the handler with ownedAccountID. Must run after RequireSession.
+func (h *Handler) RequireAccountOwner(param string) gin.HandlerFunc {
+	return h.requireOwner("account", param, accountIDKey, h.store.GetAccountOwnerID)
+}
+
+// RequireTransactionOwner is middleware that only lets the current user reach transactions they own
+// Read the transaction ID in the handler with ownedTransactionID. Must run after RequireSession.
+func (h *Handler) RequireTransactionOwner(param string) gin.HandlerFunc {
+	return h.requireOwner("transaction", param, transactionIDKey, h.store.GetTransactionOwnerID)
+}
+
+// RequireAssetOwner is middleware that only lets the current user reach manual assets they own
+// Read the asset ID in the handler with ownedAssetID. Must run after RequireSession.
+func (h *Handler) RequireAssetOwner(param string) gin.HandlerFunc {
+	return h.requireOwner("asset", param, assetIDKey, h.store.GetAssetOwnerID)
+}
+
+// RequireSelf is middleware for routes addressed by user ID, only the current user's own ID is allowed
+// Must run after RequireSession.
+func (h *Handler) RequireSelf(param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param(param))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "invalid user id",
+			})
+			return
+		}
+
+		if id != currentUser(c).ID {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "user not found",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func (h *Handler) requireOwner(kind, param, key string, lookup ownerLookup) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param(param))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "invalid " + kind + " id",
+			})
+			return
+		}
+
+		if !h.checkOwner(c, kind, id, lookup) {
+			return
+		}
+
+		c.Set(key, id)
+		c.Next()
+	}
+}
+
+// checkOwner reports whether the current user owns the resource, aborting with an error response if not
+// Resources owned by someone else get the same 404 as ones that don't exist, so IDs can't be probed
+func (h *Handler) checkOwner(c *gin.Context, kind string, id int, lookup ownerLookup) bool {
+	ownerID, err := lookup(context.Background(), id)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to load " + kind + " - check server logs",
+		})
+		return false
+	}
+
+	if err != nil || ownerID != currentUser(c).ID {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": kind + " not found",
+		})
+		return false
+	}
+
+	return true
+}
+
+// ownedItemID returns the item ID checked by RequireItemOwner
+func ownedItemID(c *gin.Context) int {
+	return c.GetInt(itemIDKey)
+}
+
+// ownedAccountID returns the account ID checked by RequireAccountOwner
+func ownedAccountID(c *gin.Context) int {
+	return c.GetInt(accountIDKey)
+}
+
+// ownedTransactionID returns the transaction ID checked by RequireTransactionOwner
+func ownedTransactionID(c *gin.Context) int {
+	return c.GetInt(transactionIDKey)
+}
+
+// ownedAssetID returns the asset ID checked by RequireAssetOwner
+func ownedAssetID(c *gin.Context) int {
+	return c.GetInt(assetIDKey)
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"compound/go-server/internal/db"
+	plaidpkg "compound/go-server/internal/plaid"
+	"compound/go-server/internal/redact"
+	"compound/go-server/internal/services"
+	"compound/go-server/pkg/models"
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Day limits for GET /api/accounts/:id/balance-history
+const (
+	defaultBalanceHistoryDays = 90
+	maxBalanceHistoryDays     = 730
+)
+
+// RefreshItemBalances handles POST /api/items/:id/balances/refresh
+// Fetches real-time balances from the institution for one of the current user's items and
+// stores them. Account listings already carry the balances Plaid cached at the last fetch,
+// this is for when those are too stale; Plaid bills every call.
+//
+// Response:
+// {
+//   "accounts": [ ...AccountResponse ]
+// }
+func (h *Handler) RefreshItemBalances(c *gin.Context) {
+	user := currentUser(c)
+
+	item, err := h.store.GetItemByID(context.Background(), ownedItemID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to get item: " + redact.Error(err),
+		})
+		return
+	}
+
+	plaidCtx := plaidpkg.WithEventScope(context.Background(), user.ID, item.ID)
+	plaidAccounts, err := h.aggregator.GetAccountBalances(plaidCtx, item.PlaidAccessToken)
+	if err != nil {
+		h.respondItemError(c, item, http.StatusBadGateway, "failed to get account balances", err)
+		return
+	}
+
+	var accounts []*models.Account
+	err = h.store.WithTx(context.Background(), func(tx *db.Store) error {
+		for _, plaidAccount := range plaidAccounts {
+			account, err := services.StoreAccount(context.Background(), tx, item.ID, plaidAccount)
+			if err != nil {
+				return err
+			}
+			accounts = append(accounts, account)
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": redact.Error(err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"accounts": newAccountResponses(accounts),
+	})
+}
+
+// GetAccountBalanceHistory handles GET /api/accounts/:id/balance-history?days=90
+// Returns one of the current user's accounts' daily balances for the last days, oldest first
+// Days on which the account's balances were never fetched have no snapshot.
+func (h *Handler) GetAccountBalanceHistory(c *gin.Context) {
+	days := defaultBalanceHistoryDays
+	if daysStr := c.Query("days"); daysStr != "" {
+		n, err := strconv.Atoi(daysStr)
+		if err != nil || n < 1 || n > maxBalanceHistoryDays {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "days must be between 1 and " + strconv.Itoa(maxBalanceHistoryDays),
+			})
+			return
+		}
+		days = n
+	}
+
+	// the last days end today, in UTC like the snapshot dates
+	from := db.Today().AddDate(0, 0, 1-days)
+	snapshots, err := h.store.GetAccountBalanceSnapshots(context.Background(), ownedAccountID(c), from)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to get balance history: " + redact.Error(err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"snapshots": newBalanceSnapshotResponses(snapshots),
+	})
+}
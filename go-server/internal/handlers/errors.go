@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	plaidpkg "compound/go-server/internal/plaid"
+	"compound/go-server/internal/redact"
+	"compound/go-server/pkg/models"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondError writes an ErrorResponse for err, message says what the handler was doing
+// Plaid API errors get a status matching their error_type and the Plaid error details,
+// anything else gets fallbackStatus
+func respondError(c *gin.Context, fallbackStatus int, message string, err error) {
+	response := ErrorResponse{
+		Error: message + ": " + redact.Error(err),
+	}
+
+	status := fallbackStatus
+	if plaidErr, ok := plaidpkg.AsPlaidError(err); ok {
+		status = plaidErrorStatus(plaidErr)
+		response.PlaidError = newPlaidErrorResponse(plaidErr)
+	} else if errors.Is(err, context.DeadlineExceeded) {
+		status = http.StatusGatewayTimeout
+	}
+
+	c.JSON(status, response)
+}
+
+// respondItemError is respondError for a failed Plaid call made with item's access token
+// ITEM_LOGIN_REQUIRED also moves the item to login_required, like a failed sync does
+func (h *Handler) respondItemError(c *gin.Context, item *models.Item, fallbackStatus int, message string, err error) {
+	if plaidpkg.IsLoginRequired(err) && item.Status != models.ItemStatusLoginRequired {
+		if err := h.store.UpdateItemStatus(context.Background(), item.ID, models.ItemStatusLoginRequired); err != nil {
+			log.Printf("item %d: failed to mark item login required: %s", item.ID, redact.Error(err))
+		}
+	}
+
+	respondError(c, fallbackStatus, message, err)
+}
+
+// plaidErrorStatus maps a Plaid error to the status we respond with
+// Plaid's own HTTP status describes our request to Plaid, not the client's request to us,
+// so the error type decides. An invalid request to Plaid is our bug and a 502, only the codes
+// for input the client gave us (a public token, an account ID) are the client's 400.
+func plaidErrorStatus(plaidErr *plaidpkg.PlaidError) int {
+	switch plaidErr.ErrorCode {
+	case plaidpkg.ErrorCodeItemNotFound:
+		return http.StatusNotFound
+	case plaidpkg.ErrorCodeInvalidPublicToken, plaidpkg.ErrorCodeInvalidAccountID:
+		return http.StatusBadRequest
+	}
+
+	switch plaidErr.ErrorType {
+	case plaidpkg.ErrorTypeItemError:
+		// the item needs the user to act, e.g. log in again through Link update mode
+		return http.StatusConflict
+	case plaidpkg.ErrorTypeRateLimitExceeded:
+		return http.StatusTooManyRequests
+	case plaidpkg.ErrorTypeInstitutionError:
+		return http.StatusServiceUnavailable
+	default:
+		// API_ERROR, INVALID_RESULT and anything newer are Plaid's problem
+		return http.StatusBadGateway
+	}
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	plaidpkg "compound/go-server/internal/plaid"
+	"net/http"
+	"testing"
+)
+
+func TestPlaidErrorStatus(t *testing.T) {
+	tests := []struct {
+		errorType string
+		errorCode string
+		want      int
+	}{
+		// a bad request to Plaid is ours to fix, not the client's
+		{plaidpkg.ErrorTypeInvalidRequest, "INVALID_FIELD", http.StatusBadGateway},
+		{plaidpkg.ErrorTypeInvalidInput, "INVALID_ACCESS_TOKEN", http.StatusBadGateway},
+		{plaidpkg.ErrorTypeInvalidInput, plaidpkg.ErrorCodeInvalidPublicToken, http.StatusBadRequest},
+		{plaidpkg.ErrorTypeInvalidInput, plaidpkg.ErrorCodeInvalidAccountID, http.StatusBadRequest},
+		{plaidpkg.ErrorTypeItemError, plaidpkg.ErrorCodeItemNotFound, http.StatusNotFound},
+		{plaidpkg.ErrorTypeItemError, plaidpkg.ErrorCodeItemLoginRequired, http.StatusConflict},
+		{plaidpkg.ErrorTypeRateLimitExceeded, "TRANSACTIONS_LIMIT", http.StatusTooManyRequests},
+		{plaidpkg.ErrorTypeInstitutionError, "INSTITUTION_DOWN", http.StatusServiceUnavailable},
+		{plaidpkg.ErrorTypeAPIError, "INTERNAL_SERVER_ERROR", http.StatusBadGateway},
+	}
+
+	for _, tt := range tests {
+		plaidErr := &plaidpkg.PlaidError{ErrorType: tt.errorType, ErrorCode: tt.errorCode}
+		if got := plaidErrorStatus(plaidErr); got != tt.want {
+			t.Errorf("plaidErrorStatus(%s %s) = %d, want %d", tt.errorType, tt.errorCode, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"compound/go-server/internal/db"
+	plaidpkg "compound/go-server/internal/plaid"
+	"compound/go-server/internal/services"
+)
+
+// Handler holds the dependencies shared by every HTTP handler
+// main.go builds one Handler and registers its methods as routes
+type Handler struct {
+	store      *db.Store
+	aggregator plaidpkg.Aggregator
+	syncer     *services.TransactionSyncer
+}
+
+// New creates a Handler backed by store and aggregator
+func New(store *db.Store, aggregator plaidpkg.Aggregator, syncer *services.TransactionSyncer) *Handler {
+	return &Handler{store: store, aggregator: aggregator, syncer: syncer}
+}
@@ -3,146 +3,408 @@ package handlers
 import (
 	"compound/go-server/internal/db"
 	plaidpkg "compound/go-server/internal/plaid"
+	"compound/go-server/internal/redact"
+	"compound/go-server/internal/services"
+	"compound/go-server/pkg/models"
 	"context"
+	"fmt"
+	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
 // ExchangeTokenRequest represents the request body for exchanging a public token
+// The item is always stored for the logged in user. InstitutionID and Accounts come from the
+// Link onSuccess metadata and are used to detect an institution the user already linked, which
+// has to happen before the single-use public token is exchanged.
 type ExchangeTokenRequest struct {
-	PublicToken string `json:"publicToken" binding:"required"`
-	UserID      int    `json:"userId" binding:"required"`
+	PublicToken   string        `json:"publicToken" binding:"required"`
+	InstitutionID string        `json:"institutionId" binding:"required"`
+	Accounts      []LinkAccount `json:"accounts"`
+	ReplaceItemID *int          `json:"replaceItemId"`
 }
 
+// LinkAccount is an account from the Link onSuccess metadata
+type LinkAccount struct {
+	Mask string `json:"mask"`
+}
+
+// errCodeDuplicateItem is the DuplicateItemResponse code
+const errCodeDuplicateItem = "DUPLICATE_ITEM"
+
 // ExchangeToken handles POST /api/items
 // Exchanges a public token from Plaid Link for an access token and stores the item
 //
 // Request body:
 // {
 //   "publicToken": "public-sandbox-...",
-//   "userId": 123
+//   "institutionId": "ins_3",             // metadata.institution.institution_id
+//   "accounts": [{ "mask": "0000" }],     // optional, metadata.accounts
+//   "replaceItemId": 12                   // optional, replaces the duplicate item
 // }
 //
-// Response:
+// If the user already has an item at the institution sharing an account mask (or any item at
+// it when masks are unknown), responds 409 with a DuplicateItemResponse before the public token
+// is exchanged. The same request with replaceItemId set to the duplicate's ID links the new
+// item and removes the old one; replaceItemId naming any other item is refused. The old item
+// is deleted here along with storing the new one, then removed from Plaid; if that fails the
+// removal is retried by the sync scheduler.
+//
+// The duplicate check trusts institutionId, so once exchanged the item's institution is checked
+// against it. An item at another institution is removed from Plaid again and the request
+// refused with a 400. An exchanged item that fails to be stored for any other reason is removed
+// from Plaid too, nothing here would refer to it.
+//
+// Response (the access token never leaves the server):
 // {
-//   "item_id": "plaid-item-id",
-//   "access_token": "access-sandbox-...",
+//   "item_id": 42,
+//   "replaced_item_id": 12,               // only when an item was replaced
+//   "plaid_item_id": "plaid-item-id",
+//   "institution_name": "Chase",
+//   "item": { ...ItemResponse },
 //   "accounts": [
 //     {
-//       "id": "account-id",
+//       "id": 7,
+//       "plaid_account_id": "account-id",
 //       "name": "Checking Account",
 //       "mask": "1234",
 //       "type": "depository",
-//       "subtype": "checking"
+//       "subtype": "checking",
+//       ...
 //     }
 //   ]
 // }
-func ExchangeToken(c *gin.Context) {
+func (h *Handler) ExchangeToken(c *gin.Context) {
 	var req ExchangeTokenRequest
 
 	// Parse request body
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "publicToken and userId are required",
+			"error": "publicToken and institutionId are required",
 		})
 		return
 	}
 
-	// Exchange public token for access token with Plaid
-	accessToken, itemID, err := plaidpkg.ExchangePublicToken(context.Background(), req.PublicToken)
+	user := currentUser(c)
+
+	if req.ReplaceItemID != nil && !h.checkOwner(c, "item", *req.ReplaceItemID, h.store.GetItemOwnerID) {
+		return
+	}
+
+	// check before exchanging, an unexchanged public token can be resent with replaceItemId
+	duplicate, err := h.findDuplicateItem(context.Background(), user.ID, req)
 	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to check for duplicate items: " + redact.Error(err),
+		})
+		return
+	}
+	if duplicate != nil && (req.ReplaceItemID == nil || *req.ReplaceItemID != duplicate.ID) {
+		c.JSON(http.StatusConflict, DuplicateItemResponse{
+			ErrorResponse: ErrorResponse{Error: "institution already linked"},
+			Code:          errCodeDuplicateItem,
+			Item:          newItemResponse(duplicate),
+		})
+		return
+	}
+	if req.ReplaceItemID != nil && duplicate == nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "failed to exchange token: " + err.Error(),
+			"error": "replaceItemId must be the item this link duplicates",
 		})
 		return
 	}
 
-	// Get accounts for the linked item
-	accounts, err := plaidpkg.GetAccounts(context.Background(), accessToken)
+	// only the duplicate can be replaced
+	replaced := duplicate
+
+	// the item doesn't exist yet, so these Plaid calls are only attributed to the user
+	plaidCtx := plaidpkg.WithEventScope(context.Background(), user.ID, 0)
+
+	// Exchange public token for access token with Plaid
+	accessToken, itemID, err := h.aggregator.ExchangePublicToken(plaidCtx, req.PublicToken)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "failed to get accounts: " + err.Error(),
-		})
+		respondError(c, http.StatusBadGateway, "failed to exchange token", err)
 		return
 	}
 
-	// Get item details to retrieve institution ID
-	item, err := plaidpkg.GetItem(context.Background(), accessToken)
+	// Get item details to check the institution the duplicate check was run for
+	item, err := h.aggregator.GetItem(plaidCtx, accessToken)
 	if err != nil {
+		h.discardExchangedItem(plaidCtx, user.ID, accessToken, itemID, "")
+		respondError(c, http.StatusBadGateway, "failed to get item details", err)
+		return
+	}
+
+	institutionID := item.GetInstitutionId()
+	if institutionID != req.InstitutionID {
+		h.discardExchangedItem(plaidCtx, user.ID, accessToken, itemID, institutionID)
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "failed to get item details: " + err.Error(),
+			"error": "institutionId does not match the linked item's institution",
 		})
 		return
 	}
 
+	// Get accounts for the linked item
+	accounts, err := h.aggregator.GetAccounts(plaidCtx, accessToken)
+	if err != nil {
+		h.discardExchangedItem(plaidCtx, user.ID, accessToken, itemID, institutionID)
+		respondError(c, http.StatusBadGateway, "failed to get accounts", err)
+		return
+	}
+
 	// Get institution details
-	var institutionID string
 	var institutionName string
-	if item.InstitutionId.IsSet() {
-		instIDPtr := item.InstitutionId.Get()
-		if instIDPtr != nil {
-			institutionID = *instIDPtr
-			institution, err := plaidpkg.InstitutionsGetByID(context.Background(), *instIDPtr)
-			if err == nil {
-				institutionName = institution.GetName()
+	institution, err := h.aggregator.InstitutionsGetByID(plaidCtx, institutionID)
+	if err == nil {
+		institutionName = institution.GetName()
+	}
+
+	// the replaced item is removed from Plaid only once the new one is stored, until then its
+	// removal is pending and keeps the access token
+	var removal *models.ItemRemoval
+	if replaced != nil {
+		removal = newItemRemoval(user.ID, replaced)
+		removal.PlaidAccessToken = replaced.PlaidAccessToken
+	}
+
+	// Store item and its accounts in one transaction so a failed account insert
+	// doesn't leave behind an item with no accounts, and the user never loses the replaced item
+	// without getting the new one
+	var dbItem *models.Item
+	var dbAccounts []*models.Account
+	err = h.store.WithTx(context.Background(), func(tx *db.Store) error {
+		if removal != nil {
+			if err := deleteRemovedItem(context.Background(), tx, removal); err != nil {
+				return err
 			}
 		}
-	}
 
-	// Store item in database
-	dbItem, err := db.CreateItem(context.Background(), req.UserID, accessToken, itemID, institutionID, "linked")
+		dbItem, err = tx.CreateItem(context.Background(), user.ID, accessToken, itemID, institutionID, models.ItemStatusLinked)
+		if err != nil {
+			return fmt.Errorf("failed to store item: %w", err)
+		}
+
+		for _, account := range accounts {
+			dbAccount, err := services.StoreAccount(context.Background(), tx, dbItem.ID, account)
+			if err != nil {
+				return err
+			}
+			dbAccounts = append(dbAccounts, dbAccount)
+		}
+
+		return nil
+	})
 	if err != nil {
+		h.discardExchangedItem(plaidCtx, user.ID, accessToken, itemID, institutionID)
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to store item: " + err.Error(),
+			"error": redact.Error(err),
 		})
 		return
 	}
 
-	// Store accounts in database and prepare response
-	var responseAccounts []gin.H
-	for _, account := range accounts {
-		_, err := db.CreateOrUpdateAccount(
-			context.Background(),
-			dbItem.ID,
-			account.GetAccountId(),
-			account.GetName(),
-			account.GetMask(),
-			string(account.GetType()),
-			string(account.GetSubtype()),
-		)
+	if removal != nil {
+		if err := services.CompleteItemRemoval(context.Background(), h.store, h.aggregator, removal); err != nil {
+			log.Printf("item %d: failed to remove replaced item from Plaid, will retry: %s", removal.ItemID, redact.Error(err))
+		}
+	}
+
+	// Return success response
+	response := gin.H{
+		"item_id":          dbItem.ID,
+		"plaid_item_id":    itemID,
+		"institution_name": institutionName,
+		"item":             newItemResponse(dbItem),
+		"accounts":         newAccountResponses(dbAccounts),
+	}
+	if replaced != nil {
+		response["replaced_item_id"] = replaced.ID
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// findDuplicateItem returns the user's item that req would link a second time, or nil
+// An item is a duplicate if it's at the same institution and, when both sides know their account
+// masks, shares one of them. Two logins at one bank (e.g. personal and business) have different
+// masks and aren't duplicates. If several items are duplicates and one is being replaced, that
+// one is returned.
+func (h *Handler) findDuplicateItem(ctx context.Context, userID int, req ExchangeTokenRequest) (*models.Item, error) {
+	items, err := h.store.GetItemsByPlaidInstitutionID(ctx, userID, req.InstitutionID)
+	if err != nil {
+		return nil, err
+	}
+
+	masks := make(map[string]bool)
+	for _, account := range req.Accounts {
+		if account.Mask != "" {
+			masks[account.Mask] = true
+		}
+	}
+
+	var duplicate *models.Item
+	for _, item := range items {
+		isDuplicate, err := h.sharesAccountMask(ctx, item, masks)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "failed to store account: " + err.Error(),
-			})
-			return
+			return nil, err
+		}
+		if !isDuplicate {
+			continue
 		}
+		if req.ReplaceItemID != nil && item.ID == *req.ReplaceItemID {
+			return item, nil
+		}
+		if duplicate == nil {
+			duplicate = item
+		}
+	}
 
-		responseAccounts = append(responseAccounts, gin.H{
-			"id":      account.GetAccountId(),
-			"name":    account.GetName(),
-			"mask":    account.GetMask(),
-			"type":    account.GetType(),
-			"subtype": account.GetSubtype(),
+	return duplicate, nil
+}
+
+// sharesAccountMask reports whether one of item's accounts has one of masks
+// An empty masks, or an item without accounts, can't be told apart so they always match
+func (h *Handler) sharesAccountMask(ctx context.Context, item *models.Item, masks map[string]bool) (bool, error) {
+	if len(masks) == 0 {
+		return true, nil
+	}
+
+	accounts, err := h.store.GetAccountsByItemID(ctx, item.ID)
+	if err != nil {
+		return false, err
+	}
+	if len(accounts) == 0 {
+		return true, nil
+	}
+	for _, account := range accounts {
+		if masks[account.Mask] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// discardExchangedItem removes an item that was exchanged but won't be stored from Plaid
+// The removal is recorded without an item ID, pending for the sync scheduler to retry if Plaid
+// couldn't be reached
+func (h *Handler) discardExchangedItem(ctx context.Context, userID int, accessToken, plaidItemID, institutionID string) {
+	removal := newItemRemoval(userID, &models.Item{PlaidItemID: plaidItemID, PlaidInstitutionID: institutionID})
+	if err := h.aggregator.RemoveItem(ctx, accessToken); err != nil {
+		log.Printf("user %d: failed to remove discarded item from Plaid, will retry: %s", userID, redact.Error(err))
+		removal.PlaidAccessToken = accessToken
+	}
+
+	if err := h.store.CreateItemRemoval(context.Background(), removal); err != nil {
+		log.Printf("user %d: failed to record removal of discarded item: %s", userID, redact.Error(err))
+	}
+}
+
+// GetItems handles GET /api/items
+// Retrieves the current user's items
+func (h *Handler) GetItems(c *gin.Context) {
+	items, err := h.store.GetItemsByUserID(context.Background(), currentUser(c).ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to get items: " + redact.Error(err),
 		})
+		return
 	}
 
-	// Return success response
 	c.JSON(http.StatusOK, gin.H{
-		"item_id":              dbItem.ID,
-		"plaid_item_id":        itemID,
-		"institution_name":     institutionName,
-		"access_token":         accessToken,
-		"accounts":             responseAccounts,
+		"items": newItemResponses(items),
 	})
 }
 
 // GetItemAccounts handles GET /api/items/:id/accounts
-// Retrieves accounts for a specific item
-func GetItemAccounts(c *gin.Context) {
-	_ = c.Param("id") // itemIDStr - not yet implemented
+// Retrieves accounts for one of the current user's items
+func (h *Handler) GetItemAccounts(c *gin.Context) {
+	accounts, err := h.store.GetAccountsByItemID(context.Background(), ownedItemID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to get accounts: " + redact.Error(err),
+		})
+		return
+	}
 
-	c.JSON(http.StatusNotImplemented, gin.H{
-		"error": "not yet implemented",
+	c.JSON(http.StatusOK, gin.H{
+		"accounts": newAccountResponses(accounts),
 	})
 }
+
+// DeleteItem handles DELETE /api/items/:id
+// Removes one of the current user's items from Plaid, which invalidates its access token, then
+// deletes it along with its accounts and transactions. The removal is recorded in
+// item_removals_table.
+//
+// Retrying is safe: if Plaid reports the item already gone (e.g. an earlier attempt removed it
+// from Plaid but failed to delete it here) the item is still deleted.
+//
+// Response: 204 No Content
+func (h *Handler) DeleteItem(c *gin.Context) {
+	user := currentUser(c)
+
+	item, err := h.store.GetItemByID(context.Background(), ownedItemID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to get item: " + redact.Error(err),
+		})
+		return
+	}
+
+	removal, err := h.removeFromPlaid(context.Background(), user.ID, item)
+	if err != nil {
+		respondError(c, http.StatusBadGateway, "failed to remove item", err)
+		return
+	}
+
+	err = h.store.WithTx(context.Background(), func(tx *db.Store) error {
+		return deleteRemovedItem(context.Background(), tx, removal)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": redact.Error(err),
+		})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// removeFromPlaid removes item from Plaid and returns the removal to record
+// Plaid reporting the item already gone isn't an error, the removal notes it instead
+func (h *Handler) removeFromPlaid(ctx context.Context, userID int, item *models.Item) (*models.ItemRemoval, error) {
+	err := h.aggregator.RemoveItem(plaidpkg.WithEventScope(ctx, userID, item.ID), item.PlaidAccessToken)
+	alreadyRemoved := plaidpkg.ErrorCode(err) == plaidpkg.ErrorCodeItemNotFound
+	if err != nil && !alreadyRemoved {
+		return nil, err
+	}
+
+	removal := newItemRemoval(userID, item)
+	removal.PlaidAlreadyRemoved = alreadyRemoved
+	return removal, nil
+}
+
+// newItemRemoval builds the removal record of item
+func newItemRemoval(userID int, item *models.Item) *models.ItemRemoval {
+	removal := &models.ItemRemoval{
+		ItemID:      item.ID,
+		UserID:      userID,
+		PlaidItemID: item.PlaidItemID,
+	}
+	if item.PlaidInstitutionID != "" {
+		removal.PlaidInstitutionID = &item.PlaidInstitutionID
+	}
+	return removal
+}
+
+// deleteRemovedItem deletes an item and records its removal, which is pending if it carries the
+// item's access token
+// Accounts, transactions and sync runs go with the item through ON DELETE CASCADE
+func deleteRemovedItem(ctx context.Context, tx *db.Store, removal *models.ItemRemoval) error {
+	if err := tx.DeleteItem(ctx, removal.ItemID); err != nil {
+		return fmt.Errorf("failed to delete item: %w", err)
+	}
+	if err := tx.CreateItemRemoval(ctx, removal); err != nil {
+		return fmt.Errorf("failed to record item removal: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	plaidpkg "compound/go-server/internal/plaid"
+	"compound/go-server/pkg/models"
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// TestLinkSyncList links an item for two users, syncs both and lists each user's transactions
+// Every Fake item is seeded with the same data, so this also checks items don't take over each
+// other's accounts or transactions
+func TestLinkSyncList(t *testing.T) {
+	s := newTestServer(t)
+
+	type user struct {
+		id     int
+		token  string
+		itemID int
+	}
+	var users []user
+	for _, username := range []string{"alice", "bob"} {
+		id, token := s.signUp(t, username)
+		users = append(users, user{id: id, token: token, itemID: s.linkItem(t, token)})
+	}
+
+	for _, u := range users {
+		if added := s.syncItem(t, u.token, u.itemID); added != testSyncSampleCount {
+			t.Fatalf("item %d: synced %d transactions, want %d", u.itemID, added, testSyncSampleCount)
+		}
+	}
+
+	for _, u := range users {
+		var accounts struct {
+			Accounts []struct {
+				ID int `json:"id"`
+			} `json:"accounts"`
+		}
+		s.doExpect(t, http.MethodGet, fmt.Sprintf("/api/items/%d/accounts", u.itemID), u.token, nil, http.StatusOK, &accounts)
+		if len(accounts.Accounts) != 2 {
+			t.Fatalf("item %d has %d accounts, want 2", u.itemID, len(accounts.Accounts))
+		}
+
+		var page struct {
+			Transactions []struct {
+				AccountID int `json:"account_id"`
+			} `json:"transactions"`
+			TotalCount int `json:"total_count"`
+		}
+		s.doExpect(t, http.MethodGet, fmt.Sprintf("/api/transactions/%d", u.id), u.token, nil, http.StatusOK, &page)
+		if page.TotalCount != testSyncSampleCount || len(page.Transactions) != testSyncSampleCount {
+			t.Fatalf("user %d sees %d of %d transactions, want %d", u.id, len(page.Transactions), page.TotalCount, testSyncSampleCount)
+		}
+
+		owned := map[int]bool{}
+		for _, account := range accounts.Accounts {
+			owned[account.ID] = true
+		}
+		for _, tx := range page.Transactions {
+			if !owned[tx.AccountID] {
+				t.Fatalf("user %d sees a transaction on account %d, which isn't theirs", u.id, tx.AccountID)
+			}
+		}
+	}
+}
+
+// TestReplaceItem links an institution twice, replacing the first item with the second
+// The old item is gone here and at Plaid, and its removal is recorded as complete
+func TestReplaceItem(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+
+	_, token := s.signUp(t, "alice")
+	oldItemID := s.linkItem(t, token)
+	oldItem, err := s.store.GetItemByID(ctx, oldItemID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	link := map[string]any{"publicToken": "public-fake-token", "institutionId": plaidpkg.FakeInstitutionID}
+	var duplicate struct {
+		Code string `json:"code"`
+		Item struct {
+			ID int `json:"id"`
+		} `json:"item"`
+	}
+	s.doExpect(t, http.MethodPost, "/api/items", token, link, http.StatusConflict, &duplicate)
+	if duplicate.Item.ID != oldItemID {
+		t.Fatalf("duplicate item is %d, want %d", duplicate.Item.ID, oldItemID)
+	}
+
+	link["replaceItemId"] = oldItemID
+	var replaced struct {
+		ItemID         int `json:"item_id"`
+		ReplacedItemID int `json:"replaced_item_id"`
+	}
+	s.doExpect(t, http.MethodPost, "/api/items", token, link, http.StatusOK, &replaced)
+	if replaced.ReplacedItemID != oldItemID || replaced.ItemID == oldItemID {
+		t.Fatalf("replaced item %d with %d, want %d replaced by a new item", replaced.ReplacedItemID, replaced.ItemID, oldItemID)
+	}
+
+	s.doExpect(t, http.MethodGet, fmt.Sprintf("/api/items/%d/accounts", oldItemID), token, nil, http.StatusNotFound, nil)
+	s.doExpect(t, http.MethodGet, fmt.Sprintf("/api/items/%d/accounts", replaced.ItemID), token, nil, http.StatusOK, nil)
+
+	if _, err := s.fake.GetAccounts(ctx, oldItem.PlaidAccessToken); plaidpkg.ErrorCode(err) != plaidpkg.ErrorCodeItemNotFound {
+		t.Fatalf("old item at Plaid: got %v, want %s", err, plaidpkg.ErrorCodeItemNotFound)
+	}
+	pending, err := s.store.GetPendingItemRemovals(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Fatalf("%d item removals still pending, want 0", len(pending))
+	}
+}
+
+// TestReplaceItemMustBeTheDuplicate checks replaceItemId can't name any item but the duplicate
+func TestReplaceItemMustBeTheDuplicate(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+
+	aliceID, alice := s.signUp(t, "alice")
+	_, bob := s.signUp(t, "bob")
+	duplicateID := s.linkItem(t, alice)
+	bobsItemID := s.linkItem(t, bob)
+
+	// an item of alice's at another institution
+	otherToken := s.fake.AddItem(plaidpkg.FakeItem{InstitutionID: "ins_other"})
+	other, err := s.store.CreateItem(ctx, aliceID, otherToken, "item-other", "ins_other", models.ItemStatusGood)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	link := map[string]any{"publicToken": "public-fake-token", "institutionId": plaidpkg.FakeInstitutionID}
+
+	link["replaceItemId"] = other.ID
+	var conflict struct {
+		Item struct {
+			ID int `json:"id"`
+		} `json:"item"`
+	}
+	s.doExpect(t, http.MethodPost, "/api/items", alice, link, http.StatusConflict, &conflict)
+	if conflict.Item.ID != duplicateID {
+		t.Fatalf("conflict names item %d, want the duplicate %d", conflict.Item.ID, duplicateID)
+	}
+
+	link["replaceItemId"] = bobsItemID
+	s.doExpect(t, http.MethodPost, "/api/items", alice, link, http.StatusNotFound, nil)
+
+	// with no duplicate at the institution there's nothing to replace
+	link["institutionId"] = "ins_none"
+	link["replaceItemId"] = duplicateID
+	s.doExpect(t, http.MethodPost, "/api/items", alice, link, http.StatusBadRequest, nil)
+
+	if _, err := s.fake.GetAccounts(ctx, otherToken); err != nil {
+		t.Fatalf("an item that wasn't replaced was removed from Plaid: %v", err)
+	}
+	var items struct {
+		Items []struct {
+			ID int `json:"id"`
+		} `json:"items"`
+	}
+	s.doExpect(t, http.MethodGet, "/api/items", alice, nil, http.StatusOK, &items)
+	if len(items.Items) != 2 {
+		t.Fatalf("alice has %d items, want her 2 untouched", len(items.Items))
+	}
+}
+
+// TestLinkWrongInstitution links an item claiming another institution, which would skip the
+// duplicate check; the item is refused and removed from Plaid again
+func TestLinkWrongInstitution(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+
+	_, token := s.signUp(t, "alice")
+	s.linkItem(t, token)
+
+	link := map[string]string{"publicToken": "public-fake-token", "institutionId": "ins_other"}
+	s.doExpect(t, http.MethodPost, "/api/items", token, link, http.StatusBadRequest, nil)
+
+	if _, err := s.fake.GetAccounts(ctx, plaidpkg.FakeAccessToken(2)); plaidpkg.ErrorCode(err) != plaidpkg.ErrorCodeItemNotFound {
+		t.Fatalf("refused item at Plaid: got %v, want %s", err, plaidpkg.ErrorCodeItemNotFound)
+	}
+
+	var items struct {
+		Items []struct {
+			ID int `json:"id"`
+		} `json:"items"`
+	}
+	s.doExpect(t, http.MethodGet, "/api/items", token, nil, http.StatusOK, &items)
+	if len(items.Items) != 1 {
+		t.Fatalf("alice has %d items, want only the first", len(items.Items))
+	}
+	pending, err := s.store.GetPendingItemRemovals(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Fatalf("%d item removals still pending, want 0", len(pending))
+	}
+}
+
+// TestLinkFailureDiscardsItem fails each step after the public token is exchanged; the request
+// fails and the exchanged item, which nothing here refers to, is removed from Plaid again
+func TestLinkFailureDiscardsItem(t *testing.T) {
+	plaidDown := plaidpkg.NewFakeError("API_ERROR", "INTERNAL_SERVER_ERROR", "an unexpected error occurred")
+
+	tests := []struct {
+		name string
+		fail func(s *testServer, userID int)
+		want int
+	}{
+		{"get item", func(s *testServer, userID int) { s.fake.FailNext("GetItem", plaidDown) }, http.StatusBadGateway},
+		{"get accounts", func(s *testServer, userID int) { s.fake.FailNext("GetAccounts", plaidDown) }, http.StatusBadGateway},
+		{"store item", func(s *testServer, userID int) {
+			// an item already holding the Plaid item ID the exchange returns fails the insert
+			if _, err := s.store.CreateItem(context.Background(), userID, "access-taken", "item-fake-1", "ins_other", models.ItemStatusGood); err != nil {
+				t.Fatal(err)
+			}
+		}, http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			ctx := context.Background()
+
+			userID, token := s.signUp(t, "alice")
+			tt.fail(s, userID)
+
+			link := map[string]string{"publicToken": "public-fake-token", "institutionId": plaidpkg.FakeInstitutionID}
+			s.doExpect(t, http.MethodPost, "/api/items", token, link, tt.want, nil)
+
+			if _, err := s.fake.GetAccounts(ctx, plaidpkg.FakeAccessToken(1)); plaidpkg.ErrorCode(err) != plaidpkg.ErrorCodeItemNotFound {
+				t.Fatalf("exchanged item at Plaid: got %v, want %s", err, plaidpkg.ErrorCodeItemNotFound)
+			}
+			items, err := s.store.GetItemsByPlaidInstitutionID(ctx, userID, plaidpkg.FakeInstitutionID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(items) != 0 {
+				t.Fatalf("alice has %d items at %s, want none", len(items), plaidpkg.FakeInstitutionID)
+			}
+			pending, err := s.store.GetPendingItemRemovals(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(pending) != 0 {
+				t.Fatalf("%d item removals still pending, want 0", len(pending))
+			}
+		})
+	}
+}
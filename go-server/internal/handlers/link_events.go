@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"compound/go-server/internal/redact"
+	"compound/go-server/pkg/models"
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxLinkEventTypeLength bounds the event type, Link's own event names are far shorter
+const maxLinkEventTypeLength = 64
+
+// LinkEventRequest represents the request body for logging a Plaid Link callback
+// Field names match Link's metadata so the client can pass it through
+type LinkEventRequest struct {
+	Type          string `json:"type" binding:"required"`
+	LinkSessionID string `json:"link_session_id"`
+	RequestID     string `json:"request_id"`
+	ErrorType     string `json:"error_type"`
+	ErrorCode     string `json:"error_code"`
+	Status        string `json:"status"`
+}
+
+// CreateLinkEvent handles POST /api/link-events
+// Records a Plaid Link onSuccess/onExit/onEvent callback for the current user
+//
+// Request body:
+// {
+//   "type": "exit",  // "success", "exit", or the onEvent event name
+//   "link_session_id": "...",
+//   "request_id": "...",
+//   "error_type": "ITEM_ERROR",
+//   "error_code": "INVALID_CREDENTIALS",
+//   "status": "requires_credentials"
+// }
+func (h *Handler) CreateLinkEvent(c *gin.Context) {
+	var req LinkEventRequest
+
+	// Parse request body
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.Type) > maxLinkEventTypeLength {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "type is required and must be at most 64 characters",
+		})
+		return
+	}
+
+	userID := currentUser(c).ID
+	event := &models.LinkEvent{
+		Type:          req.Type,
+		UserID:        &userID,
+		LinkSessionID: optionalString(req.LinkSessionID),
+		RequestID:     optionalString(req.RequestID),
+		ErrorType:     optionalString(req.ErrorType),
+		ErrorCode:     optionalString(req.ErrorCode),
+		Status:        optionalString(req.Status),
+	}
+
+	if err := h.store.CreateLinkEvent(context.Background(), event); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to store link event: " + redact.Error(err),
+		})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// optionalString maps an empty string to nil, for nullable columns
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// linkEventList is the body of GET /api/admin/link-events
+type linkEventList struct {
+	Events []struct {
+		Type          string  `json:"type"`
+		UserID        *int    `json:"user_id"`
+		LinkSessionID *string `json:"link_session_id"`
+		RequestID     *string `json:"request_id"`
+		ErrorCode     *string `json:"error_code"`
+	} `json:"events"`
+}
+
+// types lists the events' types, newest first
+func (l linkEventList) types() []string {
+	var types []string
+	for _, event := range l.Events {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+// TestCreateLinkEvent checks every Link callback is stored, repeats of a request ID included,
+// and a callback without a type is rejected
+func TestCreateLinkEvent(t *testing.T) {
+	s := newTestServer(t)
+	aliceID, alice := s.signUp(t, "alice")
+	adminID, admin := s.signUp(t, "admin")
+	makeAdmin(t, adminID)
+
+	// Link reports a failed request as an ERROR event, then again on exit
+	s.doExpect(t, http.MethodPost, "/api/link-events", alice, map[string]string{
+		"type": "ERROR", "link_session_id": "session-1", "request_id": "req-1",
+		"error_type": "ITEM_ERROR", "error_code": "INVALID_CREDENTIALS",
+	}, http.StatusNoContent, nil)
+	s.doExpect(t, http.MethodPost, "/api/link-events", alice, map[string]string{
+		"type": "exit", "link_session_id": "session-1", "request_id": "req-1",
+		"error_type": "ITEM_ERROR", "error_code": "INVALID_CREDENTIALS", "status": "requires_credentials",
+	}, http.StatusNoContent, nil)
+
+	s.doExpect(t, http.MethodPost, "/api/link-events", alice, map[string]string{"link_session_id": "session-1"}, http.StatusBadRequest, nil)
+	s.doExpect(t, http.MethodPost, "/api/link-events", alice, map[string]string{"type": strings.Repeat("x", 65)}, http.StatusBadRequest, nil)
+	s.doExpect(t, http.MethodPost, "/api/link-events", "", map[string]string{"type": "exit"}, http.StatusUnauthorized, nil)
+
+	var list linkEventList
+	s.doExpect(t, http.MethodGet, "/api/admin/link-events", admin, nil, http.StatusOK, &list)
+	if got, want := list.types(), []string{"exit", "ERROR"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+	for _, event := range list.Events {
+		if event.UserID == nil || *event.UserID != aliceID || event.RequestID == nil || *event.RequestID != "req-1" {
+			t.Fatalf("event %s not recorded for alice's req-1: %+v", event.Type, event)
+		}
+	}
+}
+
+// TestGetLinkEventsFilters checks the admin link event listing filters by user, Link session and
+// errors, honours the limit, and is for admins only
+func TestGetLinkEventsFilters(t *testing.T) {
+	s := newTestServer(t)
+	aliceID, alice := s.signUp(t, "alice")
+	_, bob := s.signUp(t, "bob")
+	adminID, admin := s.signUp(t, "admin")
+	makeAdmin(t, adminID)
+
+	events := []struct {
+		token string
+		body  map[string]string
+	}{
+		{alice, map[string]string{"type": "OPEN", "link_session_id": "alice-1"}},
+		{alice, map[string]string{"type": "exit", "link_session_id": "alice-1", "error_code": "INVALID_CREDENTIALS"}},
+		{alice, map[string]string{"type": "success", "link_session_id": "alice-2"}},
+		{bob, map[string]string{"type": "exit", "link_session_id": "bob-1", "error_code": "INSTITUTION_DOWN"}},
+	}
+	for _, event := range events {
+		s.doExpect(t, http.MethodPost, "/api/link-events", event.token, event.body, http.StatusNoContent, nil)
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"exit", "success", "exit", "OPEN"}},
+		{fmt.Sprintf("?user_id=%d", aliceID), []string{"success", "exit", "OPEN"}},
+		{"?link_session_id=alice-1", []string{"exit", "OPEN"}},
+		{"?errors_only=true", []string{"exit", "exit"}},
+		{fmt.Sprintf("?user_id=%d&errors_only=true", aliceID), []string{"exit"}},
+		{"?limit=2", []string{"exit", "success"}},
+		{"?link_session_id=nobody", nil},
+	}
+	for _, tt := range tests {
+		var list linkEventList
+		s.doExpect(t, http.MethodGet, "/api/admin/link-events"+tt.query, admin, nil, http.StatusOK, &list)
+		if got := list.types(); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("link-events%s = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	s.doExpect(t, http.MethodGet, "/api/admin/link-events?user_id=alice", admin, nil, http.StatusBadRequest, nil)
+	s.doExpect(t, http.MethodGet, "/api/admin/link-events?limit=0", admin, nil, http.StatusBadRequest, nil)
+	s.doExpect(t, http.MethodGet, "/api/admin/link-events", alice, nil, http.StatusForbidden, nil)
+}
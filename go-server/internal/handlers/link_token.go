@@ -1,27 +1,30 @@
 package handlers
 
 import (
-	"compound/go-server/internal/db"
 	plaidpkg "compound/go-server/internal/plaid"
+	"compound/go-server/internal/redact"
 	"context"
+	"errors"
+	"io"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
 // LinkTokenRequest represents the request body for creating a link token
+// The token is always created for the logged in user
 type LinkTokenRequest struct {
-	UserID int  `json:"userId" binding:"required"`
-	ItemID *int `json:"itemId"`
+	ItemID           *int `json:"itemId"`
+	AccountSelection bool `json:"accountSelection"`
 }
 
 // MakeLinkTokenHandler creates a handler for POST /api/link-token
 // Generates a Plaid Link token for account linking (normal mode) or updating (update mode)
 //
-// Request body:
+// Request body (optional):
 // {
-//   "userId": 123,
-//   "itemId": null  // omit or null for normal mode, set for update mode
+//   "itemId": null,            // omit or null for normal mode, set for update mode
+//   "accountSelection": false  // update mode only, lets the user share newly opened accounts
 // }
 //
 // Response:
@@ -30,42 +33,51 @@ type LinkTokenRequest struct {
 // }
 //
 // The handler uses closures to capture plaid configuration from main.go
-func MakeLinkTokenHandler(plaidProducts, plaidCountryCodes, plaidRedirectURI string) gin.HandlerFunc {
+func (h *Handler) MakeLinkTokenHandler(plaidProducts, plaidCountryCodes, plaidRedirectURI, plaidWebhookURL string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req LinkTokenRequest
+		user := currentUser(c)
 
-		// Parse request body
-		if err := c.ShouldBindJSON(&req); err != nil {
+		// Parse request body, an empty body means normal mode
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
 			c.JSON(http.StatusBadRequest, gin.H{
-				"error": "userId is required",
+				"error": "invalid request body",
 			})
 			return
 		}
 
 		// Determine mode based on presence of itemId
 		var products []string
+		var itemID int
+		var update *plaidpkg.LinkTokenUpdate
 
 		if req.ItemID != nil && *req.ItemID != 0 {
 			// Update mode: re-linking existing item
-			// Fetch the item to validate it exists
-			item, err := db.GetItemByID(context.Background(), *req.ItemID)
-			if err != nil {
-				c.JSON(http.StatusNotFound, gin.H{
-					"error": "item not found",
-				})
+			// Verify the item exists and belongs to this user
+			if !h.checkOwner(c, "item", *req.ItemID, h.store.GetItemOwnerID) {
 				return
 			}
+			itemID = *req.ItemID
 
-			// Verify the item belongs to this user
-			if item.UserID != req.UserID {
-				c.JSON(http.StatusForbidden, gin.H{
-					"error": "item does not belong to this user",
+			item, err := h.store.GetItemByID(context.Background(), itemID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "failed to get item: " + redact.Error(err),
 				})
 				return
 			}
+			update = &plaidpkg.LinkTokenUpdate{
+				AccessToken:      item.PlaidAccessToken,
+				AccountSelection: req.AccountSelection,
+			}
 
 			// Empty products array for update mode - only updating connection, not adding new products
 			products = []string{}
+		} else if req.AccountSelection {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "accountSelection requires itemId",
+			})
+			return
 		} else {
 			// Normal mode: new account linking
 			// Must include transactions product for webhooks
@@ -76,18 +88,18 @@ func MakeLinkTokenHandler(plaidProducts, plaidCountryCodes, plaidRedirectURI str
 		countryCodes := []string{plaidCountryCodes}
 
 		// Create the link token via plaid package
-		linkToken, err := plaidpkg.CreateLinkToken(
-			context.Background(),
-			req.UserID,
+		linkToken, err := h.aggregator.CreateLinkToken(
+			plaidpkg.WithEventScope(context.Background(), user.ID, itemID),
+			user.ID,
 			products,
 			countryCodes,
 			plaidRedirectURI,
+			plaidWebhookURL,
+			update,
 		)
 
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": err.Error(),
-			})
+			respondError(c, http.StatusBadGateway, "failed to create link token", err)
 			return
 		}
 
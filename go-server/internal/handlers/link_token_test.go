@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+)
+
+// TestCreateLinkToken checks the link token modes, account selection only updating an item
+func TestCreateLinkToken(t *testing.T) {
+	s := newTestServer(t)
+	_, alice := s.signUp(t, "alice")
+	_, bob := s.signUp(t, "bob")
+	itemID := s.linkItem(t, alice)
+	bobItemID := s.linkItem(t, bob)
+
+	tests := []struct {
+		name string
+		body any
+		want int
+	}{
+		{"new item", nil, http.StatusOK},
+		{"update mode", map[string]any{"itemId": itemID}, http.StatusOK},
+		{"account selection", map[string]any{"itemId": itemID, "accountSelection": true}, http.StatusOK},
+		{"account selection without item", map[string]any{"accountSelection": true}, http.StatusBadRequest},
+		{"another user's item", map[string]any{"itemId": bobItemID, "accountSelection": true}, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		var resp struct {
+			LinkToken string `json:"link_token"`
+		}
+		s.doExpect(t, http.MethodPost, "/api/link-token", alice, tt.body, tt.want, &resp)
+		if tt.want == http.StatusOK && resp.LinkToken == "" {
+			t.Errorf("%s: no link token", tt.name)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"compound/go-server/internal/redact"
+	"compound/go-server/internal/services"
+	"compound/go-server/pkg/models"
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Series length limits for GET /api/users/:id/net-worth, in periods of the interval
+var netWorthPeriods = map[string]struct{ def, max int }{
+	services.NetWorthIntervalDaily:   {def: 30, max: 730},
+	services.NetWorthIntervalMonthly: {def: 12, max: 120},
+}
+
+// NetWorthPointResponse is one point of a net worth series
+type NetWorthPointResponse struct {
+	Date        string       `json:"date"`
+	Assets      models.Money `json:"assets"`
+	Liabilities models.Money `json:"liabilities"`
+	NetWorth    models.Money `json:"net_worth"`
+}
+
+// MakeNetWorthHandler creates a handler for GET /api/users/:id/net-worth?interval=daily&periods=30
+// Returns the current user's net worth in the server's net worth currency. Depository and
+// investment balances count as assets, credit and loan balances as liabilities, manual assets
+// are added to assets. interval is daily or monthly.
+//
+// Response:
+// {
+//   "currency": "USD",
+//   "assets": 15250.00,
+//   "account_assets": 5250.00,
+//   "manual_assets": 10000.00,
+//   "liabilities": 1200.00,
+//   "net_worth": 14050.00,
+//   "unconverted_currencies": [],   // balances in these currencies had no exchange rate and are left out
+//   "interval": "daily",
+//   "series": [
+//     { "date": "2025-01-01", "assets": 15100.00, "liabilities": 1150.00, "net_worth": 13950.00 },
+//     ...
+//   ]
+// }
+//
+// The handler uses a closure to capture the calculator configured in main.go
+func (h *Handler) MakeNetWorthHandler(calculator *services.NetWorthCalculator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		interval := c.DefaultQuery("interval", services.NetWorthIntervalDaily)
+		limits, ok := netWorthPeriods[interval]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "interval must be daily or monthly",
+			})
+			return
+		}
+
+		periods := limits.def
+		if periodsStr := c.Query("periods"); periodsStr != "" {
+			n, err := strconv.Atoi(periodsStr)
+			if err != nil || n < 1 || n > limits.max {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": "periods must be between 1 and " + strconv.Itoa(limits.max),
+				})
+				return
+			}
+			periods = n
+		}
+
+		netWorth, err := calculator.Calculate(context.Background(), currentUser(c).ID, interval, periods)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "failed to calculate net worth: " + redact.Error(err),
+			})
+			return
+		}
+
+		series := make([]NetWorthPointResponse, 0, len(netWorth.Series))
+		for _, point := range netWorth.Series {
+			series = append(series, NetWorthPointResponse{
+				Date:        point.Date.Format(time.DateOnly),
+				Assets:      point.Assets,
+				Liabilities: point.Liabilities,
+				NetWorth:    point.Assets.Sub(point.Liabilities),
+			})
+		}
+
+		unconverted := netWorth.UnconvertedCurrencies
+		if unconverted == nil {
+			unconverted = []string{}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"currency":               netWorth.Currency,
+			"assets":                 netWorth.Assets(),
+			"account_assets":         netWorth.AccountAssets,
+			"manual_assets":          netWorth.ManualAssets,
+			"liabilities":            netWorth.Liabilities,
+			"net_worth":              netWorth.Total(),
+			"unconverted_currencies": unconverted,
+			"interval":               interval,
+			"series":                 series,
+		})
+	}
+}
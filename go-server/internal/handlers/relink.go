@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"compound/go-server/internal/db"
+	plaidpkg "compound/go-server/internal/plaid"
+	"compound/go-server/internal/redact"
+	"compound/go-server/internal/services"
+	"compound/go-server/pkg/models"
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CompleteRelink handles POST /api/items/:id/relink-complete
+// Called by the client after Link update mode succeeds for one of the current user's items.
+// Re-fetches the item and its accounts from Plaid, stores accounts the user newly shared,
+// closes accounts that are no longer shared, moves the item back to good, clears its
+// new_accounts_available flag and starts a sync in the background.
+//
+// Response:
+// {
+//   "item": { ...ItemResponse },
+//   "accounts": [ ...AccountResponse ],   // every account of the item, closed ones included
+//   "new_accounts": 1,
+//   "closed_accounts": 0
+// }
+func (h *Handler) CompleteRelink(c *gin.Context) {
+	user := currentUser(c)
+
+	item, err := h.store.GetItemByID(context.Background(), ownedItemID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to get item: " + redact.Error(err),
+		})
+		return
+	}
+
+	plaidCtx := plaidpkg.WithEventScope(context.Background(), user.ID, item.ID)
+
+	// fails with the item's error (e.g. ITEM_LOGIN_REQUIRED) if update mode didn't fix it
+	if _, err := h.aggregator.GetItem(plaidCtx, item.PlaidAccessToken); err != nil {
+		h.respondItemError(c, item, http.StatusBadGateway, "failed to get item details", err)
+		return
+	}
+
+	plaidAccounts, err := h.aggregator.GetAccounts(plaidCtx, item.PlaidAccessToken)
+	if err != nil {
+		h.respondItemError(c, item, http.StatusBadGateway, "failed to get accounts", err)
+		return
+	}
+
+	existing, err := h.store.GetAccountsByItemID(context.Background(), item.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to get accounts: " + redact.Error(err),
+		})
+		return
+	}
+	known := make(map[string]bool, len(existing))
+	for _, account := range existing {
+		known[account.PlaidAccountID] = true
+	}
+
+	newAccounts := 0
+	var closedAccounts int64
+	err = h.store.WithTx(context.Background(), func(tx *db.Store) error {
+		plaidAccountIDs := make([]string, 0, len(plaidAccounts))
+		for _, account := range plaidAccounts {
+			if _, err := services.StoreAccount(context.Background(), tx, item.ID, account); err != nil {
+				return err
+			}
+			plaidAccountIDs = append(plaidAccountIDs, account.GetAccountId())
+			if !known[account.GetAccountId()] {
+				newAccounts++
+			}
+		}
+
+		closedAccounts, err = tx.CloseMissingAccounts(context.Background(), item.ID, plaidAccountIDs)
+		if err != nil {
+			return fmt.Errorf("failed to close accounts: %w", err)
+		}
+
+		if err := tx.UpdateItemStatus(context.Background(), item.ID, models.ItemStatusGood); err != nil {
+			return fmt.Errorf("failed to update item status: %w", err)
+		}
+
+		// every account the user wanted to share is stored now
+		if err := tx.UpdateItemNewAccountsAvailable(context.Background(), item.ID, false); err != nil {
+			return fmt.Errorf("failed to update item: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": redact.Error(err),
+		})
+		return
+	}
+
+	h.syncer.SyncInBackground(item.ID, models.SyncTriggerRelink)
+
+	item, err = h.store.GetItemByID(context.Background(), item.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to get item: " + redact.Error(err),
+		})
+		return
+	}
+	accounts, err := h.store.GetAccountsByItemID(context.Background(), item.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to get accounts: " + redact.Error(err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"item":            newItemResponse(item),
+		"accounts":        newAccountResponses(accounts),
+		"new_accounts":    newAccounts,
+		"closed_accounts": closedAccounts,
+	})
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"compound/go-server/pkg/models"
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestCompleteRelink has the user share a new account and stop sharing another through update
+// mode, then checks relink-complete stores the new one, closes the other, moves the item back to
+// good and syncs it
+func TestCompleteRelink(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	_, alice := s.signUp(t, "alice")
+	itemID := s.linkItem(t, alice)
+
+	item, err := s.store.GetItemByID(ctx, itemID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.store.UpdateItemStatus(ctx, itemID, models.ItemStatusLoginRequired); err != nil {
+		t.Fatal(err)
+	}
+
+	// in update mode the user keeps checking, drops savings and adds a credit card
+	fakeItem, ok := s.fake.Item(item.PlaidAccessToken)
+	if !ok {
+		t.Fatal("linked item missing from the fake")
+	}
+	checking, savings := fakeItem.Accounts[0], fakeItem.Accounts[1]
+	card := checking
+	card.SetAccountId(item.PlaidItemID + "-card")
+	card.SetName("Fake Card")
+	card.SetMask("0003")
+	fakeItem.Accounts = append(fakeItem.Accounts[:1:1], card)
+
+	var relinked struct {
+		Item struct {
+			Status string `json:"status"`
+		} `json:"item"`
+		Accounts []struct {
+			PlaidAccountID string     `json:"plaid_account_id"`
+			ClosedAt       *time.Time `json:"closed_at"`
+		} `json:"accounts"`
+		NewAccounts    int `json:"new_accounts"`
+		ClosedAccounts int `json:"closed_accounts"`
+	}
+	s.doExpect(t, http.MethodPost, fmt.Sprintf("/api/items/%d/relink-complete", itemID), alice, nil, http.StatusOK, &relinked)
+
+	if relinked.Item.Status != models.ItemStatusGood {
+		t.Errorf("status = %s, want %s", relinked.Item.Status, models.ItemStatusGood)
+	}
+	if relinked.NewAccounts != 1 || relinked.ClosedAccounts != 1 {
+		t.Errorf("new_accounts = %d, closed_accounts = %d, want 1 and 1", relinked.NewAccounts, relinked.ClosedAccounts)
+	}
+
+	closed := map[string]bool{}
+	for _, account := range relinked.Accounts {
+		closed[account.PlaidAccountID] = account.ClosedAt != nil
+	}
+	want := map[string]bool{checking.GetAccountId(): false, savings.GetAccountId(): true, card.GetAccountId(): false}
+	if fmt.Sprint(closed) != fmt.Sprint(want) {
+		t.Errorf("accounts closed = %v, want %v", closed, want)
+	}
+
+	s.waitForSyncRun(t, itemID, models.SyncTriggerRelink)
+}
@@ -0,0 +1,423 @@
+package handlers
+
+import (
+	"compound/go-server/internal/db"
+	plaidpkg "compound/go-server/internal/plaid"
+	"compound/go-server/internal/redact"
+	"compound/go-server/pkg/models"
+	"time"
+)
+
+// Response DTOs
+// Handlers never serialize models directly, so fields like the Plaid access token or the
+// sync cursor can't leak into a response when a model grows a new field
+
+// UserResponse is the public view of a user
+type UserResponse struct {
+	ID        int       `json:"id"`
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ItemResponse is the public view of an item
+type ItemResponse struct {
+	ID                 int        `json:"id"`
+	UserID             int        `json:"user_id"`
+	PlaidItemID        string     `json:"plaid_item_id"`
+	PlaidInstitutionID string     `json:"plaid_institution_id"`
+	Status             string     `json:"status"`
+	LastSyncedAt       *time.Time `json:"last_synced_at"`
+	// NewAccountsAvailable means the client should offer account selection update mode
+	NewAccountsAvailable bool      `json:"new_accounts_available"`
+	CreatedAt            time.Time `json:"created_at"`
+	UpdatedAt            time.Time `json:"updated_at"`
+}
+
+// AccountResponse is the public view of an account
+type AccountResponse struct {
+	ID                     int           `json:"id"`
+	ItemID                 int           `json:"item_id"`
+	PlaidAccountID         string        `json:"plaid_account_id"`
+	Name                   string        `json:"name"`
+	Mask                   string        `json:"mask"`
+	OfficialName           *string       `json:"official_name"`
+	CurrentBalance         *models.Money `json:"current_balance"`
+	AvailableBalance       *models.Money `json:"available_balance"`
+	IsoCurrencyCode        *string       `json:"iso_currency_code"`
+	UnofficialCurrencyCode *string       `json:"unofficial_currency_code"`
+	Type                   string        `json:"type"`
+	Subtype                string        `json:"subtype"`
+	CreatedAt              time.Time     `json:"created_at"`
+	UpdatedAt              time.Time     `json:"updated_at"`
+	ClosedAt               *time.Time    `json:"closed_at"`
+}
+
+// TransactionResponse is the public view of a transaction
+type TransactionResponse struct {
+	ID                     int          `json:"id"`
+	AccountID              int          `json:"account_id"`
+	PlaidTransactionID     string       `json:"plaid_transaction_id"`
+	PlaidCategoryID        *string      `json:"plaid_category_id"`
+	Category               *string      `json:"category"`
+	Type                   string       `json:"type"`
+	Name                   string       `json:"name"`
+	MerchantName           *string      `json:"merchant_name"`
+	Notes                  *string      `json:"notes"`
+	Amount                 models.Money `json:"amount"`
+	IsoCurrencyCode        *string      `json:"iso_currency_code"`
+	UnofficialCurrencyCode *string      `json:"unofficial_currency_code"`
+	Date                   string       `json:"date"`
+	Pending                bool         `json:"pending"`
+	AccountOwner           *string      `json:"account_owner"`
+	CreatedAt              time.Time    `json:"created_at"`
+	UpdatedAt              time.Time    `json:"updated_at"`
+}
+
+// TransactionSearchResultResponse is a transaction matching a search
+// Snippet holds the matching text as HTML, escaped, with each match wrapped in <mark></mark>.
+// Markup in the transaction's own text comes out escaped, so the snippet is safe to render as HTML.
+type TransactionSearchResultResponse struct {
+	Transaction TransactionResponse `json:"transaction"`
+	Rank        float64             `json:"rank"`
+	Snippet     string              `json:"snippet"`
+}
+
+// SyncRunResponse is the public view of a sync run
+// Plaid cursors are opaque internal state and are left out, like on ItemResponse
+type SyncRunResponse struct {
+	ID            int        `json:"id"`
+	ItemID        int        `json:"item_id"`
+	Trigger       string     `json:"trigger"`
+	StartedAt     time.Time  `json:"started_at"`
+	FinishedAt    *time.Time `json:"finished_at"`
+	Pages         int        `json:"pages"`
+	AddedCount    int        `json:"added_count"`
+	ModifiedCount int        `json:"modified_count"`
+	RemovedCount  int        `json:"removed_count"`
+	ErrorCode     *string    `json:"error_code"`
+	ErrorMessage  *string    `json:"error_message"`
+}
+
+// PlaidAPIEventResponse is the admin view of a recorded Plaid API call
+type PlaidAPIEventResponse struct {
+	ID           int       `json:"id"`
+	ItemID       *int      `json:"item_id"`
+	UserID       *int      `json:"user_id"`
+	PlaidMethod  string    `json:"plaid_method"`
+	Arguments    *string   `json:"arguments"`
+	RequestID    *string   `json:"request_id"`
+	ErrorType    *string   `json:"error_type"`
+	ErrorCode    *string   `json:"error_code"`
+	ErrorMessage *string   `json:"error_message"`
+	DurationMs   *int      `json:"duration_ms"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// LinkEventResponse is the admin view of a Plaid Link event
+type LinkEventResponse struct {
+	ID            int       `json:"id"`
+	Type          string    `json:"type"`
+	UserID        *int      `json:"user_id"`
+	LinkSessionID *string   `json:"link_session_id"`
+	RequestID     *string   `json:"request_id"`
+	ErrorType     *string   `json:"error_type"`
+	ErrorCode     *string   `json:"error_code"`
+	Status        *string   `json:"status"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// BalanceSnapshotResponse is the public view of an account's balances on one day
+type BalanceSnapshotResponse struct {
+	AccountID              int           `json:"account_id"`
+	Date                   string        `json:"date"`
+	CurrentBalance         *models.Money `json:"current_balance"`
+	AvailableBalance       *models.Money `json:"available_balance"`
+	IsoCurrencyCode        *string       `json:"iso_currency_code"`
+	UnofficialCurrencyCode *string       `json:"unofficial_currency_code"`
+	UpdatedAt              time.Time     `json:"updated_at"`
+}
+
+// AssetResponse is the public view of a manual asset
+type AssetResponse struct {
+	ID          int          `json:"id"`
+	UserID      int          `json:"user_id"`
+	Description *string      `json:"description"`
+	Value       models.Money `json:"value"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// AssetValueResponse is the public view of a manual asset's value on one day
+type AssetValueResponse struct {
+	AssetID   int          `json:"asset_id"`
+	Date      string       `json:"date"`
+	Value     models.Money `json:"value"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+// ErrorResponse is the body of an error response
+// Error is always set. PlaidError is set when the request failed because a Plaid API call did,
+// so the client can act on error_code (e.g. launch Link update mode on ITEM_LOGIN_REQUIRED)
+type ErrorResponse struct {
+	Error      string              `json:"error"`
+	PlaidError *PlaidErrorResponse `json:"plaid_error,omitempty"`
+}
+
+// PlaidErrorResponse is the public view of a Plaid API error
+// DisplayMessage is safe to show end users, it's null when the error isn't theirs to fix
+type PlaidErrorResponse struct {
+	ErrorType      string  `json:"error_type"`
+	ErrorCode      string  `json:"error_code"`
+	ErrorMessage   string  `json:"error_message"`
+	DisplayMessage *string `json:"display_message"`
+	RequestID      *string `json:"request_id"`
+}
+
+// DuplicateItemResponse is the 409 body of POST /api/items when the institution is already linked
+// Code is always DUPLICATE_ITEM, Item is the existing item the client can offer to replace
+type DuplicateItemResponse struct {
+	ErrorResponse
+	Code string       `json:"code"`
+	Item ItemResponse `json:"item"`
+}
+
+func newUserResponse(user *models.User) UserResponse {
+	return UserResponse{
+		ID:        user.ID,
+		Username:  user.Username,
+		CreatedAt: user.CreatedAt,
+	}
+}
+
+func newItemResponse(item *models.Item) ItemResponse {
+	return ItemResponse{
+		ID:                   item.ID,
+		UserID:               item.UserID,
+		PlaidItemID:          item.PlaidItemID,
+		PlaidInstitutionID:   item.PlaidInstitutionID,
+		Status:               item.Status,
+		LastSyncedAt:         item.LastSyncedAt,
+		NewAccountsAvailable: item.NewAccountsAvailable,
+		CreatedAt:            item.CreatedAt,
+		UpdatedAt:            item.UpdatedAt,
+	}
+}
+
+func newItemResponses(items []*models.Item) []ItemResponse {
+	responses := make([]ItemResponse, 0, len(items))
+	for _, item := range items {
+		responses = append(responses, newItemResponse(item))
+	}
+	return responses
+}
+
+func newAccountResponse(account *models.Account) AccountResponse {
+	return AccountResponse{
+		ID:                     account.ID,
+		ItemID:                 account.ItemID,
+		PlaidAccountID:         account.PlaidAccountID,
+		Name:                   account.Name,
+		Mask:                   account.Mask,
+		OfficialName:           account.OfficialName,
+		CurrentBalance:         account.CurrentBalance,
+		AvailableBalance:       account.AvailableBalance,
+		IsoCurrencyCode:        account.IsoCurrencyCode,
+		UnofficialCurrencyCode: account.UnofficialCurrencyCode,
+		Type:                   account.Type,
+		Subtype:                account.Subtype,
+		CreatedAt:              account.CreatedAt,
+		UpdatedAt:              account.UpdatedAt,
+		ClosedAt:               account.ClosedAt,
+	}
+}
+
+func newAccountResponses(accounts []*models.Account) []AccountResponse {
+	responses := make([]AccountResponse, 0, len(accounts))
+	for _, account := range accounts {
+		responses = append(responses, newAccountResponse(account))
+	}
+	return responses
+}
+
+func newTransactionResponse(transaction *models.Transaction) TransactionResponse {
+	return TransactionResponse{
+		ID:                     transaction.ID,
+		AccountID:              transaction.AccountID,
+		PlaidTransactionID:     transaction.PlaidTransactionID,
+		PlaidCategoryID:        transaction.PlaidCategoryID,
+		Category:               transaction.Category,
+		Type:                   transaction.Type,
+		Name:                   transaction.Name,
+		MerchantName:           transaction.MerchantName,
+		Notes:                  transaction.Notes,
+		Amount:                 transaction.Amount,
+		IsoCurrencyCode:        transaction.IsoCurrencyCode,
+		UnofficialCurrencyCode: transaction.UnofficialCurrencyCode,
+		Date:                   transaction.Date.Format(time.DateOnly),
+		Pending:                transaction.Pending,
+		AccountOwner:           transaction.AccountOwner,
+		CreatedAt:              transaction.CreatedAt,
+		UpdatedAt:              transaction.UpdatedAt,
+	}
+}
+
+func newTransactionResponses(transactions []*models.Transaction) []TransactionResponse {
+	responses := make([]TransactionResponse, 0, len(transactions))
+	for _, transaction := range transactions {
+		responses = append(responses, newTransactionResponse(transaction))
+	}
+	return responses
+}
+
+func newTransactionSearchResultResponses(results []*db.TransactionSearchResult) []TransactionSearchResultResponse {
+	responses := make([]TransactionSearchResultResponse, 0, len(results))
+	for _, result := range results {
+		responses = append(responses, TransactionSearchResultResponse{
+			Transaction: newTransactionResponse(result.Transaction),
+			Rank:        result.Rank,
+			Snippet:     result.Snippet,
+		})
+	}
+	return responses
+}
+
+func newSyncRunResponse(run *models.SyncRun) SyncRunResponse {
+	return SyncRunResponse{
+		ID:            run.ID,
+		ItemID:        run.ItemID,
+		Trigger:       run.Trigger,
+		StartedAt:     run.StartedAt,
+		FinishedAt:    run.FinishedAt,
+		Pages:         run.Pages,
+		AddedCount:    run.AddedCount,
+		ModifiedCount: run.ModifiedCount,
+		RemovedCount:  run.RemovedCount,
+		ErrorCode:     run.ErrorCode,
+		ErrorMessage:  run.ErrorMessage,
+	}
+}
+
+func newSyncRunResponses(runs []*models.SyncRun) []SyncRunResponse {
+	responses := make([]SyncRunResponse, 0, len(runs))
+	for _, run := range runs {
+		responses = append(responses, newSyncRunResponse(run))
+	}
+	return responses
+}
+
+func newPlaidAPIEventResponse(event *models.PlaidAPIEvent) PlaidAPIEventResponse {
+	return PlaidAPIEventResponse{
+		ID:           event.ID,
+		ItemID:       event.ItemID,
+		UserID:       event.UserID,
+		PlaidMethod:  event.PlaidMethod,
+		Arguments:    event.Arguments,
+		RequestID:    event.RequestID,
+		ErrorType:    event.ErrorType,
+		ErrorCode:    event.ErrorCode,
+		ErrorMessage: event.ErrorMessage,
+		DurationMs:   event.DurationMs,
+		CreatedAt:    event.CreatedAt,
+	}
+}
+
+func newPlaidAPIEventResponses(events []*models.PlaidAPIEvent) []PlaidAPIEventResponse {
+	responses := make([]PlaidAPIEventResponse, 0, len(events))
+	for _, event := range events {
+		responses = append(responses, newPlaidAPIEventResponse(event))
+	}
+	return responses
+}
+
+func newLinkEventResponse(event *models.LinkEvent) LinkEventResponse {
+	return LinkEventResponse{
+		ID:            event.ID,
+		Type:          event.Type,
+		UserID:        event.UserID,
+		LinkSessionID: event.LinkSessionID,
+		RequestID:     event.RequestID,
+		ErrorType:     event.ErrorType,
+		ErrorCode:     event.ErrorCode,
+		Status:        event.Status,
+		CreatedAt:     event.CreatedAt,
+	}
+}
+
+func newLinkEventResponses(events []*models.LinkEvent) []LinkEventResponse {
+	responses := make([]LinkEventResponse, 0, len(events))
+	for _, event := range events {
+		responses = append(responses, newLinkEventResponse(event))
+	}
+	return responses
+}
+
+func newBalanceSnapshotResponse(snapshot *models.AccountBalanceSnapshot) BalanceSnapshotResponse {
+	return BalanceSnapshotResponse{
+		AccountID:              snapshot.AccountID,
+		Date:                   snapshot.Date.Format(time.DateOnly),
+		CurrentBalance:         snapshot.CurrentBalance,
+		AvailableBalance:       snapshot.AvailableBalance,
+		IsoCurrencyCode:        snapshot.IsoCurrencyCode,
+		UnofficialCurrencyCode: snapshot.UnofficialCurrencyCode,
+		UpdatedAt:              snapshot.UpdatedAt,
+	}
+}
+
+func newBalanceSnapshotResponses(snapshots []*models.AccountBalanceSnapshot) []BalanceSnapshotResponse {
+	responses := make([]BalanceSnapshotResponse, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		responses = append(responses, newBalanceSnapshotResponse(snapshot))
+	}
+	return responses
+}
+
+func newAssetResponse(asset *models.Asset) AssetResponse {
+	return AssetResponse{
+		ID:          asset.ID,
+		UserID:      asset.UserID,
+		Description: asset.Description,
+		Value:       asset.Value,
+		CreatedAt:   asset.CreatedAt,
+		UpdatedAt:   asset.UpdatedAt,
+	}
+}
+
+func newAssetResponses(assets []*models.Asset) []AssetResponse {
+	responses := make([]AssetResponse, 0, len(assets))
+	for _, asset := range assets {
+		responses = append(responses, newAssetResponse(asset))
+	}
+	return responses
+}
+
+func newAssetValueResponse(value *models.AssetValue) AssetValueResponse {
+	return AssetValueResponse{
+		AssetID:   value.AssetID,
+		Date:      value.Date.Format(time.DateOnly),
+		Value:     value.Value,
+		UpdatedAt: value.UpdatedAt,
+	}
+}
+
+func newAssetValueResponses(values []*models.AssetValue) []AssetValueResponse {
+	responses := make([]AssetValueResponse, 0, len(values))
+	for _, value := range values {
+		responses = append(responses, newAssetValueResponse(value))
+	}
+	return responses
+}
+
+func newPlaidErrorResponse(plaidErr *plaidpkg.PlaidError) *PlaidErrorResponse {
+	response := &PlaidErrorResponse{
+		ErrorType:    plaidErr.ErrorType,
+		ErrorCode:    plaidErr.ErrorCode,
+		ErrorMessage: redact.String(plaidErr.ErrorMessage),
+	}
+	if plaidErr.DisplayMessage != "" {
+		response.DisplayMessage = &plaidErr.DisplayMessage
+	}
+	if plaidErr.RequestID != "" {
+		response.RequestID = &plaidErr.RequestID
+	}
+	return response
+}
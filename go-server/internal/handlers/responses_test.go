@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"bytes"
+	plaidpkg "compound/go-server/internal/plaid"
+	"compound/go-server/pkg/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestResponsesHaveNoAccessTokens drives every endpoint that touches an item, including its Plaid
+// error paths, and checks no response or log line carries an access token
+func TestResponsesHaveNoAccessTokens(t *testing.T) {
+	s := newTestServer(t)
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	userID, token := s.signUp(t, "alice")
+
+	link := map[string]any{"publicToken": "public-fake-token", "institutionId": plaidpkg.FakeInstitutionID}
+	var linked struct {
+		ItemID   int `json:"item_id"`
+		Accounts []struct {
+			ID int `json:"id"`
+		} `json:"accounts"`
+	}
+	s.doExpect(t, http.MethodPost, "/api/items", token, link, http.StatusOK, &linked)
+	itemID := linked.ItemID
+
+	// linking the institution again is refused with the existing item in the body
+	s.doExpect(t, http.MethodPost, "/api/items", token, link, http.StatusConflict, nil)
+	s.doExpect(t, http.MethodPost, "/api/items", token, map[string]string{}, http.StatusBadRequest, nil)
+
+	s.doExpect(t, http.MethodGet, "/api/items", token, nil, http.StatusOK, nil)
+	s.doExpect(t, http.MethodGet, fmt.Sprintf("/api/items/%d/accounts", itemID), token, nil, http.StatusOK, nil)
+	s.doExpect(t, http.MethodPost, fmt.Sprintf("/api/items/%d/balances/refresh", itemID), token, nil, http.StatusOK, nil)
+	s.doExpect(t, http.MethodPost, fmt.Sprintf("/api/items/%d/sync-transactions", itemID), token, nil, http.StatusOK, nil)
+	s.doExpect(t, http.MethodGet, fmt.Sprintf("/api/transactions/%d", userID), token, nil, http.StatusOK, nil)
+	s.doExpect(t, http.MethodGet, fmt.Sprintf("/api/users/%d/transactions/search?q=coffee", userID), token, nil, http.StatusOK, nil)
+	s.doExpect(t, http.MethodGet, fmt.Sprintf("/api/accounts/%d/transactions", linked.Accounts[0].ID), token, nil, http.StatusOK, nil)
+
+	item, err := s.store.GetItemByID(context.Background(), itemID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accessToken := item.PlaidAccessToken
+
+	// a Plaid error echoing the token, like INVALID_REQUEST errors echo the request body
+	s.fake.FailNextSync(plaidpkg.NewFakeError("INVALID_REQUEST", "INVALID_FIELD",
+		fmt.Sprintf(`access token %s is invalid, request body {"access_token":"%s"}`, accessToken, accessToken)))
+	s.doExpect(t, http.MethodPost, fmt.Sprintf("/api/items/%d/sync-transactions", itemID), token, nil, http.StatusBadGateway, nil)
+
+	// the item is removed at Plaid behind our back, every Plaid call now fails with ITEM_NOT_FOUND
+	if err := s.fake.RemoveItem(context.Background(), accessToken); err != nil {
+		t.Fatal(err)
+	}
+	s.doExpect(t, http.MethodPost, fmt.Sprintf("/api/items/%d/sync-transactions", itemID), token, nil, http.StatusNotFound, nil)
+	s.doExpect(t, http.MethodPost, fmt.Sprintf("/api/items/%d/balances/refresh", itemID), token, nil, http.StatusNotFound, nil)
+	s.doExpect(t, http.MethodDelete, fmt.Sprintf("/api/items/%d", itemID), token, nil, http.StatusNoContent, nil)
+	s.doExpect(t, http.MethodGet, fmt.Sprintf("/api/items/%d/accounts", itemID), token, nil, http.StatusNotFound, nil)
+
+	if strings.Contains(logs.String(), accessToken) {
+		t.Fatalf("logs leak the access token: %s", logs.String())
+	}
+}
+
+// TestTransactionResponseDate checks transaction dates are plain calendar dates, like the other
+// DTOs' dates, so clients in any time zone show the day the bank posted
+func TestTransactionResponseDate(t *testing.T) {
+	transaction := &models.Transaction{Date: time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC), Amount: models.MustParseMoney("4.5")}
+
+	body, err := json.Marshal(newTransactionResponse(transaction))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(body), `"date":"2025-01-02"`) {
+		t.Fatalf("transaction response %s, want date 2025-01-02", body)
+	}
+}
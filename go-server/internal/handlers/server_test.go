@@ -0,0 +1,330 @@
+package handlers
+
+import (
+	"bytes"
+	"compound/go-server/internal/db"
+	"compound/go-server/internal/db/dbtest"
+	plaidpkg "compound/go-server/internal/plaid"
+	"compound/go-server/internal/redact"
+	"compound/go-server/internal/services"
+	"compound/go-server/pkg/models"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testSyncSample seeds every item the test Fake creates: two accounts, three transactions
+const testSyncSample = `{
+  "added": [
+    {"account_id": "checking", "transaction_id": "tx-1", "name": "Coffee", "amount": 4.5, "date": "2025-01-02", "iso_currency_code": "USD"},
+    {"account_id": "checking", "transaction_id": "tx-2", "name": "Groceries", "amount": 52.1, "date": "2025-01-03", "iso_currency_code": "USD"},
+    {"account_id": "savings", "transaction_id": "tx-3", "name": "Interest", "amount": -1.25, "date": "2025-01-31", "iso_currency_code": "USD"}
+  ],
+  "modified": [],
+  "removed": []
+}`
+
+// testSyncSampleCount is the number of transactions each seeded item syncs
+const testSyncSampleCount = 3
+
+// testServer is the API wired to a real database and a plaid.Fake
+type testServer struct {
+	router *gin.Engine
+	store  *db.Store
+	fake   *plaidpkg.Fake
+	// webhookKey signs webhooks the way Plaid would, see sendWebhook
+	webhookKey *ecdsa.PrivateKey
+}
+
+// newTestServer builds a testServer on a dbtest database, skipping the test without one
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	store := dbtest.Open(t)
+
+	fake := plaidpkg.NewFake()
+	if err := fake.SeedFromSyncSample(strings.NewReader(testSyncSample)); err != nil {
+		t.Fatal(err)
+	}
+
+	syncer := services.NewTransactionSyncer(store, fake)
+	t.Cleanup(syncer.Close)
+	h := New(store, fake, syncer)
+
+	webhookKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	webhookKeys := plaidpkg.KeyFetcherFunc(func(ctx context.Context, keyID string) (*ecdsa.PublicKey, error) {
+		return &webhookKey.PublicKey, nil
+	})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/webhooks/plaid", h.MakePlaidWebhookHandler(plaidpkg.NewWebhookVerifier(webhookKeys)))
+	router.POST("/api/users", h.CreateUser)
+	// secure cookies, as in production, the recorder keeps them over plain http
+	router.POST("/api/sessions", h.MakeCreateSessionHandler(time.Hour, true))
+
+	authed := router.Group("/api", h.RequireSession)
+	authed.GET("/sessions/current", h.GetCurrentSession)
+	authed.DELETE("/sessions", h.MakeDeleteSessionHandler(true))
+	self := h.RequireSelf("id")
+	ownsItem := h.RequireItemOwner("id")
+	ownsAccount := h.RequireAccountOwner("id")
+	ownsAsset := h.RequireAssetOwner("id")
+	authed.GET("/users/:id", self, h.GetUser)
+	authed.GET("/users/:id/net-worth", self, h.MakeNetWorthHandler(services.NewNetWorthCalculator(store, services.NetWorthConfig{})))
+	authed.GET("/users/:id/transactions/search", self, h.SearchUserTransactions)
+	authed.POST("/link-events", h.CreateLinkEvent)
+	authed.POST("/link-token", h.MakeLinkTokenHandler("transactions", "US", "", ""))
+	authed.POST("/items", h.ExchangeToken)
+	authed.GET("/items", h.GetItems)
+	authed.DELETE("/items/:id", ownsItem, h.DeleteItem)
+	authed.GET("/items/:id/accounts", ownsItem, h.GetItemAccounts)
+	authed.GET("/items/:id/sync-runs", ownsItem, h.GetItemSyncRuns)
+	authed.POST("/items/:id/relink-complete", ownsItem, h.CompleteRelink)
+	authed.POST("/items/:id/sync-transactions", ownsItem, h.SyncTransactionsForItem)
+	authed.POST("/items/:id/balances/refresh", ownsItem, h.RefreshItemBalances)
+	authed.GET("/accounts/:id/transactions", ownsAccount, h.GetAccountTransactions)
+	authed.GET("/accounts/:id/balance-history", ownsAccount, h.GetAccountBalanceHistory)
+	authed.POST("/assets", h.CreateAsset)
+	authed.GET("/assets", h.GetAssets)
+	authed.GET("/assets/:id", ownsAsset, h.GetAsset)
+	authed.PATCH("/assets/:id", ownsAsset, h.UpdateAsset)
+	authed.DELETE("/assets/:id", ownsAsset, h.DeleteAsset)
+	authed.GET("/assets/:id/values", ownsAsset, h.GetAssetValues)
+	authed.GET("/transactions/:userID", h.RequireSelf("userID"), h.GetUserTransactions)
+	authed.PATCH("/transactions/:id", h.RequireTransactionOwner("id"), h.UpdateTransaction)
+	admin := authed.Group("/admin", h.RequireAdmin)
+	admin.GET("/items/:id/plaid-api-failures", h.GetItemPlaidAPIFailures)
+	admin.GET("/link-events", h.GetLinkEvents)
+
+	return &testServer{router: router, store: store, fake: fake, webhookKey: webhookKey}
+}
+
+// do sends a request with body encoded as JSON, authenticated with the session token if set
+func (s *testServer) do(t *testing.T, method, path, token string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var reader *bytes.Reader
+	if body == nil {
+		reader = bytes.NewReader(nil)
+	} else {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+// doExpect is do, failing the test unless the response status is want, and decoding the body into v
+// Every response is also checked for access tokens, none may ever leave the server
+func (s *testServer) doExpect(t *testing.T, method, path, token string, body any, want int, v any) {
+	t.Helper()
+
+	w := s.do(t, method, path, token, body)
+	assertNoAccessToken(t, method+" "+path, w.Body.String())
+	if w.Code != want {
+		t.Fatalf("%s %s = %d %s, want %d", method, path, w.Code, w.Body.String(), want)
+	}
+	if v != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Fatalf("%s %s: invalid response body %s: %v", method, path, w.Body.String(), err)
+		}
+	}
+}
+
+// assertNoAccessToken fails the test if text holds an access token
+// A token redacted to "access-[REDACTED]" is fine, any other "access-" is a leak
+func assertNoAccessToken(t *testing.T, where, text string) {
+	t.Helper()
+
+	if strings.Contains(strings.ReplaceAll(text, "access-"+redact.Placeholder, ""), "access-") {
+		t.Fatalf("%s leaks an access token: %s", where, text)
+	}
+}
+
+// signUp creates a user and logs them in, returning their ID and session token
+func (s *testServer) signUp(t *testing.T, username string) (int, string) {
+	t.Helper()
+
+	credentials := map[string]string{"username": username, "password": "correct horse battery staple"}
+
+	var user struct {
+		ID int `json:"id"`
+	}
+	s.doExpect(t, http.MethodPost, "/api/users", "", credentials, http.StatusOK, &user)
+
+	var session struct {
+		Token string `json:"token"`
+	}
+	s.doExpect(t, http.MethodPost, "/api/sessions", "", credentials, http.StatusOK, &session)
+
+	return user.ID, session.Token
+}
+
+// linkItem links a new Fake item for the user and returns its ID
+func (s *testServer) linkItem(t *testing.T, token string) int {
+	t.Helper()
+
+	var linked struct {
+		ItemID int `json:"item_id"`
+	}
+	link := map[string]string{"publicToken": "public-fake-token", "institutionId": plaidpkg.FakeInstitutionID}
+	s.doExpect(t, http.MethodPost, "/api/items", token, link, http.StatusOK, &linked)
+	return linked.ItemID
+}
+
+// syncItem syncs the item's transactions and returns how many were added
+func (s *testServer) syncItem(t *testing.T, token string, itemID int) int {
+	t.Helper()
+
+	var synced struct {
+		AddedCount int `json:"addedCount"`
+	}
+	s.doExpect(t, http.MethodPost, fmt.Sprintf("/api/items/%d/sync-transactions", itemID), token, nil, http.StatusOK, &synced)
+	return synced.AddedCount
+}
+
+// waitForSyncRun waits for a finished sync of the item started by trigger, failing the test if
+// none finishes within a few seconds
+func (s *testServer) waitForSyncRun(t *testing.T, itemID int, trigger string) *models.SyncRun {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		runs, err := s.store.GetSyncRunsByItemID(context.Background(), itemID, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, run := range runs {
+			if run.Trigger == trigger && run.FinishedAt != nil {
+				return run
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("item %d: no %s sync finished", itemID, trigger)
+	return nil
+}
+
+// TestOtherUsersResourcesNotFound has bob reach for each of alice's items, accounts, assets and
+// transactions, and alice's user ID, on every route that owns them. Each is a 404, the same as
+// an ID that doesn't exist, nothing of alice's is in the response and nothing of hers changes.
+func TestOtherUsersResourcesNotFound(t *testing.T) {
+	s := newTestServer(t)
+
+	aliceID, alice := s.signUp(t, "alice")
+	_, bob := s.signUp(t, "bob")
+	s.linkItem(t, bob)
+
+	itemID := s.linkItem(t, alice)
+	s.syncItem(t, alice, itemID)
+
+	var accounts struct {
+		Accounts []struct {
+			ID int `json:"id"`
+		} `json:"accounts"`
+	}
+	s.doExpect(t, http.MethodGet, fmt.Sprintf("/api/items/%d/accounts", itemID), alice, nil, http.StatusOK, &accounts)
+	accountID := accounts.Accounts[0].ID
+
+	var page struct {
+		Transactions []struct {
+			ID int `json:"id"`
+		} `json:"transactions"`
+	}
+	s.doExpect(t, http.MethodGet, fmt.Sprintf("/api/transactions/%d", aliceID), alice, nil, http.StatusOK, &page)
+	transactionID := page.Transactions[0].ID
+
+	var asset struct {
+		ID int `json:"id"`
+	}
+	s.doExpect(t, http.MethodPost, "/api/assets", alice, map[string]any{"description": "Alice's house", "value": 350000}, http.StatusOK, &asset)
+
+	routes := []struct {
+		method string
+		path   string
+		body   any
+	}{
+		{http.MethodGet, "/api/users/%d", nil},
+		{http.MethodGet, "/api/users/%d/net-worth", nil},
+		{http.MethodGet, "/api/users/%d/transactions/search?q=coffee", nil},
+		{http.MethodGet, "/api/transactions/%d", nil},
+		{http.MethodGet, "/api/items/%d/accounts", nil},
+		{http.MethodGet, "/api/items/%d/sync-runs", nil},
+		{http.MethodPost, "/api/items/%d/sync-transactions", nil},
+		{http.MethodPost, "/api/items/%d/balances/refresh", nil},
+		{http.MethodPost, "/api/items/%d/relink-complete", nil},
+		{http.MethodDelete, "/api/items/%d", nil},
+		{http.MethodGet, "/api/accounts/%d/transactions", nil},
+		{http.MethodGet, "/api/accounts/%d/balance-history", nil},
+		{http.MethodGet, "/api/assets/%d", nil},
+		{http.MethodGet, "/api/assets/%d/values", nil},
+		{http.MethodPatch, "/api/assets/%d", map[string]any{"description": "Bob's house now"}},
+		{http.MethodDelete, "/api/assets/%d", nil},
+		{http.MethodPatch, "/api/transactions/%d", map[string]any{"notes": "bob was here"}},
+	}
+	ids := map[string]int{"users": aliceID, "transactions": aliceID, "items": itemID, "accounts": accountID, "assets": asset.ID}
+
+	for _, route := range routes {
+		resource := strings.Split(route.path, "/")[2]
+		id := ids[resource]
+		if route.method == http.MethodPatch && resource == "transactions" {
+			id = transactionID
+		}
+
+		for _, target := range []int{id, 999999} {
+			path := fmt.Sprintf(route.path, target)
+			w := s.do(t, route.method, path, bob, route.body)
+			if w.Code != http.StatusNotFound {
+				t.Fatalf("bob %s %s = %d %s, want 404", route.method, path, w.Code, w.Body.String())
+			}
+			for _, leak := range []string{"alice", "Alice's house", "Coffee", "Fake Account"} {
+				if strings.Contains(w.Body.String(), leak) {
+					t.Fatalf("bob %s %s leaks %q: %s", route.method, path, leak, w.Body.String())
+				}
+			}
+		}
+	}
+
+	// alice's resources are untouched
+	s.doExpect(t, http.MethodGet, fmt.Sprintf("/api/items/%d/accounts", itemID), alice, nil, http.StatusOK, nil)
+	var got struct {
+		Description string `json:"description"`
+	}
+	s.doExpect(t, http.MethodGet, fmt.Sprintf("/api/assets/%d", asset.ID), alice, nil, http.StatusOK, &got)
+	if got.Description != "Alice's house" {
+		t.Fatalf("alice's asset is described %q, want it unchanged", got.Description)
+	}
+	transaction, err := s.store.GetTransactionByID(context.Background(), transactionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transaction.Notes != nil {
+		t.Fatalf("alice's transaction has notes %q, want none", *transaction.Notes)
+	}
+}
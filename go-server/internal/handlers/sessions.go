@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"compound/go-server/internal/auth"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// CreateSessionRequest represents the request body for logging in
+type CreateSessionRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// MakeCreateSessionHandler creates a handler for POST /api/sessions
+// Logs a user in with their username and password and starts a session lasting ttl
+//
+// Request body:
+// {
+//   "username": "alice",
+//   "password": "correct horse battery staple"
+// }
+//
+// Response (the token is also set as an HttpOnly session cookie):
+// {
+//   "token": "...",
+//   "expires_at": "2025-01-01T00:00:00Z",
+//   "user": { ...UserResponse }
+// }
+//
+// Browser clients should rely on the cookie, other clients send the token as
+// "Authorization: Bearer <token>". secureCookie should only be false for local development over http.
+func (h *Handler) MakeCreateSessionHandler(ttl time.Duration, secureCookie bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CreateSessionRequest
+
+		// Parse request body
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "username and password are required",
+			})
+			return
+		}
+
+		// unknown users still go through CheckPassword so they take as long as a wrong password
+		user, err := h.store.GetUserByUsername(context.Background(), req.Username)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			log.Printf("failed to get user to log in: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "session not created successfully - check server logs",
+			})
+			return
+		}
+		var passwordHash *string
+		if err == nil {
+			passwordHash = user.PasswordHash
+		}
+		if !auth.CheckPassword(passwordHash, req.Password) {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "invalid username or password",
+			})
+			return
+		}
+
+		token, tokenHash, err := auth.NewSessionToken()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "session not created successfully - check server logs",
+			})
+			return
+		}
+
+		session, err := h.store.CreateSession(context.Background(), user.ID, tokenHash, time.Now().Add(ttl))
+		if err != nil {
+			log.Printf("failed to create session for user %d: %v", user.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "session not created successfully - check server logs",
+			})
+			return
+		}
+
+		// opportunistic cleanup, a failure here doesn't affect the login
+		if _, err := h.store.DeleteExpiredSessions(context.Background()); err != nil {
+			log.Printf("failed to delete expired sessions: %v", err)
+		}
+
+		setSessionCookie(c, token, int(ttl.Seconds()), secureCookie)
+
+		c.JSON(http.StatusOK, gin.H{
+			"token":      token,
+			"expires_at": session.ExpiresAt,
+			"user":       newUserResponse(user),
+		})
+	}
+}
+
+// GetCurrentSession handles GET /api/sessions/current
+// Returns the logged in user
+func (h *Handler) GetCurrentSession(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"user": newUserResponse(currentUser(c)),
+	})
+}
+
+// MakeDeleteSessionHandler creates a handler for DELETE /api/sessions
+// Logs out by deleting the current session and clearing the session cookie
+//
+// secureCookie must match the value given to MakeCreateSessionHandler, browsers only replace a
+// cookie with one set the same way
+func (h *Handler) MakeDeleteSessionHandler(secureCookie bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := h.store.DeleteSession(context.Background(), c.GetString(sessionTokenHashKey)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "session not deleted successfully - check server logs",
+			})
+			return
+		}
+
+		setSessionCookie(c, "", -1, secureCookie)
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// setSessionCookie sets the session cookie, a negative maxAge clears it
+// The cookie is HttpOnly so scripts can't read the token, and SameSite=Lax so it isn't sent
+// with cross-site requests other than top-level navigation
+func setSessionCookie(c *gin.Context, token string, maxAge int, secure bool) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    url.QueryEscape(token),
+		MaxAge:   maxAge,
+		Path:     "/",
+		Secure:   secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"compound/go-server/internal/db/dbtest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRegister(t *testing.T) {
+	s := newTestServer(t)
+
+	credentials := map[string]string{"username": "alice", "password": "correct horse battery staple"}
+	var user struct {
+		ID       int    `json:"id"`
+		Username string `json:"username"`
+	}
+	s.doExpect(t, http.MethodPost, "/api/users", "", credentials, http.StatusOK, &user)
+	if user.ID == 0 || user.Username != "alice" {
+		t.Fatalf("registered %+v, want alice with an ID", user)
+	}
+
+	s.doExpect(t, http.MethodPost, "/api/users", "", credentials, http.StatusConflict, nil)
+	s.doExpect(t, http.MethodPost, "/api/users", "", map[string]string{"username": "bob", "password": "short"}, http.StatusBadRequest, nil)
+	s.doExpect(t, http.MethodPost, "/api/users", "", map[string]string{"username": "bob"}, http.StatusBadRequest, nil)
+}
+
+// sessionCookie returns the session cookie the response sets, or nil
+func sessionCookie(w *httptest.ResponseRecorder) *http.Cookie {
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == SessionCookieName {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func TestLogin(t *testing.T) {
+	s := newTestServer(t)
+	aliceID, _ := s.signUp(t, "alice")
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		want     int
+	}{
+		{"right password", "alice", "correct horse battery staple", http.StatusOK},
+		{"wrong password", "alice", "correct horse battery stapler", http.StatusUnauthorized},
+		{"unknown user", "mallory", "correct horse battery staple", http.StatusUnauthorized},
+		{"missing password", "alice", "", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		w := s.do(t, http.MethodPost, "/api/sessions", "", map[string]string{"username": tt.username, "password": tt.password})
+		if w.Code != tt.want {
+			t.Fatalf("%s: got %d %s, want %d", tt.name, w.Code, w.Body.String(), tt.want)
+		}
+		if tt.want != http.StatusOK {
+			continue
+		}
+
+		cookie := sessionCookie(w)
+		if cookie == nil || cookie.Value == "" || !cookie.HttpOnly || !cookie.Secure || cookie.SameSite != http.SameSiteLaxMode {
+			t.Fatalf("%s: no HttpOnly, Secure, SameSite=Lax session cookie set: %v", tt.name, w.Result().Cookies())
+		}
+
+		var current struct {
+			User struct {
+				ID int `json:"id"`
+			} `json:"user"`
+		}
+		s.doExpect(t, http.MethodGet, "/api/sessions/current", cookie.Value, nil, http.StatusOK, &current)
+		if current.User.ID != aliceID {
+			t.Fatalf("%s: logged in as user %d, want %d", tt.name, current.User.ID, aliceID)
+		}
+	}
+}
+
+// TestLoginDatabaseDown checks a failing user lookup is a 500, not a wrong password
+func TestLoginDatabaseDown(t *testing.T) {
+	s := newTestServer(t)
+	s.signUp(t, "alice")
+	s.store.Close()
+
+	credentials := map[string]string{"username": "alice", "password": "correct horse battery staple"}
+	s.doExpect(t, http.MethodPost, "/api/sessions", "", credentials, http.StatusInternalServerError, nil)
+}
+
+func TestLogout(t *testing.T) {
+	s := newTestServer(t)
+	_, token := s.signUp(t, "alice")
+	_, other := s.signUp(t, "alice2")
+
+	s.doExpect(t, http.MethodGet, "/api/sessions/current", token, nil, http.StatusOK, nil)
+	w := s.do(t, http.MethodDelete, "/api/sessions", token, nil)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("logout = %d %s, want 204", w.Code, w.Body.String())
+	}
+
+	// the cookie is only replaced if it is cleared with the attributes it was set with
+	cookie := sessionCookie(w)
+	if cookie == nil || cookie.Value != "" || cookie.MaxAge >= 0 || !cookie.HttpOnly || !cookie.Secure ||
+		cookie.SameSite != http.SameSiteLaxMode || cookie.Path != "/" {
+		t.Fatalf("session cookie not cleared like it was set: %v", w.Result().Cookies())
+	}
+
+	s.doExpect(t, http.MethodGet, "/api/sessions/current", token, nil, http.StatusUnauthorized, nil)
+	s.doExpect(t, http.MethodDelete, "/api/sessions", token, nil, http.StatusUnauthorized, nil)
+
+	// only the session logged out of ends
+	s.doExpect(t, http.MethodGet, "/api/sessions/current", other, nil, http.StatusOK, nil)
+}
+
+func TestExpiredSession(t *testing.T) {
+	s := newTestServer(t)
+	_, token := s.signUp(t, "alice")
+
+	s.doExpect(t, http.MethodGet, "/api/sessions/current", token, nil, http.StatusOK, nil)
+	dbtest.Exec(t, "UPDATE sessions_table SET expires_at = NOW() - interval '1 minute'")
+	s.doExpect(t, http.MethodGet, "/api/sessions/current", token, nil, http.StatusUnauthorized, nil)
+	s.doExpect(t, http.MethodGet, "/api/items", token, nil, http.StatusUnauthorized, nil)
+
+	// logging in again deletes the expired session and starts a new one
+	_, fresh := s.signUp(t, "bob")
+	s.doExpect(t, http.MethodGet, "/api/sessions/current", fresh, nil, http.StatusOK, nil)
+	s.doExpect(t, http.MethodGet, "/api/sessions/current", token, nil, http.StatusUnauthorized, nil)
+}
+
+func TestRequireSessionRejectsBadTokens(t *testing.T) {
+	s := newTestServer(t)
+	s.signUp(t, "alice")
+
+	for _, token := range []string{"", "not-a-session-token"} {
+		s.doExpect(t, http.MethodGet, "/api/sessions/current", token, nil, http.StatusUnauthorized, nil)
+	}
+}
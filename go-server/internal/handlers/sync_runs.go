@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"compound/go-server/internal/redact"
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Page size limits for GET /api/items/:id/sync-runs
+const (
+	defaultSyncRunsLimit = 20
+	maxSyncRunsLimit     = 100
+)
+
+// GetItemSyncRuns handles GET /api/items/:id/sync-runs?limit=20
+// Returns the item's most recent sync runs, newest first
+func (h *Handler) GetItemSyncRuns(c *gin.Context) {
+	limit := defaultSyncRunsLimit
+	if limitStr := c.Query("limit"); limitStr != "" {
+		n, err := strconv.Atoi(limitStr)
+		if err != nil || n < 1 || n > maxSyncRunsLimit {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "limit must be between 1 and " + strconv.Itoa(maxSyncRunsLimit),
+			})
+			return
+		}
+		limit = n
+	}
+
+	runs, err := h.store.GetSyncRunsByItemID(context.Background(), ownedItemID(c), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to get sync runs: " + redact.Error(err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sync_runs": newSyncRunResponses(runs),
+	})
+}
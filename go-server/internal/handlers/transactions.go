@@ -2,147 +2,368 @@ package handlers
 
 import (
 	"compound/go-server/internal/db"
+	"compound/go-server/internal/redact"
+	"compound/go-server/internal/services"
+	"compound/go-server/pkg/models"
 	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
-
-	plaidpkg "compound/go-server/internal/plaid"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 )
 
-// called inside SyncTransactionsForItem
-// accepts each transaction object from the plaid data
-// func CreateOrUpdateTransaction(c *gin.Context) {
+// Page size limits for GET /api/transactions/:userID
+const (
+	defaultTransactionLimit = 100
+	maxTransactionLimit     = 500
+)
 
-// }
+// Result limits for GET /api/users/:id/transactions/search
+const (
+	defaultTransactionSearchLimit = 20
+	maxTransactionSearchLimit     = 100
+)
 
-// SyncTransactionsForItem handles POST /api/transactions/sync
-// accepts itemID
-func SyncTransactionsForItem(c *gin.Context) {
+// maxTransactionNotesLength is the longest note accepted on a transaction
+const maxTransactionNotesLength = 1000
 
-	// parse itemID from incoming URL
-	// Get user ID from URL parameter
-	idStr := c.Param("itemID")
-	itemID, err := strconv.Atoi(idStr)
+// SyncTransactionsForItem handles POST /api/items/:id/sync-transactions
+// Pulls every pending page of transaction updates for the item from Plaid
+// Plaid failures respond with an ErrorResponse carrying plaid_error, on ITEM_LOGIN_REQUIRED the
+// item has already been moved to login_required
+func (h *Handler) SyncTransactionsForItem(c *gin.Context) {
+	result, err := h.syncer.SyncTransactionsForItem(context.Background(), ownedItemID(c), models.SyncTriggerManual)
+	if errors.Is(err, services.ErrSyncInProgress) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "sync already in progress",
+		})
+		return
+	}
 	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to sync transactions", err)
+		return
+	}
+
+	// Return summary of what was synced
+	c.JSON(http.StatusOK, gin.H{
+		"success":       true,
+		"addedCount":    result.AddedCount,
+		"modifiedCount": result.ModifiedCount,
+		"removedCount":  result.RemovedCount,
+		"pages":         result.Pages,
+	})
+}
+
+// GetUserTransactions handles GET /api/transactions/:userID
+// Returns a page of the current user's transactions, RequireSelf rejects any other user ID
+//
+// Query parameters, all optional:
+//   account_id, item_id     only these accounts or items, repeat to pass several
+//   start_date, end_date    YYYY-MM-DD, inclusive
+//   min_amount, max_amount  inclusive, signed as Plaid reports them (money out is positive)
+//   pending                 true or false
+//   category                Plaid personal finance category (primary or detailed) or legacy category
+//   name                    text the transaction name contains, case-insensitive
+//   sort                    date_desc (default), date_asc, amount_desc or amount_asc
+//   limit                   page size, default 100, at most 500
+//   cursor                  next_cursor of the previous page, with the same filters and sort
+//
+// Response:
+// {
+//   "transactions": [ ...TransactionResponse ],
+//   "total_count": 1234,        // transactions matching the filters across all pages
+//   "next_cursor": "ZGF0ZV9..."  // null on the last page
+// }
+func (h *Handler) GetUserTransactions(c *gin.Context) {
+	filter, ok := transactionFilter(c)
+	if !ok {
+		return
+	}
+	filter.UserID = currentUser(c).ID
+
+	sort := c.DefaultQuery("sort", db.TransactionSortDateDesc)
+	switch sort {
+	case db.TransactionSortDateDesc, db.TransactionSortDateAsc, db.TransactionSortAmountDesc, db.TransactionSortAmountAsc:
+	default:
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid user id",
+			"error": "sort must be date_desc, date_asc, amount_desc or amount_asc",
 		})
 		return
 	}
 
-	// retrieve item from DB to get access token
-	item, err := db.GetItemByID(context.Background(), itemID)
+	limit := defaultTransactionLimit
+	if limitStr := c.Query("limit"); limitStr != "" {
+		n, err := strconv.Atoi(limitStr)
+		if err != nil || n < 1 || n > maxTransactionLimit {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "limit must be between 1 and " + strconv.Itoa(maxTransactionLimit),
+			})
+			return
+		}
+		limit = n
+	}
+
+	var after *db.TransactionCursor
+	if cursor := c.Query("cursor"); cursor != "" {
+		decoded, err := decodeTransactionCursor(sort, cursor)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid cursor",
+			})
+			return
+		}
+		after = decoded
+	}
+
+	// fetch one extra row to tell whether there's another page
+	transactions, err := h.store.GetTransactions(context.Background(), filter, sort, after, limit+1)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to get item: " + err.Error(),
+			"error": "failed to get transactions: " + redact.Error(err),
 		})
 		return
 	}
 
-	// use access token + cursor to call plaid.NewTransactionsSyncRequest
-	// returns plaid objects
-	result, err := plaidpkg.SyncTransactions(context.Background(), item.PlaidAccessToken, item.TransactionsCursor)
+	totalCount, err := h.store.CountTransactions(context.Background(), filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to sync transactions: " + err.Error(),
+			"error": "failed to count transactions: " + redact.Error(err),
 		})
 		return
 	}
 
-	// move this to CreateOrUpdateTransaction later
-	// combine adds and updates
-	allTransactions := append(result.Added, result.Modified...)
-
-	// loop thru each
-	for _, plaidTx := range allTransactions {
-		// get our DB account ID from plaid account ID
-		account, err := db.GetAccountByPlaidAccountID(context.Background(), plaidTx.GetAccountId())
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "failed to get DB Account ID for: " + err.Error(),
-			})
-			return
-		}
+	var nextCursor *string
+	if len(transactions) > limit {
+		transactions = transactions[:limit]
+		cursor := encodeTransactionCursor(sort, transactions[limit-1])
+		nextCursor = &cursor
+	}
 
-		// map category data
-		categoryData := map[string]interface{}{
-			"legacy":                    plaidTx.GetCategory(),
-			"personal_finance_category": plaidTx.GetPersonalFinanceCategory(),
-		}
+	c.JSON(http.StatusOK, gin.H{
+		"transactions": newTransactionResponses(transactions),
+		"total_count":  totalCount,
+		"next_cursor":  nextCursor,
+	})
+}
 
-		// Convert account owner string to *string
-		accountOwner := plaidTx.GetAccountOwner()
-		var ownerPtr *string
-		if accountOwner != "" {
-			ownerPtr = &accountOwner
-		}
+// SearchUserTransactions handles GET /api/users/:id/transactions/search?q=amazon&limit=20
+// Full-text searches the current user's transactions' names, merchant names and notes, best matches
+// first. Every word of q must match, each as a prefix ("amaz" finds "Amazon"); name and merchant
+// name matches rank above notes.
+//
+// Response:
+// {
+//   "results": [
+//     {
+//       "transaction": { ...TransactionResponse },
+//       "rank": 0.61,
+//       "snippet": "<mark>Amazon</mark> Mktp US · <mark>Amazon</mark>"   // HTML, the text escaped
+//     }
+//   ]
+// }
+func (h *Handler) SearchUserTransactions(c *gin.Context) {
+	terms := db.SearchTerms(c.Query("q"))
+	if len(terms) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "q must contain at least one word",
+		})
+		return
+	}
 
-		_, err = db.CreateOrUpdateTransaction(
-			context.Background(),
-			account.ID,
-			plaidTx.GetTransactionId(),
-			categoryData,
-			plaidTx.GetTransactionType(),
-			plaidTx.GetName(),
-			plaidTx.GetAmount(),
-			plaidTx.GetIsoCurrencyCode(),
-			plaidTx.GetUnofficialCurrencyCode(),
-			plaidTx.GetDate(),
-			plaidTx.GetPending(),
-			ownerPtr,
-		)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "failed to store transaction: " + err.Error(),
+	limit := defaultTransactionSearchLimit
+	if limitStr := c.Query("limit"); limitStr != "" {
+		n, err := strconv.Atoi(limitStr)
+		if err != nil || n < 1 || n > maxTransactionSearchLimit {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "limit must be between 1 and " + strconv.Itoa(maxTransactionSearchLimit),
 			})
 			return
 		}
+		limit = n
 	}
 
-	// Update the cursor for the next sync
-	err = db.UpdateItemTransactionsCursor(context.Background(), itemID, result.NextCursor)
+	results, err := h.store.SearchTransactions(context.Background(), currentUser(c).ID, terms, limit)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to update cursor: " + err.Error(),
+			"error": "failed to search transactions: " + redact.Error(err),
 		})
 		return
 	}
 
-	// Return summary of what was synced
 	c.JSON(http.StatusOK, gin.H{
-		"success":       true,
-		"addedCount":    len(result.Added),
-		"modifiedCount": len(result.Modified),
-		"removedCount":  len(result.Removed),
+		"results": newTransactionSearchResultResponses(results),
 	})
 }
 
-// handles GET /api/users/:user_id/transactions
-// Returns all transactions for a specific user
-func GetUserTransactions(c *gin.Context) {
-	// parse user ID from URL parameter
-	userIDStr := c.Param("userID")
-	userID, err := strconv.Atoi(userIDStr)
-	if err != nil {
+// UpdateTransaction handles PATCH /api/transactions/:id
+// Sets the notes on one of the current user's transactions, notes are included in search
+//
+// Request body:
+// {
+//   "notes": "birthday present for Sam"   // null or "" clears them
+// }
+//
+// Response: TransactionResponse
+func (h *Handler) UpdateTransaction(c *gin.Context) {
+	var req struct {
+		Notes *string `json:"notes"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid user id",
+			"error": "invalid request body",
+		})
+		return
+	}
+
+	notes := req.Notes
+	if notes != nil {
+		trimmed := strings.TrimSpace(*notes)
+		if utf8.RuneCountInString(trimmed) > maxTransactionNotesLength {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "notes must be at most " + strconv.Itoa(maxTransactionNotesLength) + " characters",
+			})
+			return
+		}
+		notes = &trimmed
+		if trimmed == "" {
+			notes = nil
+		}
+	}
+
+	transaction, err := h.store.UpdateTransactionNotes(context.Background(), ownedTransactionID(c), notes)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to update transaction: " + redact.Error(err),
 		})
 		return
 	}
 
-	// get all transactions for the user
-	transactions, err := db.GetTransactionByUserID(context.Background(), userID)
+	c.JSON(http.StatusOK, newTransactionResponse(transaction))
+}
+
+// GetAccountTransactions handles GET /api/accounts/:id/transactions
+// Returns all transactions for one of the current user's accounts
+func (h *Handler) GetAccountTransactions(c *gin.Context) {
+	transactions, err := h.store.GetTransactionsByAccountID(context.Background(), ownedAccountID(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to get transactions: " + err.Error(),
+			"error": "failed to get transactions: " + redact.Error(err),
 		})
 		return
 	}
 
-	// return transactions
 	c.JSON(http.StatusOK, gin.H{
-		"transactions": transactions,
+		"transactions": newTransactionResponses(transactions),
 	})
 }
+
+// transactionFilter parses the filter query parameters of GET /api/transactions/:userID,
+// responding 400 if one is invalid
+func transactionFilter(c *gin.Context) (db.TransactionFilter, bool) {
+	filter := db.TransactionFilter{
+		Category: c.Query("category"),
+		Name:     c.Query("name"),
+	}
+
+	badRequest := func(message string) (db.TransactionFilter, bool) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": message,
+		})
+		return db.TransactionFilter{}, false
+	}
+
+	for _, param := range []struct {
+		name string
+		ids  *[]int
+	}{{"account_id", &filter.AccountIDs}, {"item_id", &filter.ItemIDs}} {
+		for _, idStr := range c.QueryArray(param.name) {
+			id, err := strconv.Atoi(idStr)
+			if err != nil {
+				return badRequest("invalid " + param.name)
+			}
+			*param.ids = append(*param.ids, id)
+		}
+	}
+
+	for _, param := range []struct {
+		name string
+		date **time.Time
+	}{{"start_date", &filter.StartDate}, {"end_date", &filter.EndDate}} {
+		if dateStr := c.Query(param.name); dateStr != "" {
+			date, err := time.Parse(time.DateOnly, dateStr)
+			if err != nil {
+				return badRequest(param.name + " must be formatted YYYY-MM-DD")
+			}
+			*param.date = &date
+		}
+	}
+
+	for _, param := range []struct {
+		name   string
+		amount **models.Money
+	}{{"min_amount", &filter.MinAmount}, {"max_amount", &filter.MaxAmount}} {
+		if amountStr := c.Query(param.name); amountStr != "" {
+			amount, err := models.ParseMoney(amountStr)
+			if err != nil {
+				return badRequest(param.name + " must be a decimal number")
+			}
+			*param.amount = &amount
+		}
+	}
+
+	if pendingStr := c.Query("pending"); pendingStr != "" {
+		pending, err := strconv.ParseBool(pendingStr)
+		if err != nil {
+			return badRequest("pending must be true or false")
+		}
+		filter.Pending = &pending
+	}
+
+	return filter, true
+}
+
+// encodeTransactionCursor returns the cursor of the page ending at transaction
+// Cursors are opaque to clients, they hold the sort order, the sort key and the ID
+func encodeTransactionCursor(sort string, transaction *models.Transaction) string {
+	key := transaction.Date.Format(time.DateOnly)
+	if sort == db.TransactionSortAmountDesc || sort == db.TransactionSortAmountAsc {
+		key = transaction.Amount.String()
+	}
+	raw := sort + "|" + key + "|" + strconv.Itoa(transaction.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeTransactionCursor parses a cursor made by encodeTransactionCursor for the same sort order
+func decodeTransactionCursor(sort, cursor string) (*db.TransactionCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 3 || parts[0] != sort {
+		return nil, fmt.Errorf("cursor is for a different sort order")
+	}
+
+	id, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return nil, err
+	}
+	after := &db.TransactionCursor{ID: id}
+
+	if sort == db.TransactionSortAmountDesc || sort == db.TransactionSortAmountAsc {
+		after.Amount, err = models.ParseMoney(parts[1])
+	} else {
+		after.Date, err = time.Parse(time.DateOnly, parts[1])
+	}
+	if err != nil {
+		return nil, err
+	}
+	return after, nil
+}
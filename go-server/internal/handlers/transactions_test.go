@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"compound/go-server/internal/db"
+	"compound/go-server/pkg/models"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTransactionCursorRoundTrip(t *testing.T) {
+	transaction := &models.Transaction{
+		ID:     42,
+		Date:   time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC),
+		Amount: models.MustParseMoney("-1250.0125"),
+	}
+
+	for _, sort := range []string{db.TransactionSortDateDesc, db.TransactionSortDateAsc} {
+		after, err := decodeTransactionCursor(sort, encodeTransactionCursor(sort, transaction))
+		if err != nil {
+			t.Fatalf("%s: %v", sort, err)
+		}
+		if after.ID != transaction.ID || !after.Date.Equal(transaction.Date) {
+			t.Fatalf("%s: decoded %+v, want ID %d and date %s", sort, after, transaction.ID, transaction.Date)
+		}
+	}
+
+	for _, sort := range []string{db.TransactionSortAmountDesc, db.TransactionSortAmountAsc} {
+		after, err := decodeTransactionCursor(sort, encodeTransactionCursor(sort, transaction))
+		if err != nil {
+			t.Fatalf("%s: %v", sort, err)
+		}
+		if after.ID != transaction.ID || after.Amount.Cmp(transaction.Amount) != 0 {
+			t.Fatalf("%s: decoded %+v, want ID %d and amount %s", sort, after, transaction.ID, transaction.Amount)
+		}
+	}
+}
+
+func TestDecodeTransactionCursorRejects(t *testing.T) {
+	encode := func(raw string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(raw))
+	}
+	transaction := &models.Transaction{ID: 7, Date: time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC), Amount: models.MustParseMoney("10")}
+
+	tests := []struct {
+		name   string
+		sort   string
+		cursor string
+	}{
+		{"other direction", db.TransactionSortDateAsc, encodeTransactionCursor(db.TransactionSortDateDesc, transaction)},
+		{"other column", db.TransactionSortAmountDesc, encodeTransactionCursor(db.TransactionSortDateDesc, transaction)},
+		{"not base64", db.TransactionSortDateDesc, "not a cursor!"},
+		{"padded base64", db.TransactionSortDateDesc, base64.URLEncoding.EncodeToString([]byte("date_desc|2025-01-02|7"))},
+		{"missing ID", db.TransactionSortDateDesc, encode("date_desc|2025-01-02")},
+		{"extra field", db.TransactionSortDateDesc, encode("date_desc|2025-01-02|7|8")},
+		{"non-numeric ID", db.TransactionSortDateDesc, encode("date_desc|2025-01-02|7 OR 1=1")},
+		{"bad date", db.TransactionSortDateDesc, encode("date_desc|2025-13-45|7")},
+		{"amount for a date sort", db.TransactionSortDateDesc, encode("date_desc|10.00|7")},
+		{"bad amount", db.TransactionSortAmountAsc, encode("amount_asc|1e9|7")},
+		{"date for an amount sort", db.TransactionSortAmountAsc, encode("amount_asc|2025-01-02|7")},
+		{"empty", db.TransactionSortDateDesc, ""},
+	}
+
+	for _, tt := range tests {
+		if after, err := decodeTransactionCursor(tt.sort, tt.cursor); err == nil {
+			t.Errorf("%s: decoded %q as %+v, want an error", tt.name, tt.cursor, after)
+		}
+	}
+}
+
+// TestTransactionPagesWithTiedAmounts pages through transactions by amount when several share an
+// amount, every transaction comes up exactly once, in order
+func TestTransactionPagesWithTiedAmounts(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+
+	userID, token := s.signUp(t, "alice")
+	itemID := s.linkItem(t, token)
+	s.syncItem(t, token, itemID)
+
+	accounts, err := s.store.GetAccountsByItemID(ctx, itemID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 7 {
+		_, err := s.store.CreateOrUpdateTransaction(ctx, accounts[0].ID, fmt.Sprintf("tied-%d", i), map[string]any{}, "place",
+			"Tied", nil, models.MustParseMoney("10.00"), "USD", "", fmt.Sprintf("2025-02-%02d", i%3+1), false, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	const total = testSyncSampleCount + 7
+
+	for _, sort := range []string{db.TransactionSortAmountDesc, db.TransactionSortAmountAsc} {
+		seen := map[int]bool{}
+		var previous *models.Money
+		cursor := ""
+		for pages := 0; ; pages++ {
+			if pages > total {
+				t.Fatalf("%s: still paging after %d pages", sort, pages)
+			}
+
+			query := url.Values{"sort": {sort}, "limit": {"2"}}
+			if cursor != "" {
+				query.Set("cursor", cursor)
+			}
+			var page struct {
+				Transactions []struct {
+					ID     int          `json:"id"`
+					Amount models.Money `json:"amount"`
+				} `json:"transactions"`
+				TotalCount int     `json:"total_count"`
+				NextCursor *string `json:"next_cursor"`
+			}
+			s.doExpect(t, http.MethodGet, fmt.Sprintf("/api/transactions/%d?%s", userID, query.Encode()), token, nil, http.StatusOK, &page)
+
+			for _, tx := range page.Transactions {
+				if seen[tx.ID] {
+					t.Fatalf("%s: transaction %d repeated", sort, tx.ID)
+				}
+				seen[tx.ID] = true
+				if previous != nil {
+					if cmp := previous.Cmp(tx.Amount); (sort == db.TransactionSortAmountDesc && cmp < 0) || (sort == db.TransactionSortAmountAsc && cmp > 0) {
+						t.Fatalf("%s: amount %s follows %s", sort, tx.Amount, previous)
+					}
+				}
+				previous = &tx.Amount
+			}
+
+			if page.NextCursor == nil {
+				break
+			}
+			cursor = *page.NextCursor
+		}
+
+		if len(seen) != total {
+			t.Fatalf("%s: paged through %d transactions, want %d", sort, len(seen), total)
+		}
+	}
+
+	// a cursor from one sort order is refused by another
+	var first struct {
+		NextCursor string `json:"next_cursor"`
+	}
+	s.doExpect(t, http.MethodGet, fmt.Sprintf("/api/transactions/%d?sort=amount_desc&limit=1", userID), token, nil, http.StatusOK, &first)
+	s.doExpect(t, http.MethodGet, fmt.Sprintf("/api/transactions/%d?sort=amount_asc&cursor=%s", userID, first.NextCursor), token, nil, http.StatusBadRequest, nil)
+}
+
+// TestSearchTransactions searches by prefix, with tsquery syntax in the query, and by notes as
+// they're set and cleared
+func TestSearchTransactions(t *testing.T) {
+	s := newTestServer(t)
+
+	userID, token := s.signUp(t, "alice")
+	itemID := s.linkItem(t, token)
+	s.syncItem(t, token, itemID)
+
+	var snippets []string
+	search := func(q string) []int {
+		t.Helper()
+
+		var found struct {
+			Results []struct {
+				Transaction struct {
+					ID int `json:"id"`
+				} `json:"transaction"`
+				Snippet string `json:"snippet"`
+			} `json:"results"`
+		}
+		path := fmt.Sprintf("/api/users/%d/transactions/search?%s", userID, url.Values{"q": {q}}.Encode())
+		s.doExpect(t, http.MethodGet, path, token, nil, http.StatusOK, &found)
+
+		var ids []int
+		snippets = nil
+		for _, result := range found.Results {
+			ids = append(ids, result.Transaction.ID)
+			snippets = append(snippets, result.Snippet)
+		}
+		return ids
+	}
+
+	// "Groceries" is the only match for both a prefix and the whole word
+	groceries := search("Groceries")
+	if len(groceries) != 1 {
+		t.Fatalf("search for Groceries found %d transactions, want 1", len(groceries))
+	}
+	for _, q := range []string{"groc", "GROC", "gro & | !"} {
+		if got := search(q); len(got) != 1 || got[0] != groceries[0] {
+			t.Fatalf("search for %q found %v, want %v", q, got, groceries)
+		}
+	}
+
+	// tsquery operators and quoting are word separators, not syntax
+	for _, q := range []string{`coffee:* & !groceries`, `(coffee | rent)`, `'coffee'`, `coffee <-> shop`, `\coffee\`, `co'ffee`} {
+		search(q)
+	}
+	for _, q := range []string{"", "&|!():*", `'"`} {
+		path := fmt.Sprintf("/api/users/%d/transactions/search?%s", userID, url.Values{"q": {q}}.Encode())
+		s.doExpect(t, http.MethodGet, path, token, nil, http.StatusBadRequest, nil)
+	}
+
+	// notes are searchable as soon as they're set, and not once they're cleared
+	if got := search("birthday"); len(got) != 0 {
+		t.Fatalf("search for birthday found %v before any notes were set", got)
+	}
+	notesPath := fmt.Sprintf("/api/transactions/%d", groceries[0])
+	s.doExpect(t, http.MethodPatch, notesPath, token, map[string]any{"notes": "Birthday <mark>cake</mark> for Sam <3"}, http.StatusOK, nil)
+	if got := search("birthday"); len(got) != 1 || got[0] != groceries[0] {
+		t.Fatalf("search for birthday found %v, want %v", got, groceries)
+	}
+
+	// the notes' own markup never reaches the snippet as markup, only the match is highlighted
+	snippet := snippets[0]
+	unmarked := strings.NewReplacer("<mark>Birthday</mark>", "Birthday").Replace(snippet)
+	if !strings.Contains(snippet, "<mark>Birthday</mark>") || strings.ContainsAny(unmarked, "<>") {
+		t.Fatalf("snippet %q, want only Birthday highlighted and the notes escaped", snippet)
+	}
+	s.doExpect(t, http.MethodPatch, notesPath, token, map[string]any{"notes": nil}, http.StatusOK, nil)
+	if got := search("birthday"); len(got) != 0 {
+		t.Fatalf("search for birthday found %v after the notes were cleared", got)
+	}
+}
+
+// TestUpdateTransactionNotesLength checks the notes limit counts characters, not bytes
+func TestUpdateTransactionNotesLength(t *testing.T) {
+	s := newTestServer(t)
+
+	userID, token := s.signUp(t, "alice")
+	itemID := s.linkItem(t, token)
+	s.syncItem(t, token, itemID)
+
+	var page struct {
+		Transactions []struct {
+			ID int `json:"id"`
+		} `json:"transactions"`
+	}
+	s.doExpect(t, http.MethodGet, fmt.Sprintf("/api/transactions/%d", userID), token, nil, http.StatusOK, &page)
+	path := fmt.Sprintf("/api/transactions/%d", page.Transactions[0].ID)
+
+	longest := strings.Repeat("é", maxTransactionNotesLength)
+	var updated struct {
+		Notes string `json:"notes"`
+	}
+	s.doExpect(t, http.MethodPatch, path, token, map[string]any{"notes": longest}, http.StatusOK, &updated)
+	if updated.Notes != longest {
+		t.Fatalf("notes = %d characters, want the %d sent", len([]rune(updated.Notes)), maxTransactionNotesLength)
+	}
+	s.doExpect(t, http.MethodPatch, path, token, map[string]any{"notes": longest + "é"}, http.StatusBadRequest, nil)
+}
@@ -1,86 +1,85 @@
 package handlers
 
 import (
-	"compound/go-server/internal/db"
+	"compound/go-server/internal/auth"
 	"context"
+	"errors"
 	"net/http"
-	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
+// uniqueViolation is the PostgreSQL error code for a unique constraint violation
+const uniqueViolation = "23505"
+
 // CreateUser handles POST /api/users
-func CreateUser(c *gin.Context) {
-	// Get username from request body
+// Signs up a new user, log in afterwards with POST /api/sessions
+func (h *Handler) CreateUser(c *gin.Context) {
+	// Get username and password from request body
 	var req struct {
 		Username string `json:"username" binding:"required"`
+		Password string `json:"password" binding:"required"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "username is required",
+			"error": "username and password are required",
 		})
 		return
 	}
 
-	user, err := db.CreateUser(context.Background(), req.Username)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "user not created successfully - check server logs",
+	passwordHash, err := auth.HashPassword(req.Password)
+	if errors.Is(err, auth.ErrInvalidPassword) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
 		})
 		return
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"id":         user.ID,
-		"username":   user.Username,
-		"created_at": user.CreatedAt,
-	})
-}
-
-// GetUser handles GET /api/users/:id
-func GetUser(c *gin.Context) {
-	// Get user ID from URL parameter
-	idStr := c.Param("id")
-	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid user id",
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "user not created successfully - check server logs",
 		})
 		return
 	}
 
-	user, err := db.GetUserByID(context.Background(), id)
+	user, err := h.store.CreateUser(context.Background(), req.Username, passwordHash)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "user not found",
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "username is already taken",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "user not created successfully - check server logs",
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"id":         user.ID,
-		"username":   user.Username,
-		"created_at": user.CreatedAt,
-	})
+	c.JSON(http.StatusOK, newUserResponse(user))
+}
+
+// GetUser handles GET /api/users/:id
+// RequireSelf has already checked the ID is the current user's
+func (h *Handler) GetUser(c *gin.Context) {
+	c.JSON(http.StatusOK, newUserResponse(currentUser(c)))
 }
 
 // GetUserByUsername handles GET /api/users/username/:username
-func GetUserByUsername(c *gin.Context) {
+// Users can only look themselves up, any other username is reported as not found
+func (h *Handler) GetUserByUsername(c *gin.Context) {
 	// Get username from URL parameter
 	username := c.Param("username")
 
-	user, err := db.GetUserByUsername(context.Background(), username)
-	if err != nil {
+	user := currentUser(c)
+	if user.Username != username {
 		c.JSON(http.StatusNotFound, gin.H{
 			"error": "user not found",
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"id":         user.ID,
-		"username":   user.Username,
-		"created_at": user.CreatedAt,
-	})
+	c.JSON(http.StatusOK, newUserResponse(user))
 }
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	plaidpkg "compound/go-server/internal/plaid"
+	"compound/go-server/internal/redact"
+	"compound/go-server/pkg/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// maxWebhookBodyBytes caps how much of a webhook body we read before verifying it
+const maxWebhookBodyBytes = 1 << 20
+
+// PlaidWebhook is the subset of the webhook payload we act on
+// See https://plaid.com/docs/api/webhooks/
+type PlaidWebhook struct {
+	WebhookType string             `json:"webhook_type"`
+	WebhookCode string             `json:"webhook_code"`
+	ItemID      string             `json:"item_id"`
+	Error       *PlaidWebhookError `json:"error"`
+}
+
+// PlaidWebhookError is the error object attached to ITEM: ERROR webhooks
+type PlaidWebhookError struct {
+	ErrorType    string `json:"error_type"`
+	ErrorCode    string `json:"error_code"`
+	ErrorMessage string `json:"error_message"`
+}
+
+// itemWebhookStatuses maps ITEM webhook codes to the status the item moves to
+// An ERROR carrying ITEM_LOGIN_REQUIRED moves the item to login_required instead of bad
+var itemWebhookStatuses = map[string]string{
+	"ERROR":                   models.ItemStatusBad,
+	"PENDING_EXPIRATION":      models.ItemStatusPendingExpiration,
+	"PENDING_DISCONNECT":      models.ItemStatusPendingDisconnect,
+	"USER_PERMISSION_REVOKED": models.ItemStatusRevoked,
+	"LOGIN_REPAIRED":          models.ItemStatusGood,
+}
+
+// MakePlaidWebhookHandler creates a handler for POST /api/webhooks/plaid
+// Every request must carry a valid Plaid-Verification JWT, verified against the raw body
+//
+// The handler uses a closure to capture the verifier so tests can swap in a local key
+func (h *Handler) MakePlaidWebhookHandler(verifier *plaidpkg.WebhookVerifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxWebhookBodyBytes))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "failed to read webhook body",
+			})
+			return
+		}
+
+		err = verifier.Verify(c.Request.Context(), c.GetHeader("Plaid-Verification"), body)
+		if err != nil {
+			log.Printf("WEBHOOK: rejected: %s", redact.Error(err))
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "webhook verification failed",
+			})
+			return
+		}
+
+		var webhook PlaidWebhook
+		if err := json.Unmarshal(body, &webhook); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid webhook body",
+			})
+			return
+		}
+
+		switch webhook.WebhookType {
+		case "TRANSACTIONS":
+			err = h.handleTransactionsWebhook(c.Request.Context(), webhook)
+		case "ITEM":
+			err = h.handleItemWebhook(c.Request.Context(), webhook)
+		default:
+			log.Printf("UNHANDLED %s WEBHOOK: %s: Plaid item id %s: unhandled webhook type received", webhook.WebhookType, webhook.WebhookCode, webhook.ItemID)
+		}
+
+		if err != nil {
+			log.Printf("WEBHOOK: %s: %s: Plaid item id %s: %s", webhook.WebhookType, webhook.WebhookCode, webhook.ItemID, redact.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "failed to handle webhook",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"received": true,
+		})
+	}
+}
+
+// handleTransactionsWebhook starts a sync when Plaid reports new transaction data
+// Plaid expects a fast response, so the sync runs in the background
+func (h *Handler) handleTransactionsWebhook(ctx context.Context, webhook PlaidWebhook) error {
+	switch webhook.WebhookCode {
+	case "SYNC_UPDATES_AVAILABLE":
+		item, err := h.getWebhookItem(ctx, webhook)
+		if err != nil || item == nil {
+			return err
+		}
+
+		// bursts of webhooks for one item collapse into at most one more sync
+		h.syncer.SyncInBackground(item.ID, models.SyncTriggerWebhook)
+		log.Printf("WEBHOOK: TRANSACTIONS: %s: Plaid item id %s -> item %d sync queued", webhook.WebhookCode, webhook.ItemID, item.ID)
+	case "DEFAULT_UPDATE", "INITIAL_UPDATE", "HISTORICAL_UPDATE":
+		// ignore - not needed when using the sync endpoint + SYNC_UPDATES_AVAILABLE
+	default:
+		log.Printf("WEBHOOK: TRANSACTIONS: %s: Plaid item id %s: unhandled webhook type received", webhook.WebhookCode, webhook.ItemID)
+	}
+	return nil
+}
+
+// handleItemWebhook moves the item to the status matching the webhook code
+// NEW_ACCOUNTS_AVAILABLE doesn't change the status, it flags the item instead
+func (h *Handler) handleItemWebhook(ctx context.Context, webhook PlaidWebhook) error {
+	if webhook.WebhookCode == "NEW_ACCOUNTS_AVAILABLE" {
+		return h.flagNewAccountsAvailable(ctx, webhook)
+	}
+
+	status, ok := itemWebhookStatuses[webhook.WebhookCode]
+	if !ok {
+		log.Printf("WEBHOOK: ITEM: %s: Plaid item id %s: unhandled webhook type received", webhook.WebhookCode, webhook.ItemID)
+		return nil
+	}
+
+	// the client sends the user through Link update mode for login_required items
+	if webhook.Error != nil && webhook.Error.ErrorCode == plaidpkg.ErrorCodeItemLoginRequired {
+		status = models.ItemStatusLoginRequired
+	}
+
+	item, err := h.getWebhookItem(ctx, webhook)
+	if err != nil || item == nil {
+		return err
+	}
+
+	if err := h.store.UpdateItemStatus(ctx, item.ID, status); err != nil {
+		return err
+	}
+
+	if webhook.Error != nil {
+		log.Printf("WEBHOOK: ITEM: %s: Plaid item id %s: %s: %s -> %s", webhook.WebhookCode, webhook.ItemID, webhook.Error.ErrorCode, webhook.Error.ErrorMessage, status)
+	} else {
+		log.Printf("WEBHOOK: ITEM: %s: Plaid item id %s -> %s", webhook.WebhookCode, webhook.ItemID, status)
+	}
+
+	return nil
+}
+
+// flagNewAccountsAvailable marks the item so listings prompt the user to share the new accounts
+// The flag is cleared by POST /api/items/:id/relink-complete after account selection update mode
+func (h *Handler) flagNewAccountsAvailable(ctx context.Context, webhook PlaidWebhook) error {
+	item, err := h.getWebhookItem(ctx, webhook)
+	if err != nil || item == nil {
+		return err
+	}
+
+	if err := h.store.UpdateItemNewAccountsAvailable(ctx, item.ID, true); err != nil {
+		return err
+	}
+
+	log.Printf("WEBHOOK: ITEM: %s: Plaid item id %s -> new accounts available", webhook.WebhookCode, webhook.ItemID)
+	return nil
+}
+
+// getWebhookItem looks up the item a webhook is for
+// Returns a nil item and a nil error for an item we don't have, e.g. one deleted locally. Plaid
+// retries webhooks that get a 500 forever, so those are logged and acknowledged instead.
+func (h *Handler) getWebhookItem(ctx context.Context, webhook PlaidWebhook) (*models.Item, error) {
+	item, err := h.store.GetItemByPlaidItemID(ctx, webhook.ItemID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		log.Printf("WEBHOOK: %s: %s: Plaid item id %s: unknown item, ignoring", webhook.WebhookType, webhook.WebhookCode, webhook.ItemID)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get item: %w", err)
+	}
+	return item, nil
+}
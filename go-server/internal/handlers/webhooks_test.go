@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"bytes"
+	"compound/go-server/pkg/models"
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// sendWebhook posts body to the webhook endpoint with a Plaid-Verification JWT signed by the
+// test server's webhook key
+func (s *testServer) sendWebhook(t *testing.T, body any) *httptest.ResponseRecorder {
+	t.Helper()
+
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/webhooks/plaid", bytes.NewReader(encoded))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Plaid-Verification", signTestWebhook(t, s.webhookKey, encoded))
+
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+// signTestWebhook builds the ES256 JWT Plaid sends with a webhook, issued now
+func signTestWebhook(t *testing.T, key *ecdsa.PrivateKey, body []byte) string {
+	t.Helper()
+
+	bodyHash := sha256.Sum256(body)
+	header, err := json.Marshal(map[string]string{"alg": "ES256", "kid": "test-key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := json.Marshal(map[string]any{"iat": time.Now().Unix(), "request_body_sha256": hex.EncodeToString(bodyHash[:])})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	r, sigS, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	sigS.FillBytes(sig[32:])
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// linkedPlaidItemID returns the Plaid item ID webhooks for the item carry
+func (s *testServer) linkedPlaidItemID(t *testing.T, itemID int) string {
+	t.Helper()
+
+	item, err := s.store.GetItemByID(context.Background(), itemID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return item.PlaidItemID
+}
+
+// TestItemWebhooks checks each ITEM webhook leaves the item in the status the client acts on
+func TestItemWebhooks(t *testing.T) {
+	s := newTestServer(t)
+	_, alice := s.signUp(t, "alice")
+	itemID := s.linkItem(t, alice)
+	plaidItemID := s.linkedPlaidItemID(t, itemID)
+
+	tests := []struct {
+		name    string
+		webhook PlaidWebhook
+		want    string
+	}{
+		{
+			name:    "ERROR ITEM_LOGIN_REQUIRED",
+			webhook: PlaidWebhook{WebhookCode: "ERROR", Error: &PlaidWebhookError{ErrorType: "ITEM_ERROR", ErrorCode: "ITEM_LOGIN_REQUIRED"}},
+			want:    models.ItemStatusLoginRequired,
+		},
+		{
+			name:    "ERROR",
+			webhook: PlaidWebhook{WebhookCode: "ERROR", Error: &PlaidWebhookError{ErrorType: "ITEM_ERROR", ErrorCode: "INVALID_CREDENTIALS"}},
+			want:    models.ItemStatusBad,
+		},
+		{name: "LOGIN_REPAIRED", webhook: PlaidWebhook{WebhookCode: "LOGIN_REPAIRED"}, want: models.ItemStatusGood},
+		{name: "PENDING_EXPIRATION", webhook: PlaidWebhook{WebhookCode: "PENDING_EXPIRATION"}, want: models.ItemStatusPendingExpiration},
+		{name: "USER_PERMISSION_REVOKED", webhook: PlaidWebhook{WebhookCode: "USER_PERMISSION_REVOKED"}, want: models.ItemStatusRevoked},
+		// codes we don't act on leave the status alone
+		{name: "WEBHOOK_UPDATE_ACKNOWLEDGED", webhook: PlaidWebhook{WebhookCode: "WEBHOOK_UPDATE_ACKNOWLEDGED"}, want: models.ItemStatusRevoked},
+	}
+	for _, tt := range tests {
+		tt.webhook.WebhookType = "ITEM"
+		tt.webhook.ItemID = plaidItemID
+		if w := s.sendWebhook(t, tt.webhook); w.Code != http.StatusOK {
+			t.Fatalf("%s: webhook = %d %s, want 200", tt.name, w.Code, w.Body.String())
+		}
+
+		item, err := s.store.GetItemByID(context.Background(), itemID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if item.Status != tt.want {
+			t.Errorf("%s: status = %s, want %s", tt.name, item.Status, tt.want)
+		}
+	}
+}
+
+// TestNewAccountsAvailableWebhook checks the webhook flags the item in listings until the user
+// shares the new accounts through update mode
+func TestNewAccountsAvailableWebhook(t *testing.T) {
+	s := newTestServer(t)
+	_, alice := s.signUp(t, "alice")
+	itemID := s.linkItem(t, alice)
+
+	newAccountsAvailable := func() bool {
+		t.Helper()
+
+		var list struct {
+			Items []struct {
+				ID                   int    `json:"id"`
+				Status               string `json:"status"`
+				NewAccountsAvailable bool   `json:"new_accounts_available"`
+			} `json:"items"`
+		}
+		s.doExpect(t, http.MethodGet, "/api/items", alice, nil, http.StatusOK, &list)
+		if len(list.Items) != 1 || list.Items[0].ID != itemID {
+			t.Fatalf("items = %+v, want only item %d", list.Items, itemID)
+		}
+		// the flag asks for update mode, it doesn't mean the item is broken
+		if list.Items[0].Status == models.ItemStatusBad || list.Items[0].Status == models.ItemStatusLoginRequired {
+			t.Fatalf("status = %s after NEW_ACCOUNTS_AVAILABLE", list.Items[0].Status)
+		}
+		return list.Items[0].NewAccountsAvailable
+	}
+
+	if newAccountsAvailable() {
+		t.Fatal("new accounts available before the webhook")
+	}
+
+	webhook := PlaidWebhook{WebhookType: "ITEM", WebhookCode: "NEW_ACCOUNTS_AVAILABLE", ItemID: s.linkedPlaidItemID(t, itemID)}
+	if w := s.sendWebhook(t, webhook); w.Code != http.StatusOK {
+		t.Fatalf("webhook = %d %s, want 200", w.Code, w.Body.String())
+	}
+	if !newAccountsAvailable() {
+		t.Fatal("new accounts not flagged after the webhook")
+	}
+
+	s.doExpect(t, http.MethodPost, "/api/link-token", alice, map[string]any{"itemId": itemID, "accountSelection": true}, http.StatusOK, nil)
+	s.doExpect(t, http.MethodPost, fmt.Sprintf("/api/items/%d/relink-complete", itemID), alice, nil, http.StatusOK, nil)
+	if newAccountsAvailable() {
+		t.Fatal("new accounts still flagged after relink-complete")
+	}
+	s.waitForSyncRun(t, itemID, models.SyncTriggerRelink)
+}
+
+// TestSyncUpdatesAvailableWebhook checks Plaid's new transaction data notice starts a sync
+func TestSyncUpdatesAvailableWebhook(t *testing.T) {
+	s := newTestServer(t)
+	_, alice := s.signUp(t, "alice")
+	itemID := s.linkItem(t, alice)
+
+	webhook := PlaidWebhook{WebhookType: "TRANSACTIONS", WebhookCode: "SYNC_UPDATES_AVAILABLE", ItemID: s.linkedPlaidItemID(t, itemID)}
+	if w := s.sendWebhook(t, webhook); w.Code != http.StatusOK {
+		t.Fatalf("webhook = %d %s, want 200", w.Code, w.Body.String())
+	}
+
+	run := s.waitForSyncRun(t, itemID, models.SyncTriggerWebhook)
+	if run.AddedCount != testSyncSampleCount {
+		t.Fatalf("webhook sync added %d transactions, want %d", run.AddedCount, testSyncSampleCount)
+	}
+}
+
+// TestWebhookUnknownItem checks webhooks for items we don't have are acknowledged, Plaid would
+// otherwise retry them forever
+func TestWebhookUnknownItem(t *testing.T) {
+	s := newTestServer(t)
+
+	for _, webhook := range []PlaidWebhook{
+		{WebhookType: "ITEM", WebhookCode: "ERROR", ItemID: "item-deleted", Error: &PlaidWebhookError{ErrorCode: "ITEM_LOGIN_REQUIRED"}},
+		{WebhookType: "ITEM", WebhookCode: "NEW_ACCOUNTS_AVAILABLE", ItemID: "item-deleted"},
+		{WebhookType: "TRANSACTIONS", WebhookCode: "SYNC_UPDATES_AVAILABLE", ItemID: "item-deleted"},
+	} {
+		if w := s.sendWebhook(t, webhook); w.Code != http.StatusOK {
+			t.Errorf("%s %s = %d %s, want 200", webhook.WebhookType, webhook.WebhookCode, w.Code, w.Body.String())
+		}
+	}
+}
+
+// TestWebhookRejectsUnsignedBody checks a webhook is only acted on with a valid signature
+func TestWebhookRejectsUnsignedBody(t *testing.T) {
+	s := newTestServer(t)
+	_, alice := s.signUp(t, "alice")
+	itemID := s.linkItem(t, alice)
+
+	body := []byte(`{"webhook_type":"ITEM","webhook_code":"USER_PERMISSION_REVOKED","item_id":"` + s.linkedPlaidItemID(t, itemID) + `"}`)
+	signed := signTestWebhook(t, s.webhookKey, []byte(`{"webhook_type":"ITEM","webhook_code":"LOGIN_REPAIRED"}`))
+
+	for name, verification := range map[string]string{"unsigned": "", "signed for another body": signed} {
+		req := httptest.NewRequest(http.MethodPost, "/api/webhooks/plaid", bytes.NewReader(body))
+		req.Header.Set("Plaid-Verification", verification)
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: webhook = %d, want 401", name, w.Code)
+		}
+	}
+
+	item, err := s.store.GetItemByID(context.Background(), itemID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Status == models.ItemStatusRevoked {
+		t.Fatal("an unverified webhook revoked the item")
+	}
+}
@@ -3,16 +3,33 @@ package plaid
 import (
 	"context"
 	"fmt"
+	"time"
 
 	plaid "github.com/plaid/plaid-go/v40/plaid"
 )
 
-var (
+// Aggregator is the set of bank-data operations the server depends on
+// Client talks to the real Plaid API, Fake serves deterministic data in memory
+type Aggregator interface {
+	CreateLinkToken(ctx context.Context, userID int, products []string, countryCodes []string, redirectURI string, webhookURL string, update *LinkTokenUpdate) (string, error)
+	ExchangePublicToken(ctx context.Context, publicToken string) (string, string, error)
+	GetAccounts(ctx context.Context, accessToken string) ([]plaid.AccountBase, error)
+	GetAccountBalances(ctx context.Context, accessToken string) ([]plaid.AccountBase, error)
+	SyncTransactions(ctx context.Context, accessToken string, cursor *string) (SyncTransactionsResult, error)
+	GetItem(ctx context.Context, accessToken string) (plaid.ItemWithConsentFields, error)
+	InstitutionsGetByID(ctx context.Context, institutionID string) (plaid.Institution, error)
+	RemoveItem(ctx context.Context, accessToken string) error
+}
+
+// Client is the Aggregator backed by the Plaid API
+// Every call is recorded in plaid_api_events_table once SetEventRecorder is called
+type Client struct {
 	apiClient *plaid.APIClient
-)
+	recorder  EventRecorder
+}
 
-// Initialize sets up the Plaid API client with credentials
-func Initialize(clientID, secret, env string) error {
+// NewClient sets up the Plaid API client with credentials
+func NewClient(clientID, secret, env string) (*Client, error) {
 	configuration := plaid.NewConfiguration()
 	configuration.AddDefaultHeader("PLAID-CLIENT-ID", clientID)
 	configuration.AddDefaultHeader("PLAID-SECRET", secret)
@@ -25,30 +42,30 @@ func Initialize(clientID, secret, env string) error {
 	if envType, ok := environments[env]; ok {
 		configuration.UseEnvironment(envType)
 	} else {
-		return fmt.Errorf("invalid environment: %s", env)
+		return nil, fmt.Errorf("invalid environment: %s", env)
 	}
 
-	apiClient = plaid.NewAPIClient(configuration)
-	return nil
+	return &Client{apiClient: plaid.NewAPIClient(configuration)}, nil
 }
 
-// GetClient returns the initialized Plaid API client
-func GetClient() *plaid.APIClient {
-	return apiClient
+// LinkTokenUpdate puts a link token in update mode for an existing item
+type LinkTokenUpdate struct {
+	AccessToken string
+	// AccountSelection lets the user share accounts opened since the item was linked
+	AccountSelection bool
 }
 
 // CreateLinkToken creates a new Plaid Link token for account linking
-func CreateLinkToken(
+// A non-nil update creates a token for update mode instead
+func (c *Client) CreateLinkToken(
 	ctx context.Context,
 	userID int,
 	products []string,
 	countryCodes []string,
 	redirectURI string,
+	webhookURL string,
+	update *LinkTokenUpdate,
 ) (string, error) {
-	if apiClient == nil {
-		return "", fmt.Errorf("plaid client not initialized")
-	}
-
 	// Convert string country codes to plaid.CountryCode enum
 	countryCodeEnums := convertCountryCodes(countryCodes)
 
@@ -76,41 +93,77 @@ func CreateLinkToken(
 		request.SetRedirectUri(redirectURI)
 	}
 
-	resp, _, err := apiClient.PlaidApi.LinkTokenCreate(ctx).LinkTokenCreateRequest(*request).Execute()
+	// Set webhook URL if provided so Plaid can notify us of item and transaction updates
+	if webhookURL != "" {
+		request.SetWebhook(webhookURL)
+	}
+
+	// Update mode identifies the item by its access token
+	accountSelection := false
+	if update != nil {
+		request.SetAccessToken(update.AccessToken)
+		if update.AccountSelection {
+			accountSelection = true
+			request.SetUpdate(plaid.LinkTokenCreateRequestUpdate{AccountSelectionEnabled: &accountSelection})
+		}
+	}
+
+	start := time.Now()
+	resp, _, err := c.apiClient.PlaidApi.LinkTokenCreate(ctx).LinkTokenCreateRequest(*request).Execute()
+	c.record(ctx, "linkTokenCreate", map[string]any{
+		"client_user_id":            user.ClientUserId,
+		"products":                  products,
+		"country_codes":             countryCodes,
+		"redirect_uri":              redirectURI,
+		"webhook":                   webhookURL,
+		"update_mode":               update != nil,
+		"account_selection_enabled": accountSelection,
+	}, resp.GetRequestId(), start, err)
 	if err != nil {
-		return "", fmt.Errorf("failed to create link token: %w", err)
+		return "", fmt.Errorf("failed to create link token: %w", wrapError(err))
 	}
 
 	return resp.GetLinkToken(), nil
 }
 
 // ExchangePublicToken exchanges a public token from Link for an access token and item ID
-func ExchangePublicToken(ctx context.Context, publicToken string) (string, string, error) {
-	if apiClient == nil {
-		return "", "", fmt.Errorf("plaid client not initialized")
-	}
-
+func (c *Client) ExchangePublicToken(ctx context.Context, publicToken string) (string, string, error) {
 	request := plaid.NewItemPublicTokenExchangeRequest(publicToken)
 
-	resp, _, err := apiClient.PlaidApi.ItemPublicTokenExchange(ctx).ItemPublicTokenExchangeRequest(*request).Execute()
+	start := time.Now()
+	resp, _, err := c.apiClient.PlaidApi.ItemPublicTokenExchange(ctx).ItemPublicTokenExchangeRequest(*request).Execute()
+	c.record(ctx, "itemPublicTokenExchange", nil, resp.GetRequestId(), start, err)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to exchange public token: %w", err)
+		return "", "", fmt.Errorf("failed to exchange public token: %w", wrapError(err))
 	}
 
 	return resp.GetAccessToken(), resp.GetItemId(), nil
 }
 
 // GetAccounts retrieves accounts for an item using the access token
-func GetAccounts(ctx context.Context, accessToken string) ([]plaid.AccountBase, error) {
-	if apiClient == nil {
-		return nil, fmt.Errorf("plaid client not initialized")
+func (c *Client) GetAccounts(ctx context.Context, accessToken string) ([]plaid.AccountBase, error) {
+	request := plaid.NewAccountsGetRequest(accessToken)
+
+	start := time.Now()
+	resp, _, err := c.apiClient.PlaidApi.AccountsGet(ctx).AccountsGetRequest(*request).Execute()
+	c.record(ctx, "accountsGet", nil, resp.GetRequestId(), start, err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get accounts: %w", wrapError(err))
 	}
 
-	request := plaid.NewAccountsGetRequest(accessToken)
+	return resp.GetAccounts(), nil
+}
 
-	resp, _, err := apiClient.PlaidApi.AccountsGet(ctx).AccountsGetRequest(*request).Execute()
+// GetAccountBalances retrieves the item's accounts with balances fetched from the institution in real time
+// GetAccounts returns balances cached by Plaid, this is slower and billed per call
+func (c *Client) GetAccountBalances(ctx context.Context, accessToken string) ([]plaid.AccountBase, error) {
+	request := plaid.NewAccountsBalanceGetRequest(accessToken)
+
+	start := time.Now()
+	resp, _, err := c.apiClient.PlaidApi.AccountsBalanceGet(ctx).AccountsBalanceGetRequest(*request).Execute()
+	c.record(ctx, "accountsBalanceGet", nil, resp.GetRequestId(), start, err)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get accounts: %w", err)
+		return nil, fmt.Errorf("failed to get account balances: %w", wrapError(err))
 	}
 
 	return resp.GetAccounts(), nil
@@ -121,20 +174,17 @@ type SyncTransactionsResult struct {
 	Added      []plaid.Transaction
 	Modified   []plaid.Transaction
 	Removed    []plaid.RemovedTransaction
+	Accounts   []plaid.AccountBase
 	NextCursor string
 	HasMore    bool
 }
 
 // SyncTransactions uses the Transactions Sync API to fetch transactions with cursor-based pagination
-func SyncTransactions(
+func (c *Client) SyncTransactions(
 	ctx context.Context,
 	accessToken string,
 	cursor *string,
 ) (SyncTransactionsResult, error) {
-	if apiClient == nil {
-		return SyncTransactionsResult{}, fmt.Errorf("plaid client not initialized")
-	}
-
 	result := SyncTransactionsResult{}
 
 	request := plaid.NewTransactionsSyncRequest(accessToken)
@@ -142,14 +192,17 @@ func SyncTransactions(
 		request.SetCursor(*cursor)
 	}
 
-	resp, _, err := apiClient.PlaidApi.TransactionsSync(ctx).TransactionsSyncRequest(*request).Execute()
+	start := time.Now()
+	resp, _, err := c.apiClient.PlaidApi.TransactionsSync(ctx).TransactionsSyncRequest(*request).Execute()
+	c.record(ctx, "transactionsSync", map[string]any{"cursor": request.GetCursor()}, resp.GetRequestId(), start, err)
 	if err != nil {
-		return result, fmt.Errorf("failed to sync transactions: %w", err)
+		return result, fmt.Errorf("failed to sync transactions: %w", wrapError(err))
 	}
 
 	result.Added = resp.GetAdded()
 	result.Modified = resp.GetModified()
 	result.Removed = resp.GetRemoved()
+	result.Accounts = resp.GetAccounts()
 	result.NextCursor = resp.GetNextCursor()
 	result.HasMore = resp.GetHasMore()
 
@@ -157,40 +210,50 @@ func SyncTransactions(
 }
 
 // GetItem retrieves item details
-func GetItem(ctx context.Context, accessToken string) (plaid.ItemWithConsentFields, error) {
-	if apiClient == nil {
-		return plaid.ItemWithConsentFields{}, fmt.Errorf("plaid client not initialized")
-	}
-
+func (c *Client) GetItem(ctx context.Context, accessToken string) (plaid.ItemWithConsentFields, error) {
 	request := plaid.NewItemGetRequest(accessToken)
 
-	resp, _, err := apiClient.PlaidApi.ItemGet(ctx).ItemGetRequest(*request).Execute()
+	start := time.Now()
+	resp, _, err := c.apiClient.PlaidApi.ItemGet(ctx).ItemGetRequest(*request).Execute()
+	c.record(ctx, "itemGet", nil, resp.GetRequestId(), start, err)
 	if err != nil {
-		return plaid.ItemWithConsentFields{}, fmt.Errorf("failed to get item: %w", err)
+		return plaid.ItemWithConsentFields{}, fmt.Errorf("failed to get item: %w", wrapError(err))
 	}
 
 	return resp.GetItem(), nil
 }
 
 // InstitutionsGetByID retrieves institution details by ID
-func InstitutionsGetByID(ctx context.Context, institutionID string) (plaid.Institution, error) {
-	if apiClient == nil {
-		return plaid.Institution{}, fmt.Errorf("plaid client not initialized")
-	}
-
+func (c *Client) InstitutionsGetByID(ctx context.Context, institutionID string) (plaid.Institution, error) {
 	// Convert country codes string to slice
 	countryCodes := []plaid.CountryCode{plaid.COUNTRYCODE_US}
 
 	request := plaid.NewInstitutionsGetByIdRequest(institutionID, countryCodes)
 
-	resp, _, err := apiClient.PlaidApi.InstitutionsGetById(ctx).InstitutionsGetByIdRequest(*request).Execute()
+	start := time.Now()
+	resp, _, err := c.apiClient.PlaidApi.InstitutionsGetById(ctx).InstitutionsGetByIdRequest(*request).Execute()
+	c.record(ctx, "institutionsGetById", map[string]any{"institution_id": institutionID}, resp.GetRequestId(), start, err)
 	if err != nil {
-		return plaid.Institution{}, fmt.Errorf("failed to get institution: %w", err)
+		return plaid.Institution{}, fmt.Errorf("failed to get institution: %w", wrapError(err))
 	}
 
 	return resp.GetInstitution(), nil
 }
 
+// RemoveItem invalidates the access token and removes the item from Plaid
+func (c *Client) RemoveItem(ctx context.Context, accessToken string) error {
+	request := plaid.NewItemRemoveRequest(accessToken)
+
+	start := time.Now()
+	resp, _, err := c.apiClient.PlaidApi.ItemRemove(ctx).ItemRemoveRequest(*request).Execute()
+	c.record(ctx, "itemRemove", nil, resp.GetRequestId(), start, err)
+	if err != nil {
+		return fmt.Errorf("failed to remove item: %w", wrapError(err))
+	}
+
+	return nil
+}
+
 // Helper functions to convert string slices to enum types
 
 func convertCountryCodes(countryCodeStrs []string) []plaid.CountryCode {
@@ -0,0 +1,116 @@
+package plaid
+
+import (
+	"errors"
+
+	plaid "github.com/plaid/plaid-go/v40/plaid"
+)
+
+// Plaid error types, see https://plaid.com/docs/errors/
+const (
+	ErrorTypeInvalidRequest    = "INVALID_REQUEST"
+	ErrorTypeInvalidInput      = "INVALID_INPUT"
+	ErrorTypeInvalidResult     = "INVALID_RESULT"
+	ErrorTypeRateLimitExceeded = "RATE_LIMIT_EXCEEDED"
+	ErrorTypeAPIError          = "API_ERROR"
+	ErrorTypeItemError         = "ITEM_ERROR"
+	ErrorTypeInstitutionError  = "INSTITUTION_ERROR"
+)
+
+// Plaid error codes we act on
+const (
+	ErrorCodeItemLoginRequired  = "ITEM_LOGIN_REQUIRED"
+	ErrorCodeItemNotFound       = "ITEM_NOT_FOUND"
+	ErrorCodeInvalidPublicToken = "INVALID_PUBLIC_TOKEN"
+	ErrorCodeInvalidAccountID   = "INVALID_ACCOUNT_ID"
+)
+
+// PlaidError is an error response from the Plaid API
+// Client methods wrap every Plaid failure in one, use AsPlaidError to get at it.
+// DisplayMessage is meant for end users, the rest is for developers and support.
+type PlaidError struct {
+	ErrorType      string
+	ErrorCode      string
+	ErrorMessage   string
+	DisplayMessage string
+	RequestID      string
+
+	err error
+}
+
+// Error returns the Plaid error code and developer message
+func (e *PlaidError) Error() string {
+	return e.ErrorCode + ": " + e.ErrorMessage
+}
+
+// Unwrap returns the plaid.GenericOpenAPIError the error was parsed from
+func (e *PlaidError) Unwrap() error {
+	return e.err
+}
+
+// AsPlaidError finds the Plaid API error in err's chain
+// Returns false for errors that didn't come back from Plaid (timeouts, network errors)
+func AsPlaidError(err error) (*PlaidError, bool) {
+	var plaidErr *PlaidError
+	if errors.As(err, &plaidErr) {
+		return plaidErr, true
+	}
+
+	var apiErr plaid.GenericOpenAPIError
+	if !errors.As(err, &apiErr) {
+		return nil, false
+	}
+	return parseError(apiErr)
+}
+
+// parseError converts the error returned by the generated Plaid client
+func parseError(apiErr plaid.GenericOpenAPIError) (*PlaidError, bool) {
+	model, err := plaid.ToPlaidError(apiErr)
+	if err != nil {
+		return nil, false
+	}
+
+	return &PlaidError{
+		ErrorType:      string(model.GetErrorType()),
+		ErrorCode:      model.GetErrorCode(),
+		ErrorMessage:   model.GetErrorMessage(),
+		DisplayMessage: model.GetDisplayMessage(),
+		RequestID:      model.GetRequestId(),
+		err:            apiErr,
+	}, true
+}
+
+// wrapError replaces a Plaid API error with its PlaidError so the message says what went wrong
+// Other errors are returned unchanged
+func wrapError(err error) error {
+	if plaidErr, ok := AsPlaidError(err); ok {
+		return plaidErr
+	}
+	return err
+}
+
+// ErrorCode returns the Plaid error_code carried by err
+// Returns an empty string if err did not come back from the Plaid API
+func ErrorCode(err error) string {
+	plaidErr, ok := AsPlaidError(err)
+	if !ok {
+		return ""
+	}
+	return plaidErr.ErrorCode
+}
+
+// ErrorType returns the Plaid error_type (e.g. ITEM_ERROR) carried by err
+// Returns an empty string if err did not come back from the Plaid API
+func ErrorType(err error) string {
+	plaidErr, ok := AsPlaidError(err)
+	if !ok {
+		return ""
+	}
+	return plaidErr.ErrorType
+}
+
+// IsLoginRequired reports whether err is Plaid's ITEM_LOGIN_REQUIRED
+// The user must re-authenticate the item through Link update mode before it works again
+func IsLoginRequired(err error) bool {
+	return ErrorCode(err) == ErrorCodeItemLoginRequired
+}
@@ -0,0 +1,98 @@
+package plaid
+
+import (
+	"compound/go-server/internal/redact"
+	"compound/go-server/pkg/models"
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// EventRecorder stores an audit record of every Plaid API call, *db.Store implements it
+type EventRecorder interface {
+	CreatePlaidAPIEvent(ctx context.Context, event *models.PlaidAPIEvent) error
+}
+
+type eventScopeKey struct{}
+
+// eventScope is the user and item a Plaid API call is made for
+type eventScope struct {
+	userID int
+	itemID int
+}
+
+// WithEventScope attributes Plaid API calls made with ctx to a user and item
+// Access tokens are encrypted at rest, so the item can't be looked up from the token the way
+// the Node server does it. Pass 0 for an item that isn't known yet (e.g. during token exchange).
+func WithEventScope(ctx context.Context, userID, itemID int) context.Context {
+	return context.WithValue(ctx, eventScopeKey{}, eventScope{userID: userID, itemID: itemID})
+}
+
+// SetEventRecorder makes the client record every Plaid API call through recorder
+func (c *Client) SetEventRecorder(recorder EventRecorder) {
+	c.recorder = recorder
+}
+
+// record writes the audit event for one Plaid API call
+// args must not hold access or public tokens, they are redacted again as a backstop.
+// Failing to record is logged and never fails the call itself.
+func (c *Client) record(ctx context.Context, method string, args map[string]any, requestID string, start time.Time, callErr error) {
+	if c.recorder == nil {
+		return
+	}
+
+	durationMs := int(time.Since(start).Milliseconds())
+	event := &models.PlaidAPIEvent{
+		PlaidMethod: method,
+		DurationMs:  &durationMs,
+	}
+
+	if scope, ok := ctx.Value(eventScopeKey{}).(eventScope); ok {
+		if scope.userID != 0 {
+			event.UserID = &scope.userID
+		}
+		if scope.itemID != 0 {
+			event.ItemID = &scope.itemID
+		}
+	}
+
+	if len(args) > 0 {
+		if encoded, err := json.Marshal(args); err == nil {
+			arguments := redact.String(string(encoded))
+			event.Arguments = &arguments
+		}
+	}
+
+	if callErr != nil {
+		setEventError(event, callErr)
+	}
+	if event.RequestID == nil && requestID != "" {
+		event.RequestID = &requestID
+	}
+
+	// record even if the call's context was cancelled, that's when the record is most useful
+	if err := c.recorder.CreatePlaidAPIEvent(context.WithoutCancel(ctx), event); err != nil {
+		log.Printf("failed to record Plaid API event %s: %s", method, redact.Error(err))
+	}
+}
+
+// setEventError copies Plaid's structured error fields onto event
+// Errors that didn't come back from Plaid (timeouts, network errors) only get a message
+func setEventError(event *models.PlaidAPIEvent, err error) {
+	message := redact.Error(err)
+	event.ErrorMessage = &message
+
+	plaidErr, ok := AsPlaidError(err)
+	if !ok {
+		return
+	}
+
+	errorMessage := redact.String(plaidErr.ErrorMessage)
+	event.ErrorType = &plaidErr.ErrorType
+	event.ErrorCode = &plaidErr.ErrorCode
+	event.ErrorMessage = &errorMessage
+	if plaidErr.RequestID != "" {
+		event.RequestID = &plaidErr.RequestID
+	}
+}
@@ -0,0 +1,152 @@
+package plaid
+
+import (
+	"compound/go-server/pkg/models"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	plaid "github.com/plaid/plaid-go/v40/plaid"
+)
+
+// testAccessToken is the access token the Plaid stub's calls are made with
+const testAccessToken = "access-sandbox-8ab976e6-64bc-4b38-98f7-731e7a349970"
+
+// eventRecorder is an EventRecorder that keeps events in memory
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []*models.PlaidAPIEvent
+}
+
+func (r *eventRecorder) CreatePlaidAPIEvent(ctx context.Context, event *models.PlaidAPIEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	return nil
+}
+
+// newStubClient returns a Client recording into a new eventRecorder, calling a Plaid stub that
+// answers /item/remove slowly, fails /transactions/sync with ITEM_LOGIN_REQUIRED echoing the
+// request body (as Plaid's INVALID_REQUEST errors do) and creates link tokens
+func newStubClient(t *testing.T) (*Client, *eventRecorder) {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("%s: bad request body: %v", r.URL.Path, err)
+		}
+		echoed, _ := json.Marshal(body)
+
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/item/remove":
+			time.Sleep(20 * time.Millisecond)
+			json.NewEncoder(w).Encode(map[string]any{"request_id": "req-remove"})
+		case "/transactions/sync":
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]any{
+				"error_type": "ITEM_ERROR", "error_code": "ITEM_LOGIN_REQUIRED",
+				"error_message": "login required for request " + string(echoed), "request_id": "req-sync",
+			})
+		case "/link/token/create":
+			json.NewEncoder(w).Encode(map[string]any{
+				"link_token": "link-sandbox-1", "expiration": "2025-01-01T00:00:00Z", "request_id": "req-link",
+			})
+		default:
+			t.Errorf("unexpected call to %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	configuration := plaid.NewConfiguration()
+	configuration.Servers = plaid.ServerConfigurations{{URL: server.URL}}
+	client := &Client{apiClient: plaid.NewAPIClient(configuration)}
+	recorder := &eventRecorder{}
+	client.SetEventRecorder(recorder)
+	return client, recorder
+}
+
+func TestClientRecordsEvents(t *testing.T) {
+	client, recorder := newStubClient(t)
+	ctx := WithEventScope(context.Background(), 7, 9)
+
+	if err := client.RemoveItem(ctx, testAccessToken); err != nil {
+		t.Fatal(err)
+	}
+	cursor := "cursor-1"
+	if _, err := client.SyncTransactions(ctx, testAccessToken, &cursor); !IsLoginRequired(err) {
+		t.Fatalf("SyncTransactions = %v, want ITEM_LOGIN_REQUIRED", err)
+	}
+
+	if len(recorder.events) != 2 {
+		t.Fatalf("recorded %d events, want 2", len(recorder.events))
+	}
+	for _, event := range recorder.events {
+		if event.UserID == nil || *event.UserID != 7 || event.ItemID == nil || *event.ItemID != 9 {
+			t.Fatalf("%s: recorded for user %v item %v, want user 7 item 9", event.PlaidMethod, event.UserID, event.ItemID)
+		}
+	}
+
+	removed := recorder.events[0]
+	if removed.PlaidMethod != "itemRemove" || removed.RequestID == nil || *removed.RequestID != "req-remove" {
+		t.Fatalf("recorded %+v, want itemRemove with request ID req-remove", removed)
+	}
+	if removed.DurationMs == nil || *removed.DurationMs < 20 {
+		t.Fatalf("itemRemove duration = %v, want at least the stub's 20ms", removed.DurationMs)
+	}
+	if removed.ErrorType != nil || removed.ErrorCode != nil || removed.ErrorMessage != nil {
+		t.Fatalf("successful itemRemove recorded an error: %+v", removed)
+	}
+
+	synced := recorder.events[1]
+	if synced.PlaidMethod != "transactionsSync" || synced.RequestID == nil || *synced.RequestID != "req-sync" {
+		t.Fatalf("recorded %+v, want transactionsSync with request ID req-sync", synced)
+	}
+	if synced.ErrorType == nil || *synced.ErrorType != "ITEM_ERROR" || synced.ErrorCode == nil || *synced.ErrorCode != ErrorCodeItemLoginRequired {
+		t.Fatalf("transactionsSync error = %v %v, want ITEM_ERROR ITEM_LOGIN_REQUIRED", synced.ErrorType, synced.ErrorCode)
+	}
+	if synced.Arguments == nil || !strings.Contains(*synced.Arguments, "cursor-1") {
+		t.Fatalf("transactionsSync arguments = %v, want the cursor", synced.Arguments)
+	}
+	if synced.ErrorMessage == nil || strings.Contains(*synced.ErrorMessage, testAccessToken) {
+		t.Fatalf("transactionsSync error message = %v, want it recorded without the access token", synced.ErrorMessage)
+	}
+}
+
+// TestClientEventsHaveNoAccessTokens checks no recorded arguments hold an access token, even
+// one passed where none belongs
+func TestClientEventsHaveNoAccessTokens(t *testing.T) {
+	client, recorder := newStubClient(t)
+	ctx := context.Background()
+
+	if err := client.RemoveItem(ctx, testAccessToken); err != nil {
+		t.Fatal(err)
+	}
+	client.SyncTransactions(ctx, testAccessToken, nil)
+	_, err := client.CreateLinkToken(ctx, 7, []string{"transactions"}, []string{"US"}, "",
+		"https://example.com/webhooks?token="+testAccessToken, &LinkTokenUpdate{AccessToken: testAccessToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(recorder.events) != 3 {
+		t.Fatalf("recorded %d events, want 3", len(recorder.events))
+	}
+	for _, event := range recorder.events {
+		for _, field := range []*string{event.Arguments, event.ErrorMessage} {
+			if field != nil && strings.Contains(*field, "access-sandbox") {
+				t.Fatalf("%s event leaks an access token: %s", event.PlaidMethod, *field)
+			}
+		}
+	}
+	if linked := recorder.events[2]; linked.Arguments == nil || !strings.Contains(*linked.Arguments, `"update_mode":true`) {
+		t.Fatalf("linkTokenCreate arguments = %v, want them recorded", linked.Arguments)
+	}
+}
@@ -0,0 +1,377 @@
+package plaid
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+
+	plaid "github.com/plaid/plaid-go/v40/plaid"
+)
+
+// defaultFakePageSize matches the default page size of /transactions/sync
+const defaultFakePageSize = 100
+
+// FakeInstitutionID is the institution every fake item is linked to
+const FakeInstitutionID = "ins_fake"
+
+// Fake is an in-memory Aggregator for tests and offline development
+// Every public token exchanges into a new item seeded with the same accounts and
+// transaction updates, and sync pages are served deterministically from that seed.
+// Seeded account and transaction IDs are prefixed with the item ID, so several items
+// (for one user or many) never share Plaid IDs.
+type Fake struct {
+	// PageSize is how many updates each SyncTransactions page returns
+	PageSize int
+
+	mu      sync.Mutex
+	seed    FakeSeed
+	items   map[string]*FakeItem // keyed by access token
+	removed map[string]bool      // access tokens of removed items
+	nextID  int
+	failing map[string][]error // keyed by method name
+}
+
+// FakeSeed is the data copied into every item created by the Fake
+type FakeSeed struct {
+	Accounts []plaid.AccountBase
+	Added    []plaid.Transaction
+	Modified []plaid.Transaction
+	Removed  []plaid.RemovedTransaction
+}
+
+// FakeItem is one linked item served by the Fake
+type FakeItem struct {
+	ItemID        string
+	InstitutionID string
+	Accounts      []plaid.AccountBase
+	Added         []plaid.Transaction
+	Modified      []plaid.Transaction
+	Removed       []plaid.RemovedTransaction
+}
+
+// NewFake creates a Fake whose items have a checking and savings account and no transactions
+func NewFake() *Fake {
+	return &Fake{
+		PageSize: defaultFakePageSize,
+		seed: FakeSeed{
+			Accounts: []plaid.AccountBase{
+				fakeAccount("fake-checking", "Fake Checking", "0000", plaid.ACCOUNTTYPE_DEPOSITORY, plaid.ACCOUNTSUBTYPE_CHECKING),
+				fakeAccount("fake-savings", "Fake Savings", "1111", plaid.ACCOUNTTYPE_DEPOSITORY, plaid.ACCOUNTSUBTYPE_SAVINGS),
+			},
+		},
+		items:   map[string]*FakeItem{},
+		removed: map[string]bool{},
+		failing: map[string][]error{},
+	}
+}
+
+// Seed replaces the data copied into items created after this call
+func (f *Fake) Seed(seed FakeSeed) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.seed = seed
+}
+
+// SeedFromSyncSample seeds the Fake from a saved /transactions/sync response
+// (see transaction_sync_sample.json), with one depository account per account_id it references
+func (f *Fake) SeedFromSyncSample(r io.Reader) error {
+	var sample struct {
+		Added    []plaid.Transaction        `json:"added"`
+		Modified []plaid.Transaction        `json:"modified"`
+		Removed  []plaid.RemovedTransaction `json:"removed"`
+	}
+	if err := json.NewDecoder(r).Decode(&sample); err != nil {
+		return fmt.Errorf("failed to decode sync sample: %w", err)
+	}
+
+	seed := FakeSeed{
+		Added:    sample.Added,
+		Modified: sample.Modified,
+		Removed:  sample.Removed,
+	}
+
+	// build accounts in first-seen order so the seed is deterministic
+	seen := map[string]bool{}
+	for _, tx := range append(append([]plaid.Transaction{}, sample.Added...), sample.Modified...) {
+		accountID := tx.GetAccountId()
+		if seen[accountID] {
+			continue
+		}
+		seen[accountID] = true
+		n := len(seed.Accounts) + 1
+		seed.Accounts = append(seed.Accounts, fakeAccount(
+			accountID,
+			fmt.Sprintf("Fake Account %d", n),
+			fmt.Sprintf("%04d", n),
+			plaid.ACCOUNTTYPE_DEPOSITORY,
+			plaid.ACCOUNTSUBTYPE_CHECKING,
+		))
+	}
+
+	f.Seed(seed)
+	return nil
+}
+
+// SeedFromSyncSampleFile is SeedFromSyncSample reading from a file path
+func (f *Fake) SeedFromSyncSampleFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return f.SeedFromSyncSample(file)
+}
+
+// FakeAccessToken returns the access token of the nth item a Fake creates
+// The tokens are shaped like real sandbox tokens so redaction applies to them too
+func FakeAccessToken(n int) string {
+	return fmt.Sprintf("access-sandbox-00000000-0000-4000-8000-%012d", n)
+}
+
+// AddItem registers an item directly and returns its access token
+func (f *Fake) AddItem(item FakeItem) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.nextID++
+	accessToken := FakeAccessToken(f.nextID)
+	if item.ItemID == "" {
+		item.ItemID = fmt.Sprintf("item-fake-%d", f.nextID)
+	}
+	if item.InstitutionID == "" {
+		item.InstitutionID = FakeInstitutionID
+	}
+	f.items[accessToken] = &item
+
+	return accessToken
+}
+
+// Item returns the item behind accessToken, so tests can inspect or mutate it
+func (f *Fake) Item(accessToken string) (*FakeItem, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	item, ok := f.items[accessToken]
+	return item, ok
+}
+
+// FailNextSync makes the next SyncTransactions call return err
+// Calls queue up, so several failures can be scheduled in a row
+func (f *Fake) FailNextSync(err error) {
+	f.FailNext("SyncTransactions", err)
+}
+
+// FailNext makes the next call of the named method (e.g. "GetItem") return err
+// Calls queue up per method, so several failures can be scheduled in a row
+func (f *Fake) FailNext(method string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failing[method] = append(f.failing[method], err)
+}
+
+// nextFailure pops the error scheduled for the method's next call, or returns nil
+func (f *Fake) nextFailure(method string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	failures := f.failing[method]
+	if len(failures) == 0 {
+		return nil
+	}
+	f.failing[method] = failures[1:]
+	return failures[0]
+}
+
+// CreateLinkToken returns a fixed link token for the user
+// Update mode fails like Plaid does if the item is unknown
+func (f *Fake) CreateLinkToken(ctx context.Context, userID int, products []string, countryCodes []string, redirectURI string, webhookURL string, update *LinkTokenUpdate) (string, error) {
+	if update != nil {
+		if _, err := f.lookup(update.AccessToken); err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("link-fake-%d", userID), nil
+}
+
+// ExchangePublicToken creates a new item from the current seed
+// Any public token is accepted
+func (f *Fake) ExchangePublicToken(ctx context.Context, publicToken string) (string, string, error) {
+	accessToken := f.AddItem(FakeItem{})
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	item := f.items[accessToken]
+	prefix := item.ItemID + "-"
+
+	for _, account := range f.seed.Accounts {
+		account.SetAccountId(prefix + account.GetAccountId())
+		item.Accounts = append(item.Accounts, account)
+	}
+	item.Added = prefixTransactions(prefix, f.seed.Added)
+	item.Modified = prefixTransactions(prefix, f.seed.Modified)
+	for _, removed := range f.seed.Removed {
+		removed.SetTransactionId(prefix + removed.GetTransactionId())
+		removed.SetAccountId(prefix + removed.GetAccountId())
+		item.Removed = append(item.Removed, removed)
+	}
+
+	return accessToken, item.ItemID, nil
+}
+
+// prefixTransactions copies transactions, prefixing their transaction and account IDs
+func prefixTransactions(prefix string, transactions []plaid.Transaction) []plaid.Transaction {
+	var prefixed []plaid.Transaction
+	for _, tx := range transactions {
+		tx.SetTransactionId(prefix + tx.GetTransactionId())
+		tx.SetAccountId(prefix + tx.GetAccountId())
+		prefixed = append(prefixed, tx)
+	}
+	return prefixed
+}
+
+// GetAccounts returns the item's accounts
+func (f *Fake) GetAccounts(ctx context.Context, accessToken string) ([]plaid.AccountBase, error) {
+	if err := f.nextFailure("GetAccounts"); err != nil {
+		return nil, err
+	}
+
+	item, err := f.lookup(accessToken)
+	if err != nil {
+		return nil, err
+	}
+	return append([]plaid.AccountBase{}, item.Accounts...), nil
+}
+
+// GetAccountBalances returns the item's accounts, the fake has no cached balances to refresh
+func (f *Fake) GetAccountBalances(ctx context.Context, accessToken string) ([]plaid.AccountBase, error) {
+	return f.GetAccounts(ctx, accessToken)
+}
+
+// SyncTransactions serves the item's updates in pages of PageSize
+// Added transactions come first, then modified, then removed. The cursor is the
+// number of updates already served, so replaying a cursor replays the same page
+func (f *Fake) SyncTransactions(ctx context.Context, accessToken string, cursor *string) (SyncTransactionsResult, error) {
+	if err := f.nextFailure("SyncTransactions"); err != nil {
+		return SyncTransactionsResult{}, err
+	}
+
+	item, err := f.lookup(accessToken)
+	if err != nil {
+		return SyncTransactionsResult{}, err
+	}
+
+	offset := 0
+	if cursor != nil && *cursor != "" {
+		offset, err = strconv.Atoi(*cursor)
+		if err != nil || offset < 0 {
+			return SyncTransactionsResult{}, NewFakeError("INVALID_INPUT", "INVALID_FIELD", "invalid cursor")
+		}
+	}
+
+	pageSize := f.PageSize
+	if pageSize <= 0 {
+		pageSize = defaultFakePageSize
+	}
+
+	total := len(item.Added) + len(item.Modified) + len(item.Removed)
+	end := min(offset+pageSize, total)
+
+	result := SyncTransactionsResult{
+		Accounts:   append([]plaid.AccountBase{}, item.Accounts...),
+		NextCursor: strconv.Itoa(max(end, offset)),
+		HasMore:    end < total,
+	}
+	for i := offset; i < end; i++ {
+		switch {
+		case i < len(item.Added):
+			result.Added = append(result.Added, item.Added[i])
+		case i < len(item.Added)+len(item.Modified):
+			result.Modified = append(result.Modified, item.Modified[i-len(item.Added)])
+		default:
+			result.Removed = append(result.Removed, item.Removed[i-len(item.Added)-len(item.Modified)])
+		}
+	}
+
+	return result, nil
+}
+
+// GetItem returns the item's Plaid ID and institution
+func (f *Fake) GetItem(ctx context.Context, accessToken string) (plaid.ItemWithConsentFields, error) {
+	if err := f.nextFailure("GetItem"); err != nil {
+		return plaid.ItemWithConsentFields{}, err
+	}
+
+	item, err := f.lookup(accessToken)
+	if err != nil {
+		return plaid.ItemWithConsentFields{}, err
+	}
+
+	result := plaid.NewItemWithConsentFieldsWithDefaults()
+	result.SetItemId(item.ItemID)
+	result.SetInstitutionId(item.InstitutionID)
+	return *result, nil
+}
+
+// InstitutionsGetByID returns a placeholder institution named after its ID
+func (f *Fake) InstitutionsGetByID(ctx context.Context, institutionID string) (plaid.Institution, error) {
+	institution := plaid.NewInstitutionWithDefaults()
+	institution.SetInstitutionId(institutionID)
+	institution.SetName("Fake Bank (" + institutionID + ")")
+	return *institution, nil
+}
+
+// RemoveItem forgets the item, later calls with its access token fail with ITEM_NOT_FOUND
+// like they would against Plaid
+func (f *Fake) RemoveItem(ctx context.Context, accessToken string) error {
+	if _, err := f.lookup(accessToken); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.items, accessToken)
+	f.removed[accessToken] = true
+	return nil
+}
+
+func (f *Fake) lookup(accessToken string) (*FakeItem, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.removed[accessToken] {
+		return nil, NewFakeError("ITEM_ERROR", ErrorCodeItemNotFound, "the requested item was not found")
+	}
+	item, ok := f.items[accessToken]
+	if !ok {
+		return nil, NewFakeError("INVALID_INPUT", "INVALID_ACCESS_TOKEN", "provided access token is invalid")
+	}
+	return item, nil
+}
+
+// NewFakeError builds an error shaped exactly like one returned by the Plaid API
+// so code under test sees the same error_type and error_code it would in production
+func NewFakeError(errorType, errorCode, message string) error {
+	plaidErr := plaid.PlaidError{
+		ErrorType:    plaid.PlaidErrorType(errorType),
+		ErrorCode:    errorCode,
+		ErrorMessage: message,
+	}
+	body, _ := json.Marshal(plaidErr)
+	return plaid.MakeGenericOpenAPIError(body, "400 Bad Request", plaidErr)
+}
+
+func fakeAccount(id, name, mask string, accountType plaid.AccountType, subtype plaid.AccountSubtype) plaid.AccountBase {
+	account := plaid.NewAccountBaseWithDefaults()
+	account.SetAccountId(id)
+	account.SetName(name)
+	account.SetMask(mask)
+	account.SetType(accountType)
+	account.SetSubtype(subtype)
+	return *account
+}
@@ -0,0 +1,121 @@
+package plaid
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// fakeSyncSample is a minimal /transactions/sync response with two transactions on one account
+const fakeSyncSample = `{
+  "added": [
+    {"account_id": "acct-1", "transaction_id": "tx-1", "name": "Coffee", "amount": 4.5, "date": "2025-01-02", "iso_currency_code": "USD"},
+    {"account_id": "acct-1", "transaction_id": "tx-2", "name": "Groceries", "amount": 52.1, "date": "2025-01-03", "iso_currency_code": "USD"}
+  ],
+  "modified": [],
+  "removed": [{"account_id": "acct-1", "transaction_id": "tx-0"}]
+}`
+
+func newSeededFake(t *testing.T) *Fake {
+	t.Helper()
+
+	fake := NewFake()
+	if err := fake.SeedFromSyncSample(strings.NewReader(fakeSyncSample)); err != nil {
+		t.Fatal(err)
+	}
+	return fake
+}
+
+func TestFakeItemsDoNotShareIDs(t *testing.T) {
+	ctx := context.Background()
+	fake := newSeededFake(t)
+
+	seen := map[string]string{}
+	for range 2 {
+		accessToken, itemID, err := fake.ExchangePublicToken(ctx, "public-fake")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		accounts, err := fake.GetAccounts(ctx, accessToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		itemAccounts := map[string]bool{}
+		for _, account := range accounts {
+			id := account.GetAccountId()
+			if owner, ok := seen[id]; ok {
+				t.Fatalf("account %s of %s already belongs to %s", id, itemID, owner)
+			}
+			seen[id] = itemID
+			itemAccounts[id] = true
+		}
+
+		result, err := fake.SyncTransactions(ctx, accessToken, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Added) != 2 || len(result.Removed) != 1 {
+			t.Fatalf("got %d added and %d removed, want 2 and 1", len(result.Added), len(result.Removed))
+		}
+		for _, tx := range result.Added {
+			id := tx.GetTransactionId()
+			if owner, ok := seen[id]; ok {
+				t.Fatalf("transaction %s of %s already belongs to %s", id, itemID, owner)
+			}
+			seen[id] = itemID
+			if !itemAccounts[tx.GetAccountId()] {
+				t.Fatalf("transaction %s is on account %s, which isn't one of %s's", id, tx.GetAccountId(), itemID)
+			}
+		}
+		if removed := result.Removed[0]; !strings.HasPrefix(removed.GetTransactionId(), itemID+"-") {
+			t.Fatalf("removed transaction %s isn't prefixed with %s", removed.GetTransactionId(), itemID)
+		}
+	}
+}
+
+func TestFakeRemoveItem(t *testing.T) {
+	ctx := context.Background()
+	fake := newSeededFake(t)
+
+	accessToken, _, err := fake.ExchangePublicToken(ctx, "public-fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fake.RemoveItem(ctx, accessToken); err != nil {
+		t.Fatal(err)
+	}
+
+	// a removed item's token fails like it does against Plaid, removing it again included
+	if _, err := fake.GetAccounts(ctx, accessToken); ErrorCode(err) != ErrorCodeItemNotFound {
+		t.Fatalf("GetAccounts after remove = %v, want %s", err, ErrorCodeItemNotFound)
+	}
+	if err := fake.RemoveItem(ctx, accessToken); ErrorCode(err) != ErrorCodeItemNotFound {
+		t.Fatalf("RemoveItem after remove = %v, want %s", err, ErrorCodeItemNotFound)
+	}
+
+	if _, err := fake.GetAccounts(ctx, "access-fake-unknown"); ErrorCode(err) != "INVALID_ACCESS_TOKEN" {
+		t.Fatalf("GetAccounts with an unknown token = %v, want INVALID_ACCESS_TOKEN", err)
+	}
+}
+
+func TestFakeFailNext(t *testing.T) {
+	ctx := context.Background()
+	fake := newSeededFake(t)
+
+	accessToken, _, err := fake.ExchangePublicToken(ctx, "public-fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake.FailNext("GetItem", NewFakeError("API_ERROR", "INTERNAL_SERVER_ERROR", "an unexpected error occurred"))
+	if _, err := fake.GetAccounts(ctx, accessToken); err != nil {
+		t.Fatalf("GetAccounts = %v, want only GetItem to fail", err)
+	}
+	if _, err := fake.GetItem(ctx, accessToken); ErrorCode(err) != "INTERNAL_SERVER_ERROR" {
+		t.Fatalf("GetItem = %v, want INTERNAL_SERVER_ERROR", err)
+	}
+	if _, err := fake.GetItem(ctx, accessToken); err != nil {
+		t.Fatalf("second GetItem = %v, want only the next call to fail", err)
+	}
+}
@@ -0,0 +1,278 @@
+package plaid
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	plaid "github.com/plaid/plaid-go/v40/plaid"
+)
+
+// ErrInvalidWebhook is returned when a webhook fails signature verification
+var ErrInvalidWebhook = errors.New("invalid webhook")
+
+// maxWebhookAge is how old a webhook's issued-at time may be before it is rejected as a replay
+const maxWebhookAge = 5 * time.Minute
+
+// maxWebhookClockSkew is how far in the future a webhook's issued-at time may be, to allow for
+// clock drift between us and Plaid
+const maxWebhookClockSkew = time.Minute
+
+// KeyFetcher returns the public key Plaid used to sign a webhook
+// keyID is the "kid" from the Plaid-Verification JWT header
+type KeyFetcher interface {
+	FetchKey(ctx context.Context, keyID string) (*ecdsa.PublicKey, error)
+}
+
+// KeyFetcherFunc adapts a plain function to a KeyFetcher (useful for local test keys)
+type KeyFetcherFunc func(ctx context.Context, keyID string) (*ecdsa.PublicKey, error)
+
+// FetchKey calls f
+func (f KeyFetcherFunc) FetchKey(ctx context.Context, keyID string) (*ecdsa.PublicKey, error) {
+	return f(ctx, keyID)
+}
+
+// keyLookupInterval is the least time between Plaid lookups of key IDs that aren't cached
+// Webhooks are unauthenticated until verified, so this bounds the Plaid calls (and
+// plaid_api_events rows) made up key IDs can cause. Plaid rotates keys rarely.
+const keyLookupInterval = time.Second
+
+// failedKeyTTL is how long a key ID Plaid couldn't return fails again without another lookup
+const failedKeyTTL = 5 * time.Minute
+
+// errKeyLookupLimited is returned when a key ID isn't cached and a lookup ran too recently
+var errKeyLookupLimited = errors.New("too many webhook verification key lookups, try again later")
+
+// APIKeyFetcher fetches verification keys from /webhook_verification_key/get
+// Keys are cached by key ID since Plaid rotates them rarely. Lookups of uncached key IDs are
+// limited to one per keyLookupInterval and run one at a time, and a failed lookup is cached for
+// failedKeyTTL.
+type APIKeyFetcher struct {
+	client *Client
+	now    func() time.Time
+
+	lookupMu sync.Mutex // held for the whole of a Plaid lookup
+
+	mu         sync.Mutex
+	keys       map[string]plaid.JWKPublicKey
+	failed     map[string]time.Time // key ID -> when its lookup failed
+	lastLookup time.Time
+}
+
+// NewAPIKeyFetcher creates a KeyFetcher backed by the Plaid API
+func NewAPIKeyFetcher(client *Client) *APIKeyFetcher {
+	return &APIKeyFetcher{
+		client: client,
+		now:    time.Now,
+		keys:   map[string]plaid.JWKPublicKey{},
+		failed: map[string]time.Time{},
+	}
+}
+
+// FetchKey returns the cached key for keyID, fetching it from Plaid on a cache miss
+func (f *APIKeyFetcher) FetchKey(ctx context.Context, keyID string) (*ecdsa.PublicKey, error) {
+	if keyID == "" {
+		return nil, fmt.Errorf("missing webhook verification key id")
+	}
+
+	jwk, ok, err := f.cachedKey(keyID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		jwk, err = f.lookupKey(ctx, keyID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// expired keys must not be used to verify new webhooks
+	if expiredAt := jwk.ExpiredAt.Get(); expiredAt != nil && time.Unix(int64(*expiredAt), 0).Before(time.Now()) {
+		return nil, fmt.Errorf("webhook verification key %s has expired", keyID)
+	}
+
+	return parseJWK(jwk)
+}
+
+// cachedKey returns the cached key for keyID, or the cached failure of its lookup
+func (f *APIKeyFetcher) cachedKey(keyID string) (plaid.JWKPublicKey, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if jwk, ok := f.keys[keyID]; ok {
+		return jwk, true, nil
+	}
+	if failedAt, ok := f.failed[keyID]; ok && f.now().Sub(failedAt) < failedKeyTTL {
+		return plaid.JWKPublicKey{}, false, fmt.Errorf("webhook verification key %s lookup failed recently", keyID)
+	}
+	return plaid.JWKPublicKey{}, false, nil
+}
+
+// lookupKey fetches keyID from Plaid and caches the key or the failure
+// Lookups run one at a time, so webhooks arriving together with a new key ID share one lookup
+func (f *APIKeyFetcher) lookupKey(ctx context.Context, keyID string) (plaid.JWKPublicKey, error) {
+	f.lookupMu.Lock()
+	defer f.lookupMu.Unlock()
+
+	// another webhook may have looked the key up while this one waited
+	jwk, ok, err := f.cachedKey(keyID)
+	if err != nil || ok {
+		return jwk, err
+	}
+
+	f.mu.Lock()
+	now := f.now()
+	if now.Sub(f.lastLookup) < keyLookupInterval {
+		f.mu.Unlock()
+		return plaid.JWKPublicKey{}, errKeyLookupLimited
+	}
+	f.lastLookup = now
+	f.mu.Unlock()
+
+	request := plaid.NewWebhookVerificationKeyGetRequest(keyID)
+	start := time.Now()
+	resp, _, err := f.client.apiClient.PlaidApi.WebhookVerificationKeyGet(ctx).WebhookVerificationKeyGetRequest(*request).Execute()
+	f.client.record(ctx, "webhookVerificationKeyGet", map[string]any{"key_id": keyID}, resp.GetRequestId(), start, err)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err != nil {
+		// forget failures that have expired, lookups are rate limited so this stays small
+		for id, failedAt := range f.failed {
+			if now.Sub(failedAt) >= failedKeyTTL {
+				delete(f.failed, id)
+			}
+		}
+		f.failed[keyID] = now
+		return plaid.JWKPublicKey{}, fmt.Errorf("failed to get webhook verification key: %w", wrapError(err))
+	}
+
+	jwk = resp.GetKey()
+	f.keys[keyID] = jwk
+	return jwk, nil
+}
+
+// parseJWK converts a Plaid JWK into an ECDSA P-256 public key
+func parseJWK(jwk plaid.JWKPublicKey) (*ecdsa.PublicKey, error) {
+	if jwk.Kty != "EC" || jwk.Crv != "P-256" {
+		return nil, fmt.Errorf("unsupported key type %s/%s", jwk.Kty, jwk.Crv)
+	}
+
+	x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key x coordinate: %w", err)
+	}
+	y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key y coordinate: %w", err)
+	}
+	if len(x) > 32 || len(y) > 32 {
+		return nil, fmt.Errorf("invalid P-256 key coordinates")
+	}
+
+	// uncompressed point encoding: 0x04 || X || Y, each coordinate padded to 32 bytes
+	point := make([]byte, 65)
+	point[0] = 4
+	copy(point[33-len(x):33], x)
+	copy(point[65-len(y):], y)
+
+	return ecdsa.ParseUncompressedPublicKey(elliptic.P256(), point)
+}
+
+// WebhookVerifier checks the Plaid-Verification header sent with every webhook
+// See https://plaid.com/docs/api/webhooks/webhook-verification/
+type WebhookVerifier struct {
+	keys KeyFetcher
+	now  func() time.Time
+}
+
+// NewWebhookVerifier creates a verifier that resolves signing keys through keys
+func NewWebhookVerifier(keys KeyFetcher) *WebhookVerifier {
+	return &WebhookVerifier{keys: keys, now: time.Now}
+}
+
+type webhookJWTHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type webhookJWTClaims struct {
+	IssuedAt          int64  `json:"iat"`
+	RequestBodySHA256 string `json:"request_body_sha256"`
+}
+
+// Verify validates the signed JWT from the Plaid-Verification header against the raw request body
+// Returns an error wrapping ErrInvalidWebhook if the webhook should be rejected
+func (v *WebhookVerifier) Verify(ctx context.Context, token string, body []byte) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("%w: malformed token", ErrInvalidWebhook)
+	}
+
+	var header webhookJWTHeader
+	if err := decodeJWTSegment(parts[0], &header); err != nil {
+		return fmt.Errorf("%w: bad header: %v", ErrInvalidWebhook, err)
+	}
+	// Plaid only signs with ES256, anything else (including "none") is rejected
+	if header.Alg != "ES256" {
+		return fmt.Errorf("%w: unexpected alg %q", ErrInvalidWebhook, header.Alg)
+	}
+
+	// the claims aren't verified yet, but a stale token is rejected without fetching a key
+	var claims webhookJWTClaims
+	if err := decodeJWTSegment(parts[1], &claims); err != nil {
+		return fmt.Errorf("%w: bad claims: %v", ErrInvalidWebhook, err)
+	}
+
+	age := v.now().Sub(time.Unix(claims.IssuedAt, 0))
+	if age > maxWebhookAge {
+		return fmt.Errorf("%w: token too old", ErrInvalidWebhook)
+	}
+	if age < -maxWebhookClockSkew {
+		return fmt.Errorf("%w: token issued in the future", ErrInvalidWebhook)
+	}
+
+	key, err := v.keys.FetchKey(ctx, header.Kid)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidWebhook, err)
+	}
+
+	// ES256 signatures are the raw 32-byte r and s values concatenated
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(sig) != 64 {
+		return fmt.Errorf("%w: bad signature encoding", ErrInvalidWebhook)
+	}
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:])
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if !ecdsa.Verify(key, digest[:], r, s) {
+		return fmt.Errorf("%w: signature mismatch", ErrInvalidWebhook)
+	}
+
+	bodyHash := sha256.Sum256(body)
+	if subtle.ConstantTimeCompare([]byte(hex.EncodeToString(bodyHash[:])), []byte(claims.RequestBodySHA256)) != 1 {
+		return fmt.Errorf("%w: body hash mismatch", ErrInvalidWebhook)
+	}
+
+	return nil
+}
+
+func decodeJWTSegment(segment string, v interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
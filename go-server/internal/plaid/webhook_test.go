@@ -0,0 +1,189 @@
+package plaid
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	plaid "github.com/plaid/plaid-go/v40/plaid"
+)
+
+const testKeyID = "test-key"
+
+// signWebhook builds a Plaid-Verification JWT for body, signed with key and issued at iat
+func signWebhook(t *testing.T, key *ecdsa.PrivateKey, alg string, iat time.Time, body []byte) string {
+	t.Helper()
+
+	bodyHash := sha256.Sum256(body)
+	header, err := json.Marshal(webhookJWTHeader{Alg: alg, Kid: testKeyID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := json.Marshal(webhookJWTClaims{IssuedAt: iat.Unix(), RequestBodySHA256: hex.EncodeToString(bodyHash[:])})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestWebhookVerifierVerify(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Unix(1_700_000_000, 0)
+	fetched := false
+	verifier := NewWebhookVerifier(KeyFetcherFunc(func(ctx context.Context, keyID string) (*ecdsa.PublicKey, error) {
+		fetched = true
+		if keyID != testKeyID {
+			return nil, errors.New("unknown key")
+		}
+		return &key.PublicKey, nil
+	}))
+	verifier.now = func() time.Time { return now }
+
+	body := []byte(`{"webhook_type":"TRANSACTIONS","webhook_code":"SYNC_UPDATES_AVAILABLE","item_id":"item-1"}`)
+
+	// fetches is whether the key is fetched, a token that's stale or not ES256 never gets that far
+	tests := []struct {
+		name    string
+		token   string
+		body    []byte
+		valid   bool
+		fetches bool
+	}{
+		{"valid", signWebhook(t, key, "ES256", now, body), body, true, true},
+		{"slightly in the future", signWebhook(t, key, "ES256", now.Add(30*time.Second), body), body, true, true},
+		{"expired", signWebhook(t, key, "ES256", now.Add(-maxWebhookAge-time.Second), body), body, false, false},
+		{"issued in the future", signWebhook(t, key, "ES256", now.Add(time.Hour), body), body, false, false},
+		{"body hash mismatch", signWebhook(t, key, "ES256", now, body), []byte(`{"webhook_type":"ITEM"}`), false, true},
+		{"wrong key", signWebhook(t, otherKey, "ES256", now, body), body, false, true},
+		{"alg none", signWebhook(t, key, "none", now, body), body, false, false},
+		{"malformed", "not-a-jwt", body, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetched = false
+			err := verifier.Verify(context.Background(), tt.token, tt.body)
+			if fetched != tt.fetches {
+				t.Fatalf("fetched the key = %v, want %v", fetched, tt.fetches)
+			}
+			if tt.valid && err != nil {
+				t.Fatalf("Verify() = %v, want nil", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidWebhook) {
+				t.Fatalf("Verify() = %v, want ErrInvalidWebhook", err)
+			}
+		})
+	}
+}
+
+// TestAPIKeyFetcherLimitsLookups serves /webhook_verification_key/get, which only knows testKeyID,
+// and checks made up key IDs are rate limited and their failures cached
+func TestAPIKeyFetcherLimitsLookups(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	point, err := key.PublicKey.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var lookups []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			KeyID string `json:"key_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("bad request body: %v", err)
+		}
+		lookups = append(lookups, req.KeyID)
+
+		w.Header().Set("Content-Type", "application/json")
+		if req.KeyID != testKeyID {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]any{
+				"error_type": "INVALID_INPUT", "error_code": "INVALID_WEBHOOK_VERIFICATION_KEY_ID",
+				"error_message": "invalid key_id provided", "request_id": "req-1",
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"key": map[string]any{
+				"alg": "ES256", "crv": "P-256", "kid": testKeyID, "kty": "EC", "use": "sig",
+				"x":          base64.RawURLEncoding.EncodeToString(point[1:33]),
+				"y":          base64.RawURLEncoding.EncodeToString(point[33:]),
+				"created_at": 1_560_466_143, "expired_at": nil,
+			},
+			"request_id": "req-2",
+		})
+	}))
+	defer server.Close()
+
+	configuration := plaid.NewConfiguration()
+	configuration.Servers = plaid.ServerConfigurations{{URL: server.URL}}
+	fetcher := NewAPIKeyFetcher(&Client{apiClient: plaid.NewAPIClient(configuration)})
+	now := time.Unix(1_700_000_000, 0)
+	fetcher.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	steps := []struct {
+		name    string
+		after   time.Duration
+		keyID   string
+		valid   bool
+		lookups int
+	}{
+		{"known key", 0, testKeyID, true, 1},
+		{"known key cached", 0, testKeyID, true, 1},
+		{"made up key too soon after a lookup", 0, "made-up", false, 1},
+		{"made up key", keyLookupInterval, "made-up", false, 2},
+		{"made up key failure cached", keyLookupInterval, "made-up", false, 2},
+		{"another made up key", 0, "made-up-2", false, 3},
+		{"made up key failure expired", failedKeyTTL, "made-up", false, 4},
+		{"known key still cached", 0, testKeyID, true, 4},
+		{"no key id", keyLookupInterval, "", false, 4},
+	}
+
+	for _, step := range steps {
+		now = now.Add(step.after)
+		got, err := fetcher.FetchKey(ctx, step.keyID)
+		if step.valid && (err != nil || !got.Equal(&key.PublicKey)) {
+			t.Fatalf("%s: FetchKey = %v, %v, want the test key", step.name, got, err)
+		}
+		if !step.valid && err == nil {
+			t.Fatalf("%s: FetchKey succeeded", step.name)
+		}
+		if len(lookups) != step.lookups {
+			t.Fatalf("%s: %d lookups so far (%q), want %d", step.name, len(lookups), lookups, step.lookups)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package redact
+
+import (
+	"io"
+	"regexp"
+)
+
+// Placeholder replaces every redacted secret
+const Placeholder = "[REDACTED]"
+
+var (
+	// Plaid access and public tokens look like access-sandbox-8ab976e6-64bc-4b38-98f7-731e7a349970
+	// Anchored to the environment and the UUID so words like public-facing or access-control survive
+	plaidTokenPattern = regexp.MustCompile(`\b(access|public)-(?:sandbox|development|production)-[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`)
+
+	// secret fields in JSON request bodies echoed back by Plaid or our own errors
+	jsonSecretPattern = regexp.MustCompile(`"(access_token|public_token|secret|client_id|password)"\s*:\s*"[^"]*"`)
+)
+
+// String removes Plaid tokens and secret JSON fields from s
+func String(s string) string {
+	s = plaidTokenPattern.ReplaceAllString(s, "$1-"+Placeholder)
+	s = jsonSecretPattern.ReplaceAllString(s, `"$1":"`+Placeholder+`"`)
+	return s
+}
+
+// Error returns err's message with secrets removed, safe to put in a response or log line
+func Error(err error) string {
+	if err == nil {
+		return ""
+	}
+	return String(err.Error())
+}
+
+// writer redacts everything written through it
+type writer struct {
+	w io.Writer
+}
+
+// NewWriter wraps w so every write is redacted first
+// Loggers write whole lines, so a token is never split across two writes
+func NewWriter(w io.Writer) io.Writer {
+	return writer{w: w}
+}
+
+// Write redacts p and writes it to the wrapped writer
+// Reports len(p) on success since callers only care that all of p was handled
+func (r writer) Write(p []byte) (int, error) {
+	if _, err := r.w.Write([]byte(String(string(p)))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
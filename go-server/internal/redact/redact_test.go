@@ -0,0 +1,67 @@
+package redact
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestWriterRedactsLogLines(t *testing.T) {
+	const accessToken = "access-sandbox-8ab976e6-64bc-4b38-98f7-731e7a349970"
+
+	var out bytes.Buffer
+	logger := log.New(NewWriter(&out), "", 0)
+
+	// a token in the message and a Plaid error echoing the request body, as INVALID_REQUEST errors do
+	logger.Printf("sync failed for token %s", accessToken)
+	plaidErr := errors.New(`INVALID_FIELD: request body {"client_id": "abc123", "secret": "s3cr3t", "access_token": "` + accessToken + `", "public_token":"public-sandbox-5c224a01-8314-4491-a06f-39e193d5cddc"}`)
+	logger.Printf("plaid call failed: %v", plaidErr)
+
+	logged := out.String()
+	for _, secret := range []string{accessToken, "abc123", "s3cr3t", "5c224a01-8314-4491-a06f-39e193d5cddc"} {
+		if strings.Contains(logged, secret) {
+			t.Fatalf("log output leaks %q: %s", secret, logged)
+		}
+	}
+	if strings.Contains(strings.ReplaceAll(logged, "access-"+Placeholder, ""), "access-") {
+		t.Fatalf("log output leaks an access token: %s", logged)
+	}
+	if got := strings.Count(logged, "\n"); got != 2 {
+		t.Fatalf("got %d log lines, want 2: %s", got, logged)
+	}
+}
+
+func TestError(t *testing.T) {
+	if got := Error(nil); got != "" {
+		t.Fatalf("Error(nil) = %q, want empty", got)
+	}
+
+	got := Error(errors.New("bad token access-production-0a6b3c9d-1e2f-4a5b-8c7d-9e0f1a2b3c4d"))
+	if want := "bad token access-" + Placeholder; got != want {
+		t.Fatalf("Error() = %q, want %q", got, want)
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"access-sandbox-8ab976e6-64bc-4b38-98f7-731e7a349970", "access-" + Placeholder},
+		{"token=public-development-8ab976e6-64bc-4b38-98f7-731e7a349970.", "token=public-" + Placeholder + "."},
+		{"(access-production-8ab976e6-64bc-4b38-98f7-731e7a349970)", "(access-" + Placeholder + ")"},
+		// ordinary words with the same prefixes are left alone
+		{"the public-facing-api and its access-control-list", "the public-facing-api and its access-control-list"},
+		{"access-sandbox-not-a-token", "access-sandbox-not-a-token"},
+		{"public-staging-8ab976e6-64bc-4b38-98f7-731e7a349970", "public-staging-8ab976e6-64bc-4b38-98f7-731e7a349970"},
+		{"preaccess-sandbox-8ab976e6-64bc-4b38-98f7-731e7a349970", "preaccess-sandbox-8ab976e6-64bc-4b38-98f7-731e7a349970"},
+	}
+
+	for _, tt := range tests {
+		if got := String(tt.in); got != tt.want {
+			t.Errorf("String(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// keySize is the AES-256 key length in bytes
+const keySize = 32
+
+// envelopePrefix versions the ciphertext format so it can change later
+const envelopePrefix = "v1"
+
+// ErrUnknownKey is returned when a ciphertext was sealed with a key that isn't loaded
+var ErrUnknownKey = errors.New("unknown encryption key")
+
+// Keyring holds the master keys used for envelope encryption
+// Every secret is sealed with its own random data key, and the data key is sealed with
+// the current master key. Older master keys stay loaded so existing secrets can still
+// be opened until they are rotated.
+type Keyring struct {
+	currentID string
+	keys      map[string][]byte
+}
+
+// NewKeyring creates a keyring that encrypts with keys[currentID]
+func NewKeyring(currentID string, keys map[string][]byte) (*Keyring, error) {
+	for id, key := range keys {
+		if id == "" || strings.ContainsAny(id, ":,.\n") {
+			return nil, fmt.Errorf("invalid key id %q", id)
+		}
+		if len(key) != keySize {
+			return nil, fmt.Errorf("key %s must be %d bytes, got %d", id, keySize, len(key))
+		}
+	}
+	if _, ok := keys[currentID]; !ok {
+		return nil, fmt.Errorf("current key %q is not in the keyring", currentID)
+	}
+
+	return &Keyring{currentID: currentID, keys: keys}, nil
+}
+
+// ParseKeyring parses keys written as "id:base64key" pairs separated by commas or newlines
+// currentID picks the encryption key, if empty the last key listed is used. Each ID may only be
+// listed once, a second key under the same ID would make secrets sealed with the first unreadable.
+func ParseKeyring(spec, currentID string) (*Keyring, error) {
+	keys := map[string][]byte{}
+	lastID := ""
+
+	for _, entry := range strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == '\n' }) {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, fmt.Errorf("key entry must look like id:base64key")
+		}
+
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("key %s is not valid base64: %w", id, err)
+		}
+
+		id = strings.TrimSpace(id)
+		if _, ok := keys[id]; ok {
+			return nil, fmt.Errorf("key %s is listed more than once", id)
+		}
+		keys[id] = key
+		lastID = id
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no encryption keys configured")
+	}
+	if currentID == "" {
+		currentID = lastID
+	}
+
+	return NewKeyring(currentID, keys)
+}
+
+// LoadKeyringFile reads a keyring from a file in the ParseKeyring format
+func LoadKeyringFile(path, currentID string) (*Keyring, error) {
+	spec, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	return ParseKeyring(string(spec), currentID)
+}
+
+// CurrentKeyID returns the ID of the key new secrets are sealed with
+func (k *Keyring) CurrentKeyID() string {
+	return k.currentID
+}
+
+// Encrypt seals plaintext with a fresh data key wrapped by the current master key
+// Returns the ciphertext and the ID of the master key, both must be stored
+func (k *Keyring) Encrypt(plaintext string) (string, string, error) {
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", "", fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	sealed, err := seal(dataKey, []byte(plaintext), nil)
+	if err != nil {
+		return "", "", err
+	}
+
+	// bind the wrapped data key to its master key ID so it can't be swapped between keys
+	wrappedKey, err := seal(k.keys[k.currentID], dataKey, []byte(k.currentID))
+	if err != nil {
+		return "", "", err
+	}
+
+	ciphertext := strings.Join([]string{
+		envelopePrefix,
+		base64.RawStdEncoding.EncodeToString(wrappedKey),
+		base64.RawStdEncoding.EncodeToString(sealed),
+	}, ".")
+
+	return ciphertext, k.currentID, nil
+}
+
+// Decrypt opens a ciphertext produced by Encrypt with the master key keyID
+func (k *Keyring) Decrypt(ciphertext, keyID string) (string, error) {
+	masterKey, ok := k.keys[keyID]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+	}
+
+	parts := strings.Split(ciphertext, ".")
+	if len(parts) != 3 || parts[0] != envelopePrefix {
+		return "", fmt.Errorf("malformed ciphertext")
+	}
+
+	wrappedKey, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("malformed ciphertext: %w", err)
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("malformed ciphertext: %w", err)
+	}
+
+	dataKey, err := open(masterKey, wrappedKey, []byte(keyID))
+	if err != nil {
+		return "", fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+
+	plaintext, err := open(dataKey, sealed, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt: %w", err)
+	}
+
+	return string(plaintext), nil
+}
+
+// seal encrypts with AES-GCM, returning nonce || ciphertext
+func seal(key, plaintext, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// open reverses seal
+func open(key, sealed, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, additionalData)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,186 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// testKey returns a valid base64 key filled with b
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, keySize))
+}
+
+func mustParseKeyring(t *testing.T, spec, currentID string) *Keyring {
+	t.Helper()
+
+	keyring, err := ParseKeyring(spec, currentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keyring
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	keyring := mustParseKeyring(t, "k1:"+testKey(1), "")
+	const secret = "access-sandbox-de3ce8ef-33f8-452c-a685-8671031fc0f6"
+
+	ciphertext, keyID, err := keyring.Encrypt(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyID != "k1" {
+		t.Fatalf("sealed with key %q, want k1", keyID)
+	}
+	if strings.Contains(ciphertext, secret) {
+		t.Fatalf("ciphertext holds the plaintext: %s", ciphertext)
+	}
+
+	again, _, err := keyring.Encrypt(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again == ciphertext {
+		t.Fatalf("sealing the same secret twice gave the same ciphertext")
+	}
+
+	plaintext, err := keyring.Decrypt(ciphertext, keyID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plaintext != secret {
+		t.Fatalf("decrypted %q, want %q", plaintext, secret)
+	}
+}
+
+func TestDecryptUnknownKey(t *testing.T) {
+	keyring := mustParseKeyring(t, "k1:"+testKey(1), "")
+	ciphertext, _, err := keyring.Encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := keyring.Decrypt(ciphertext, "k2"); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("Decrypt with an unknown key = %v, want ErrUnknownKey", err)
+	}
+}
+
+// TestDecryptTampered flips one byte at a time in each part of the envelope
+// AES-GCM authenticates the nonce, the wrapped data key and the ciphertext, so every change fails
+func TestDecryptTampered(t *testing.T) {
+	keyring := mustParseKeyring(t, "k1:"+testKey(1), "")
+	ciphertext, keyID, err := keyring.Encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(ciphertext, ".")
+
+	for part := 1; part <= 2; part++ {
+		raw, err := base64.RawStdEncoding.DecodeString(parts[part])
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the first 12 bytes are the nonce, the rest the sealed bytes and the GCM tag
+		for _, i := range []int{0, 11, 12, len(raw) - 1} {
+			tampered := bytes.Clone(raw)
+			tampered[i] ^= 1
+
+			altered := append([]string{}, parts...)
+			altered[part] = base64.RawStdEncoding.EncodeToString(tampered)
+			if _, err := keyring.Decrypt(strings.Join(altered, "."), keyID); err == nil {
+				t.Errorf("part %d with byte %d flipped decrypted", part, i)
+			}
+		}
+	}
+
+	for _, malformed := range []string{"", "v1", "v2." + parts[1] + "." + parts[2], parts[0] + ".!!." + parts[2], parts[0] + "." + parts[1] + ".AA"} {
+		if _, err := keyring.Decrypt(malformed, keyID); err == nil {
+			t.Errorf("Decrypt(%q) succeeded", malformed)
+		}
+	}
+}
+
+// TestDecryptWrongKeyID checks a wrapped data key is bound to its key ID
+// Two keyrings loading the same key bytes under different IDs can't open each other's secrets
+func TestDecryptWrongKeyID(t *testing.T) {
+	sealer := mustParseKeyring(t, "k1:"+testKey(1), "")
+	opener := mustParseKeyring(t, "k2:"+testKey(1), "")
+
+	ciphertext, _, err := sealer.Encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := opener.Decrypt(ciphertext, "k2"); err == nil {
+		t.Fatalf("secret sealed under k1 opened under k2")
+	}
+}
+
+func TestDecryptAfterRotation(t *testing.T) {
+	old := mustParseKeyring(t, "k1:"+testKey(1), "")
+	ciphertext, oldKeyID, err := old.Encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := mustParseKeyring(t, "k1:"+testKey(1)+"\nk2:"+testKey(2), "")
+	if rotated.CurrentKeyID() != "k2" {
+		t.Fatalf("current key is %q, want the last listed, k2", rotated.CurrentKeyID())
+	}
+
+	plaintext, err := rotated.Decrypt(ciphertext, oldKeyID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plaintext != "secret" {
+		t.Fatalf("decrypted %q, want secret", plaintext)
+	}
+
+	resealed, newKeyID, err := rotated.Encrypt(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newKeyID != "k2" {
+		t.Fatalf("resealed with key %q, want k2", newKeyID)
+	}
+	if _, err := old.Decrypt(resealed, newKeyID); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("old keyring opening a k2 secret = %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestParseKeyring(t *testing.T) {
+	keyring := mustParseKeyring(t, " # rotated 2025-01\n k1 : "+testKey(1)+" ,k2:"+testKey(2)+"\n\n", "k1")
+	if keyring.CurrentKeyID() != "k1" {
+		t.Fatalf("current key is %q, want k1", keyring.CurrentKeyID())
+	}
+	if len(keyring.keys) != 2 {
+		t.Fatalf("loaded %d keys, want 2", len(keyring.keys))
+	}
+}
+
+func TestParseKeyringErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		spec      string
+		currentID string
+	}{
+		{"empty", "", ""},
+		{"only comments", "# no keys yet\n", ""},
+		{"missing id separator", testKey(1), ""},
+		{"bad base64", "k1:not base64!", ""},
+		{"short key", "k1:" + base64.StdEncoding.EncodeToString(make([]byte, 16)), ""},
+		{"long key", "k1:" + base64.StdEncoding.EncodeToString(make([]byte, 33)), ""},
+		{"empty id", ":" + testKey(1), ""},
+		{"invalid id", "k.1:" + testKey(1), ""},
+		{"duplicate id", "k1:" + testKey(1) + ",k1:" + testKey(2), ""},
+		{"duplicate id after trimming", "k1:" + testKey(1) + "\n k1 :" + testKey(1), ""},
+		{"unknown current key", "k1:" + testKey(1), "k2"},
+	}
+
+	for _, tt := range tests {
+		if _, err := ParseKeyring(tt.spec, tt.currentID); err == nil {
+			t.Errorf("%s: ParseKeyring succeeded", tt.name)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"compound/go-server/internal/db"
+	"compound/go-server/pkg/models"
+	"context"
+	"fmt"
+
+	plaid "github.com/plaid/plaid-go/v40/plaid"
+)
+
+// StoreAccount creates or updates an account fetched from Plaid, balances included, and records
+// its balances as today's snapshot
+// Everything that fetches accounts (linking, relinking, balance refreshes and transaction syncs)
+// stores them through here, so balances are as fresh as the last fetch
+func StoreAccount(ctx context.Context, tx *db.Store, itemID int, account plaid.AccountBase) (*models.Account, error) {
+	plaidBalances := account.GetBalances()
+	balances := db.AccountBalances{
+		Current:                models.MoneyFromFloatPtr(plaidBalances.Current.Get()),
+		Available:              models.MoneyFromFloatPtr(plaidBalances.Available.Get()),
+		IsoCurrencyCode:        plaidBalances.IsoCurrencyCode.Get(),
+		UnofficialCurrencyCode: plaidBalances.UnofficialCurrencyCode.Get(),
+	}
+
+	dbAccount, err := tx.CreateOrUpdateAccount(
+		ctx,
+		itemID,
+		account.GetAccountId(),
+		account.GetName(),
+		account.GetMask(),
+		account.OfficialName.Get(),
+		string(account.GetType()),
+		string(account.GetSubtype()),
+		balances,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store account: %w", err)
+	}
+
+	if err := tx.RecordAccountBalanceSnapshot(ctx, dbAccount.ID, db.Today(), balances); err != nil {
+		return nil, fmt.Errorf("failed to record balance snapshot: %w", err)
+	}
+
+	return dbAccount, nil
+}
@@ -0,0 +1,44 @@
+package services
+
+import (
+	"compound/go-server/internal/db"
+	plaidpkg "compound/go-server/internal/plaid"
+	"compound/go-server/internal/redact"
+	"compound/go-server/pkg/models"
+	"context"
+	"log"
+)
+
+// CompleteItemRemoval removes a pending removal's item from Plaid and marks the removal complete
+// Plaid reporting the item already gone completes it too. On any other error the removal stays
+// pending for RetryPendingItemRemovals.
+func CompleteItemRemoval(ctx context.Context, store *db.Store, aggregator plaidpkg.Aggregator, removal *models.ItemRemoval) error {
+	err := aggregator.RemoveItem(plaidpkg.WithEventScope(ctx, removal.UserID, removal.ItemID), removal.PlaidAccessToken)
+	alreadyRemoved := plaidpkg.ErrorCode(err) == plaidpkg.ErrorCodeItemNotFound
+	if err != nil && !alreadyRemoved {
+		return err
+	}
+
+	return store.CompleteItemRemoval(ctx, removal.ID, alreadyRemoved)
+}
+
+// RetryPendingItemRemovals retries every removal still waiting on Plaid
+// Returns how many were completed, failures are logged and left for the next retry
+func RetryPendingItemRemovals(ctx context.Context, store *db.Store, aggregator plaidpkg.Aggregator) int {
+	removals, err := store.GetPendingItemRemovals(ctx)
+	if err != nil {
+		log.Printf("item removals: failed to list pending removals: %s", redact.Error(err))
+		return 0
+	}
+
+	completed := 0
+	for _, removal := range removals {
+		if err := CompleteItemRemoval(ctx, store, aggregator, removal); err != nil {
+			log.Printf("item removals: item %d: failed to remove from Plaid: %s", removal.ItemID, redact.Error(err))
+			continue
+		}
+		completed++
+	}
+
+	return completed
+}
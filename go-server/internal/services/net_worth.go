@@ -0,0 +1,283 @@
+package services
+
+import (
+	"compound/go-server/internal/db"
+	"compound/go-server/pkg/models"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DefaultNetWorthCurrency is the currency net worth is reported in when none is configured
+const DefaultNetWorthCurrency = "USD"
+
+// Net worth series intervals
+const (
+	NetWorthIntervalDaily   = "daily"
+	NetWorthIntervalMonthly = "monthly"
+)
+
+// assetAccountTypes and liabilityAccountTypes are the Plaid account types counted towards net worth
+// Other types (e.g. "other") are left out, there's no telling which side they belong on
+var (
+	assetAccountTypes     = []string{"depository", "investment", "brokerage"}
+	liabilityAccountTypes = []string{"credit", "loan"}
+)
+
+// NetWorthConfig sets the currency net worth is reported in and how to convert to it
+type NetWorthConfig struct {
+	// Currency is the ISO currency code totals are converted to, defaults to DefaultNetWorthCurrency
+	Currency string
+	// Rates maps an ISO currency code to the value of one unit of it in Currency
+	Rates map[string]models.Money
+}
+
+// NetWorth is a user's assets and liabilities converted to one currency
+type NetWorth struct {
+	Currency      string
+	AccountAssets models.Money
+	ManualAssets  models.Money
+	Liabilities   models.Money
+	// UnconvertedCurrencies lists the currencies of balances left out for lack of an exchange rate
+	UnconvertedCurrencies []string
+	Series                []NetWorthPoint
+}
+
+// Assets is the sum of account and manual assets
+func (n *NetWorth) Assets() models.Money {
+	return n.AccountAssets.Add(n.ManualAssets)
+}
+
+// Total is assets minus liabilities
+func (n *NetWorth) Total() models.Money {
+	return n.Assets().Sub(n.Liabilities)
+}
+
+// NetWorthPoint is net worth at the end of one day of a series
+type NetWorthPoint struct {
+	Date        time.Time
+	Assets      models.Money
+	Liabilities models.Money
+}
+
+// NetWorthCalculator computes users' net worth from account balances and manual assets
+type NetWorthCalculator struct {
+	store    *db.Store
+	currency string
+	rates    map[string]models.Money
+}
+
+// NewNetWorthCalculator creates a NetWorthCalculator reading from store
+func NewNetWorthCalculator(store *db.Store, cfg NetWorthConfig) *NetWorthCalculator {
+	if cfg.Currency == "" {
+		cfg.Currency = DefaultNetWorthCurrency
+	}
+	return &NetWorthCalculator{store: store, currency: strings.ToUpper(cfg.Currency), rates: cfg.Rates}
+}
+
+// ParseExchangeRates parses rates written as "EUR=1.08,GBP=1.27"
+// Each rate is the value of one unit of the currency in the net worth currency
+func ParseExchangeRates(s string) (map[string]models.Money, error) {
+	rates := make(map[string]models.Money)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		code, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid exchange rate %q, expected CODE=rate", pair)
+		}
+		rate, err := models.ParseMoney(strings.TrimSpace(value))
+		if err != nil || rate.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid exchange rate %q, rate must be a positive number", pair)
+		}
+		rates[strings.ToUpper(strings.TrimSpace(code))] = rate
+	}
+	return rates, nil
+}
+
+// Calculate computes a user's current net worth and a series of the given number of days or
+// months, ending today
+// Current totals use the balances of open accounts from their last fetch, the series uses the
+// daily balance snapshots and manual asset value histories, carrying an account's last balance or an
+// asset's last value forward over days nothing was recorded.
+func (n *NetWorthCalculator) Calculate(ctx context.Context, userID int, interval string, periods int) (*NetWorth, error) {
+	accounts, err := n.store.GetAccountsByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get accounts: %w", err)
+	}
+	assets, err := n.store.GetAssetsByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get assets: %w", err)
+	}
+
+	dates := seriesDates(time.Now().UTC(), interval, periods)
+	snapshots, err := n.store.GetBalanceSnapshotsByUserID(ctx, userID, dates[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to get balance snapshots: %w", err)
+	}
+	assetValues, err := n.store.GetAssetValuesByUserID(ctx, userID, dates[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to get asset values: %w", err)
+	}
+
+	result := &NetWorth{Currency: n.currency}
+	unconverted := make(map[string]bool)
+
+	accountsByID := make(map[int]*models.Account, len(accounts))
+	for _, account := range accounts {
+		accountsByID[account.ID] = account
+		if account.ClosedAt != nil || account.CurrentBalance == nil {
+			continue
+		}
+		n.add(&result.AccountAssets, &result.Liabilities, account.Type, *account.CurrentBalance,
+			currencyCode(account.IsoCurrencyCode, account.UnofficialCurrencyCode), unconverted)
+	}
+
+	for _, asset := range assets {
+		result.ManualAssets = result.ManualAssets.Add(asset.Value)
+	}
+
+	result.Series = make([]NetWorthPoint, len(dates))
+	for i, date := range dates {
+		result.Series[i] = NetWorthPoint{Date: date}
+	}
+
+	// snapshots are ordered by account then date, walk each account's run alongside the dates
+	for start := 0; start < len(snapshots); {
+		end := start
+		for end < len(snapshots) && snapshots[end].AccountID == snapshots[start].AccountID {
+			end++
+		}
+		account := accountsByID[snapshots[start].AccountID]
+		if account != nil {
+			n.addAccountSeries(result.Series, account, snapshots[start:end], unconverted)
+		}
+		start = end
+	}
+
+	// asset values are ordered the same way
+	for start := 0; start < len(assetValues); {
+		end := start
+		for end < len(assetValues) && assetValues[end].AssetID == assetValues[start].AssetID {
+			end++
+		}
+		addAssetSeries(result.Series, assetValues[start:end])
+		start = end
+	}
+
+	for code := range unconverted {
+		result.UnconvertedCurrencies = append(result.UnconvertedCurrencies, code)
+	}
+	slices.Sort(result.UnconvertedCurrencies)
+
+	return result, nil
+}
+
+// addAccountSeries adds one account's snapshots, oldest first, to every point of series
+// An account counts from its first snapshot until the day it was closed
+func (n *NetWorthCalculator) addAccountSeries(series []NetWorthPoint, account *models.Account, snapshots []*models.AccountBalanceSnapshot, unconverted map[string]bool) {
+	var last *models.AccountBalanceSnapshot
+	next := 0
+	for i := range series {
+		point := &series[i]
+		for next < len(snapshots) && !snapshots[next].Date.After(point.Date) {
+			last = snapshots[next]
+			next++
+		}
+		if last == nil || last.CurrentBalance == nil {
+			continue
+		}
+		if account.ClosedAt != nil && account.ClosedAt.Before(point.Date.AddDate(0, 0, 1)) {
+			continue
+		}
+		n.add(&point.Assets, &point.Liabilities, account.Type, *last.CurrentBalance,
+			currencyCode(last.IsoCurrencyCode, last.UnofficialCurrencyCode), unconverted)
+	}
+}
+
+// addAssetSeries adds one manual asset's values, oldest first, to the assets of every point of series
+// An asset counts from the day of its first value
+func addAssetSeries(series []NetWorthPoint, values []*models.AssetValue) {
+	var last *models.AssetValue
+	next := 0
+	for i := range series {
+		point := &series[i]
+		for next < len(values) && !values[next].Date.After(point.Date) {
+			last = values[next]
+			next++
+		}
+		if last != nil {
+			point.Assets = point.Assets.Add(last.Value)
+		}
+	}
+}
+
+// add converts balance and adds it to assets or liabilities according to the account type
+func (n *NetWorthCalculator) add(assets, liabilities *models.Money, accountType string, balance models.Money, code string, unconverted map[string]bool) {
+	var total *models.Money
+	switch {
+	case slices.Contains(assetAccountTypes, accountType):
+		total = assets
+	case slices.Contains(liabilityAccountTypes, accountType):
+		total = liabilities
+	default:
+		return
+	}
+
+	converted, ok := n.convert(balance, code)
+	if !ok {
+		unconverted[code] = true
+		return
+	}
+	*total = total.Add(converted)
+}
+
+// convert converts amount in currency code to the net worth currency
+// A balance with no currency code is taken to be in the net worth currency already
+func (n *NetWorthCalculator) convert(amount models.Money, code string) (models.Money, bool) {
+	if code == "" || code == n.currency {
+		return amount, true
+	}
+	rate, ok := n.rates[code]
+	if !ok {
+		return models.Money{}, false
+	}
+	return amount.Mul(rate), true
+}
+
+// currencyCode returns the ISO currency code, or the unofficial one Plaid uses for e.g. crypto
+func currencyCode(isoCurrencyCode, unofficialCurrencyCode *string) string {
+	if isoCurrencyCode != nil {
+		return strings.ToUpper(*isoCurrencyCode)
+	}
+	if unofficialCurrencyCode != nil {
+		return strings.ToUpper(*unofficialCurrencyCode)
+	}
+	return ""
+}
+
+// seriesDates returns the dates of a series of periods ending today, oldest first
+// Monthly series use the last day of each month, the current month ends today
+func seriesDates(now time.Time, interval string, periods int) []time.Time {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	dates := make([]time.Time, periods)
+	for i := range periods {
+		back := periods - 1 - i
+		if interval == NetWorthIntervalMonthly {
+			if back == 0 {
+				dates[i] = today
+			} else {
+				// day 0 of a month is the last day of the month before it
+				dates[i] = time.Date(today.Year(), today.Month()-time.Month(back)+1, 0, 0, 0, 0, 0, time.UTC)
+			}
+		} else {
+			dates[i] = today.AddDate(0, 0, -back)
+		}
+	}
+	return dates
+}
@@ -0,0 +1,245 @@
+package services
+
+import (
+	"compound/go-server/pkg/models"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+)
+
+func parseDate(s string) time.Time {
+	d, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func formatDates(dates []time.Time) []string {
+	formatted := make([]string, len(dates))
+	for i, d := range dates {
+		formatted[i] = d.Format(time.DateOnly)
+	}
+	return formatted
+}
+
+func TestSeriesDates(t *testing.T) {
+	tests := []struct {
+		name     string
+		now      time.Time
+		interval string
+		periods  int
+		want     []string
+	}{
+		{
+			name:     "daily across the end of January",
+			now:      time.Date(2025, 2, 2, 23, 59, 0, 0, time.UTC),
+			interval: NetWorthIntervalDaily,
+			periods:  4,
+			want:     []string{"2025-01-30", "2025-01-31", "2025-02-01", "2025-02-02"},
+		},
+		{
+			name:     "monthly from January 31 into February",
+			now:      time.Date(2025, 2, 15, 8, 0, 0, 0, time.UTC),
+			interval: NetWorthIntervalMonthly,
+			periods:  3,
+			want:     []string{"2024-12-31", "2025-01-31", "2025-02-15"},
+		},
+		{
+			name:     "monthly ending on a month end",
+			now:      time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC),
+			interval: NetWorthIntervalMonthly,
+			periods:  3,
+			want:     []string{"2025-01-31", "2025-02-28", "2025-03-31"},
+		},
+		{
+			name:     "monthly through a leap February",
+			now:      time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC),
+			interval: NetWorthIntervalMonthly,
+			periods:  2,
+			want:     []string{"2024-02-29", "2024-03-10"},
+		},
+		{
+			name:     "single period is today",
+			now:      time.Date(2025, 1, 31, 12, 0, 0, 0, time.UTC),
+			interval: NetWorthIntervalMonthly,
+			periods:  1,
+			want:     []string{"2025-01-31"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := formatDates(seriesDates(tt.now, tt.interval, tt.periods))
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("seriesDates = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// testSnapshot is a balance snapshot of the account under test, currency "" is the net worth currency
+type testSnapshot struct {
+	date     string
+	balance  string
+	currency string
+}
+
+func TestAddAccountSeries(t *testing.T) {
+	dates := []time.Time{parseDate("2025-01-30"), parseDate("2025-01-31"), parseDate("2025-02-01"), parseDate("2025-02-02")}
+	closedAt := time.Date(2025, 2, 1, 15, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name            string
+		accountType     string
+		closedAt        *time.Time
+		snapshots       []testSnapshot
+		wantAssets      []string
+		wantLiabilities []string
+		wantUnconverted []string
+	}{
+		{
+			name:        "carries the last balance forward",
+			accountType: "depository",
+			snapshots:   []testSnapshot{{"2025-01-30", "100.00", ""}, {"2025-02-01", "150.25", ""}},
+			wantAssets:  []string{"100.00", "100.00", "150.25", "150.25"},
+		},
+		{
+			name:        "counts from the first snapshot",
+			accountType: "investment",
+			snapshots:   []testSnapshot{{"2025-01-31", "100.00", ""}},
+			wantAssets:  []string{"0.00", "100.00", "100.00", "100.00"},
+		},
+		{
+			name:        "carries a snapshot from before the series in",
+			accountType: "depository",
+			snapshots:   []testSnapshot{{"2025-01-20", "80.00", ""}},
+			wantAssets:  []string{"80.00", "80.00", "80.00", "80.00"},
+		},
+		{
+			name:            "credit balances are liabilities",
+			accountType:     "credit",
+			snapshots:       []testSnapshot{{"2025-01-30", "500.00", ""}, {"2025-02-02", "-20.00", ""}},
+			wantLiabilities: []string{"500.00", "500.00", "500.00", "-20.00"},
+		},
+		{
+			name:            "loans are liabilities",
+			accountType:     "loan",
+			snapshots:       []testSnapshot{{"2025-01-30", "12000.00", ""}},
+			wantLiabilities: []string{"12000.00", "12000.00", "12000.00", "12000.00"},
+		},
+		{
+			name:        "dropped from the day it closes",
+			accountType: "depository",
+			closedAt:    &closedAt,
+			snapshots:   []testSnapshot{{"2025-01-30", "100.00", ""}, {"2025-02-02", "0.00", ""}},
+			wantAssets:  []string{"100.00", "100.00", "0.00", "0.00"},
+		},
+		{
+			name:        "converts with the exchange rate",
+			accountType: "depository",
+			snapshots:   []testSnapshot{{"2025-01-30", "100.00", "EUR"}, {"2025-02-01", "10.00", "eur"}},
+			wantAssets:  []string{"108.00", "108.00", "10.80", "10.80"},
+		},
+		{
+			name:            "left out without an exchange rate",
+			accountType:     "depository",
+			snapshots:       []testSnapshot{{"2025-01-30", "100.00", "GBP"}},
+			wantUnconverted: []string{"GBP"},
+		},
+		{
+			name:        "other account types are left out",
+			accountType: "other",
+			snapshots:   []testSnapshot{{"2025-01-30", "100.00", ""}},
+		},
+	}
+
+	zeros := []string{"0.00", "0.00", "0.00", "0.00"}
+	calculator := NewNetWorthCalculator(nil, NetWorthConfig{Rates: map[string]models.Money{"EUR": models.MustParseMoney("1.08")}})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			account := &models.Account{ID: 1, Type: tt.accountType, ClosedAt: tt.closedAt}
+			var snapshots []*models.AccountBalanceSnapshot
+			for _, s := range tt.snapshots {
+				balance := models.MustParseMoney(s.balance)
+				snapshot := &models.AccountBalanceSnapshot{AccountID: account.ID, Date: parseDate(s.date), CurrentBalance: &balance}
+				if s.currency != "" {
+					snapshot.IsoCurrencyCode = &s.currency
+				}
+				snapshots = append(snapshots, snapshot)
+			}
+
+			series := make([]NetWorthPoint, len(dates))
+			for i, d := range dates {
+				series[i] = NetWorthPoint{Date: d}
+			}
+			unconverted := map[string]bool{}
+			calculator.addAccountSeries(series, account, snapshots, unconverted)
+
+			wantAssets, wantLiabilities := tt.wantAssets, tt.wantLiabilities
+			if wantAssets == nil {
+				wantAssets = zeros
+			}
+			if wantLiabilities == nil {
+				wantLiabilities = zeros
+			}
+			for i, point := range series {
+				if got := point.Assets.String(); got != wantAssets[i] {
+					t.Errorf("%s assets = %s, want %s", point.Date.Format(time.DateOnly), got, wantAssets[i])
+				}
+				if got := point.Liabilities.String(); got != wantLiabilities[i] {
+					t.Errorf("%s liabilities = %s, want %s", point.Date.Format(time.DateOnly), got, wantLiabilities[i])
+				}
+			}
+			if got := slices.Sorted(maps.Keys(unconverted)); !slices.Equal(got, tt.wantUnconverted) {
+				t.Errorf("unconverted currencies = %v, want %v", got, tt.wantUnconverted)
+			}
+		})
+	}
+}
+
+func TestAddAssetSeries(t *testing.T) {
+	series := []NetWorthPoint{{Date: parseDate("2025-01-31")}, {Date: parseDate("2025-02-28")}, {Date: parseDate("2025-03-31")}}
+	values := []*models.AssetValue{
+		{Date: parseDate("2025-02-10"), Value: models.MustParseMoney("250000.00")},
+		{Date: parseDate("2025-03-31"), Value: models.MustParseMoney("255000.50")},
+	}
+
+	addAssetSeries(series, values)
+
+	want := []string{"0.00", "250000.00", "255000.50"}
+	for i, point := range series {
+		if got := point.Assets.String(); got != want[i] {
+			t.Errorf("%s assets = %s, want %s", point.Date.Format(time.DateOnly), got, want[i])
+		}
+	}
+}
+
+func TestNetWorthConvert(t *testing.T) {
+	calculator := NewNetWorthCalculator(nil, NetWorthConfig{
+		Currency: "eur",
+		Rates:    map[string]models.Money{"USD": models.MustParseMoney("0.9259"), "BTC": models.MustParseMoney("60000")},
+	})
+
+	tests := []struct {
+		amount string
+		code   string
+		want   string
+		ok     bool
+	}{
+		{"10.00", "", "10.00", true},
+		{"10.00", "EUR", "10.00", true},
+		{"10.00", "USD", "9.259", true},
+		{"0.5", "BTC", "30000.00", true},
+		{"10.00", "GBP", "0.00", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := calculator.convert(models.MustParseMoney(tt.amount), tt.code)
+		if got.String() != tt.want || ok != tt.ok {
+			t.Errorf("convert(%s %s) = %s, %v, want %s, %v", tt.amount, tt.code, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package services
+
+import (
+	"compound/go-server/internal/db"
+	plaidpkg "compound/go-server/internal/plaid"
+	"compound/go-server/internal/redact"
+	"compound/go-server/pkg/models"
+	"context"
+	"errors"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Defaults for zero SchedulerConfig fields
+const (
+	defaultSyncInterval    = time.Hour
+	defaultSyncConcurrency = 4
+)
+
+// scheduledStatuses are the item statuses the scheduler syncs, every status whose access token
+// still works. Items about to expire or be disconnected keep syncing until they do.
+// Items in any other status need the user to act (re-link, re-consent) before a sync can succeed
+var scheduledStatuses = []string{
+	models.ItemStatusGood,
+	models.ItemStatusLinked,
+	models.ItemStatusPendingExpiration,
+	models.ItemStatusPendingDisconnect,
+}
+
+// badItemErrorCodes are the ITEM_ERROR codes a later sync can't recover from without the user
+// Items failing with one are marked bad and left unscheduled until they are re-linked, every
+// other ITEM_ERROR (e.g. PRODUCT_NOT_READY) is retried on the next cycle
+var badItemErrorCodes = map[string]bool{
+	"INVALID_CREDENTIALS":      true,
+	"INSUFFICIENT_CREDENTIALS": true,
+	"USER_SETUP_REQUIRED":      true,
+	"MFA_NOT_SUPPORTED":        true,
+	"NO_ACCOUNTS":              true,
+	"ITEM_NOT_SUPPORTED":       true,
+}
+
+// SchedulerConfig controls how often and how aggressively the SyncScheduler syncs items
+type SchedulerConfig struct {
+	// Interval is the time between sync cycles
+	Interval time.Duration
+	// Concurrency is how many items are synced at once
+	// Each sync holds two pooled connections, one for its advisory lock and one for its queries,
+	// and the leader lock holds one more for as long as the replica leads. Concurrency is clamped
+	// so the scheduler uses at most half the pool, leaving the rest to HTTP handlers, but at least
+	// one sync always runs. Raise DB_MAX_CONNS to sync more items at once.
+	Concurrency int
+	// Jitter is the maximum random delay before each item's sync, spreading calls to Plaid out
+	Jitter time.Duration
+}
+
+// CycleResult summarizes one pass of the SyncScheduler over every eligible item
+// MarkedBad counts items moved out of a scheduled status, to bad or login_required
+type CycleResult struct {
+	Items         int
+	Synced        int
+	Failed        int
+	Skipped       int
+	MarkedBad     int
+	AddedCount    int
+	ModifiedCount int
+	RemovedCount  int
+}
+
+// SyncScheduler periodically syncs transactions for every linked item
+// Only one server replica schedules at a time, leadership is a Postgres advisory lock held
+// for as long as the replica runs
+type SyncScheduler struct {
+	store  *db.Store
+	syncer *TransactionSyncer
+	cfg    SchedulerConfig
+}
+
+// NewSyncScheduler creates a SyncScheduler that runs syncs through syncer
+func NewSyncScheduler(store *db.Store, syncer *TransactionSyncer, cfg SchedulerConfig) *SyncScheduler {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultSyncInterval
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = defaultSyncConcurrency
+	}
+
+	maxConcurrency := maxConcurrentSyncs(store)
+	if cfg.Concurrency > maxConcurrency {
+		log.Printf("SYNC SCHEDULER: syncing %d items at once instead of %d, the database pool only has %d connections",
+			maxConcurrency, cfg.Concurrency, store.MaxConns())
+		cfg.Concurrency = maxConcurrency
+	}
+
+	return &SyncScheduler{store: store, syncer: syncer, cfg: cfg}
+}
+
+// Run runs a sync cycle every Interval until ctx is cancelled
+// The first cycle runs as soon as this replica becomes the leader, so a restart doesn't delay
+// every sync by an Interval, and each cycle is followed by a retry of item removals still
+// waiting on Plaid. Replicas that aren't the leader retry for leadership every Interval.
+// Cancelling ctx aborts in-flight syncs (each sync's writes are atomic, so nothing is left half
+// applied), then Run releases leadership and returns.
+func (s *SyncScheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	var lock *db.AdvisoryLock
+	defer func() {
+		if lock != nil {
+			lock.Release()
+		}
+	}()
+
+	for {
+		lock = s.ensureLeader(ctx, lock)
+		if lock != nil {
+			start := time.Now()
+			result := s.RunCycle(ctx)
+			log.Printf("SYNC SCHEDULER: synced %d/%d items in %s (%d failed, %d already syncing, %d marked bad): %d added, %d modified, %d removed",
+				result.Synced, result.Items, time.Since(start).Round(time.Millisecond), result.Failed, result.Skipped, result.MarkedBad,
+				result.AddedCount, result.ModifiedCount, result.RemovedCount)
+
+			if removed := RetryPendingItemRemovals(ctx, s.store, s.syncer.aggregator); removed > 0 {
+				log.Printf("SYNC SCHEDULER: removed %d replaced items from Plaid on retry", removed)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ensureLeader returns the scheduler lock if this replica holds it, taking it if it's free
+// Returns nil if another replica is the leader
+func (s *SyncScheduler) ensureLeader(ctx context.Context, lock *db.AdvisoryLock) *db.AdvisoryLock {
+	if lock != nil {
+		if lock.Held(ctx) {
+			return lock
+		}
+		log.Printf("SYNC SCHEDULER: lost leadership, database connection dropped")
+		lock.Release()
+	}
+
+	lock, err := s.store.TryAdvisoryLock(ctx, db.LockClassSyncScheduler, 0)
+	if err != nil {
+		log.Printf("SYNC SCHEDULER: failed to take leader lock: %s", redact.Error(err))
+		return nil
+	}
+	if lock != nil {
+		log.Printf("SYNC SCHEDULER: this replica is now the scheduler leader")
+	}
+	return lock
+}
+
+// RunCycle syncs every item in a scheduled status once, at most Concurrency at a time
+// Items whose sync fails with an unrecoverable Plaid ITEM_ERROR are marked bad (or
+// login_required for ITEM_LOGIN_REQUIRED) so later cycles skip them until the user re-links
+func (s *SyncScheduler) RunCycle(ctx context.Context) CycleResult {
+	var result CycleResult
+
+	itemIDs, err := s.store.GetItemIDsByStatus(ctx, scheduledStatuses...)
+	if err != nil {
+		log.Printf("SYNC SCHEDULER: failed to list items: %s", redact.Error(err))
+		return result
+	}
+	result.Items = len(itemIDs)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, s.cfg.Concurrency)
+
+	for _, itemID := range itemIDs {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return result
+		case sem <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			outcome := s.syncItem(ctx, itemID)
+
+			mu.Lock()
+			defer mu.Unlock()
+			result.add(outcome)
+		}()
+	}
+
+	wg.Wait()
+	return result
+}
+
+// itemOutcome is the result of one scheduled item sync
+type itemOutcome struct {
+	sync      *SyncResult
+	failed    bool
+	skipped   bool
+	markedBad bool
+}
+
+// syncItem waits a random jitter then syncs one item, recording the outcome
+func (s *SyncScheduler) syncItem(ctx context.Context, itemID int) itemOutcome {
+	if s.cfg.Jitter > 0 {
+		select {
+		case <-ctx.Done():
+			return itemOutcome{failed: true}
+		case <-time.After(rand.N(s.cfg.Jitter)):
+		}
+	}
+
+	syncResult, err := s.syncer.SyncTransactionsForItem(ctx, itemID, models.SyncTriggerScheduler)
+	if err == nil {
+		return itemOutcome{sync: syncResult}
+	}
+
+	if ctx.Err() != nil {
+		return itemOutcome{failed: true}
+	}
+
+	// a webhook or user triggered sync got there first, it leaves the item just as fresh
+	if errors.Is(err, ErrSyncInProgress) {
+		return itemOutcome{skipped: true}
+	}
+
+	log.Printf("SYNC SCHEDULER: item %d: sync failed: %s", itemID, redact.Error(err))
+
+	// the syncer already moved the item to login_required, which isn't scheduled
+	if plaidpkg.IsLoginRequired(err) {
+		return itemOutcome{failed: true, markedBad: true}
+	}
+
+	// anything else may clear up on its own, the item stays scheduled for the next cycle
+	if plaidpkg.ErrorType(err) != plaidpkg.ErrorTypeItemError || !badItemErrorCodes[plaidpkg.ErrorCode(err)] {
+		return itemOutcome{failed: true}
+	}
+
+	if err := s.store.UpdateItemStatus(ctx, itemID, models.ItemStatusBad); err != nil {
+		log.Printf("SYNC SCHEDULER: item %d: failed to mark item bad: %s", itemID, redact.Error(err))
+		return itemOutcome{failed: true}
+	}
+
+	log.Printf("SYNC SCHEDULER: item %d: marked bad after %s, skipping until it is re-linked", itemID, plaidpkg.ErrorCode(err))
+	return itemOutcome{failed: true, markedBad: true}
+}
+
+func (r *CycleResult) add(outcome itemOutcome) {
+	if outcome.failed {
+		r.Failed++
+	}
+	if outcome.skipped {
+		r.Skipped++
+	}
+	if outcome.markedBad {
+		r.MarkedBad++
+	}
+	if outcome.sync != nil {
+		r.Synced++
+		r.AddedCount += outcome.sync.AddedCount
+		r.ModifiedCount += outcome.sync.ModifiedCount
+		r.RemovedCount += outcome.sync.RemovedCount
+	}
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"compound/go-server/internal/db/dbtest"
+	plaidpkg "compound/go-server/internal/plaid"
+	"compound/go-server/pkg/models"
+	"context"
+	"testing"
+)
+
+// TestRunCycleSyncsUsableItems checks the scheduler syncs every item whose access token still
+// works, those about to expire included, and skips the ones waiting on the user
+func TestRunCycleSyncsUsableItems(t *testing.T) {
+	store := dbtest.Open(t)
+	ctx := context.Background()
+	fake := newScriptedFake(t)
+
+	user, err := store.CreateUser(ctx, "alice", "not-a-real-hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	synced := map[string]bool{
+		models.ItemStatusLinked:            true,
+		models.ItemStatusGood:              true,
+		models.ItemStatusPendingExpiration: true,
+		models.ItemStatusPendingDisconnect: true,
+		models.ItemStatusBad:               false,
+		models.ItemStatusLoginRequired:     false,
+		models.ItemStatusRevoked:           false,
+	}
+	itemIDs := map[string]int{}
+	for status := range synced {
+		accessToken, plaidItemID, err := fake.ExchangePublicToken(ctx, "public-fake")
+		if err != nil {
+			t.Fatal(err)
+		}
+		item, err := store.CreateItem(ctx, user.ID, accessToken, plaidItemID, plaidpkg.FakeInstitutionID, status)
+		if err != nil {
+			t.Fatal(err)
+		}
+		itemIDs[status] = item.ID
+	}
+
+	scheduler := NewSyncScheduler(store, NewTransactionSyncer(store, fake), SchedulerConfig{})
+	result := scheduler.RunCycle(ctx)
+	if result.Items != 4 || result.Synced != 4 || result.Failed != 0 {
+		t.Fatalf("got %+v, want 4 items synced", result)
+	}
+
+	for status, want := range synced {
+		runs, err := store.GetSyncRunsByItemID(ctx, itemIDs[status], 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := len(runs) == 1; got != want {
+			t.Errorf("%s item: %d sync runs, want synced = %v", status, len(runs), want)
+		}
+	}
+}
+
+// TestRunCycleMarksOnlyUnrecoverableItemsBad checks an ITEM_ERROR the user has to fix takes the
+// item out of the schedule, while any other ITEM_ERROR leaves it to be retried next cycle
+func TestRunCycleMarksOnlyUnrecoverableItemsBad(t *testing.T) {
+	store := dbtest.Open(t)
+	ctx := context.Background()
+	fake := newScriptedFake(t)
+	scheduler := NewSyncScheduler(store, NewTransactionSyncer(store, fake), SchedulerConfig{})
+
+	user, err := store.CreateUser(ctx, "alice", "not-a-real-hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		code      string
+		wantBad   bool
+		wantState string
+	}{
+		{code: "NO_ACCOUNTS", wantBad: true, wantState: models.ItemStatusBad},
+		{code: "INVALID_CREDENTIALS", wantBad: true, wantState: models.ItemStatusBad},
+		{code: "PRODUCT_NOT_READY", wantBad: false, wantState: models.ItemStatusGood},
+		{code: "INSTITUTION_NOT_RESPONDING", wantBad: false, wantState: models.ItemStatusGood},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			accessToken, plaidItemID, err := fake.ExchangePublicToken(ctx, "public-fake")
+			if err != nil {
+				t.Fatal(err)
+			}
+			item, err := store.CreateItem(ctx, user.ID, accessToken, plaidItemID, plaidpkg.FakeInstitutionID, models.ItemStatusGood)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// keep the item the only one scheduled in later subtests
+			t.Cleanup(func() {
+				if err := store.UpdateItemStatus(ctx, item.ID, models.ItemStatusRevoked); err != nil {
+					t.Error(err)
+				}
+			})
+
+			fake.FailNextSync(plaidpkg.NewFakeError(plaidpkg.ErrorTypeItemError, tt.code, "item error"))
+
+			result := scheduler.RunCycle(ctx)
+			if result.Failed != 1 || (result.MarkedBad == 1) != tt.wantBad {
+				t.Fatalf("got %+v, want 1 failed and marked bad = %v", result, tt.wantBad)
+			}
+
+			got, err := store.GetItemByID(ctx, item.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != tt.wantState {
+				t.Fatalf("status = %s, want %s", got.Status, tt.wantState)
+			}
+
+			// an item left scheduled syncs on the next cycle
+			result = scheduler.RunCycle(ctx)
+			if wantSynced := !tt.wantBad; (result.Synced == 1) != wantSynced {
+				t.Fatalf("next cycle got %+v, want synced = %v", result, wantSynced)
+			}
+		})
+	}
+}
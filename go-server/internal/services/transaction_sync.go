@@ -0,0 +1,452 @@
+package services
+
+import (
+	"compound/go-server/internal/db"
+	plaidpkg "compound/go-server/internal/plaid"
+	"compound/go-server/internal/redact"
+	"compound/go-server/pkg/models"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	plaid "github.com/plaid/plaid-go/v40/plaid"
+)
+
+// errMutationDuringPagination is returned by Plaid when the item's data changed
+// while we were paging through it, the whole sync must restart from the original cursor
+const errMutationDuringPagination = "TRANSACTIONS_SYNC_MUTATION_DURING_PAGINATION"
+
+// maxSyncRestarts bounds how many times a single sync restarts after a mutation during pagination
+const maxSyncRestarts = 3
+
+// backgroundSyncTimeout bounds a background sync, including time spent retrying behind a sync
+// already in progress
+const backgroundSyncTimeout = 5 * time.Minute
+
+// backgroundSyncRetryDelay is how long a background sync waits before retrying an item that's
+// syncing elsewhere
+const backgroundSyncRetryDelay = 5 * time.Second
+
+// ErrSyncInProgress is returned when another sync of the same item, on any replica, hasn't finished
+var ErrSyncInProgress = errors.New("sync already in progress")
+
+// SyncResult summarizes a completed transaction sync for an item
+type SyncResult struct {
+	AddedCount    int
+	ModifiedCount int
+	RemovedCount  int
+	Pages         int
+	Cursor        string
+}
+
+// TransactionSyncer pulls transaction updates from Plaid into the database
+// Close it before closing the store, background syncs hold pooled connections until they end.
+type TransactionSyncer struct {
+	store      *db.Store
+	aggregator plaidpkg.Aggregator
+
+	// syncItem runs each background sync, SyncTransactionsForItem outside tests
+	syncItem func(ctx context.Context, itemID int, trigger string) (*SyncResult, error)
+
+	// ctx is the parent of every background sync, cancelled by Close
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// maxBackground is how many background syncs run at once across all items
+	maxBackground int
+
+	mu         sync.Mutex
+	closed     bool
+	background map[int]*backgroundSync // keyed by item ID, running or queued
+	queue      []int                   // item IDs waiting for a background sync slot, oldest first
+	running    int                     // goroutines running background syncs
+}
+
+// NewTransactionSyncer creates a TransactionSyncer that reads from aggregator and writes through store
+func NewTransactionSyncer(store *db.Store, aggregator plaidpkg.Aggregator) *TransactionSyncer {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &TransactionSyncer{
+		store:      store,
+		aggregator: aggregator,
+		ctx:        ctx,
+		cancel:     cancel,
+		background: map[int]*backgroundSync{},
+	}
+	s.syncItem = s.SyncTransactionsForItem
+	s.maxBackground = maxConcurrentSyncs(store)
+	return s
+}
+
+// maxConcurrentSyncs is how many syncs fit in half of store's pool
+// Each sync holds two pooled connections, one for its advisory lock and one for its queries,
+// and one more connection is set aside for the scheduler's leader lock. At least one sync
+// always runs.
+func maxConcurrentSyncs(store *db.Store) int {
+	return max((store.MaxConns()/2-1)/2, 1)
+}
+
+// Close cancels every background sync and waits for them to return
+// Syncs still queued or requested after Close are dropped. Each sync's writes are atomic, so a cancelled sync
+// leaves its item as it was and the next sync picks up from there.
+func (s *TransactionSyncer) Close() {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+
+	s.cancel()
+	s.wg.Wait()
+}
+
+// transactionUpdates holds every page fetched from Plaid for a single sync
+// accounts is from the last page, every page carries the item's accounts with current balances
+type transactionUpdates struct {
+	added    []plaid.Transaction
+	modified []plaid.Transaction
+	removed  []plaid.RemovedTransaction
+	accounts []plaid.AccountBase
+	cursor   string
+	pages    int
+}
+
+// SyncTransactionsForItem fetches all pending transaction updates for an item and stores them
+// Pages are fetched until Plaid reports HasMore=false, then added/modified/removed transactions
+// and the new cursor are written in a single database transaction, so a failure leaves the
+// item exactly where it was before the sync started
+//
+// Syncs of one item are serialized with an advisory lock, two syncs reading the same cursor
+// would otherwise race to write the next one. Returns ErrSyncInProgress if the item is already syncing.
+//
+// Every sync is recorded in sync_runs_table, trigger says what started it (see models.SyncTrigger*).
+// An ITEM_LOGIN_REQUIRED failure moves the item to models.ItemStatusLoginRequired.
+func (s *TransactionSyncer) SyncTransactionsForItem(ctx context.Context, itemID int, trigger string) (*SyncResult, error) {
+	lock, err := s.store.TryAdvisoryLock(ctx, db.LockClassItemSync, int32(itemID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock item: %w", err)
+	}
+	if lock == nil {
+		return nil, ErrSyncInProgress
+	}
+	defer lock.Release()
+
+	return s.syncLocked(ctx, itemID, trigger)
+}
+
+// SyncInBackground syncs an item in its own goroutine, for syncs requested by webhooks and
+// relinks that mustn't hold up the response
+// At most one background sync per item runs in this process. A request for an item that's
+// already syncing in the background marks it to sync once more when the running sync
+// finishes, since the running one may have fetched before the new updates arrived. A sync
+// already running elsewhere (another replica, the scheduler, a manual sync) is retried after
+// backgroundSyncRetryDelay rather than waiting on its lock, which would park a pooled connection.
+//
+// Across items, background syncs are bounded by the same share of the pool as the scheduler's,
+// so a burst of webhooks can't exhaust it. Items past the limit are queued and synced in the
+// order they were requested as running syncs finish.
+func (s *TransactionSyncer) SyncInBackground(itemID int, trigger string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		log.Printf("%s sync: item %d: not syncing, shutting down", trigger, itemID)
+		return
+	}
+
+	if queued, ok := s.background[itemID]; ok {
+		queued.again = true
+		queued.trigger = trigger
+		return
+	}
+
+	s.background[itemID] = &backgroundSync{trigger: trigger, again: true}
+	if s.running >= s.maxBackground {
+		s.queue = append(s.queue, itemID)
+		return
+	}
+
+	s.running++
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.runBackgroundSyncs(itemID)
+	}()
+}
+
+// backgroundSync is an item's running or queued background sync
+// again is set when a sync was requested that hasn't started yet, trigger is the latest request's
+type backgroundSync struct {
+	trigger string
+	again   bool
+}
+
+// runBackgroundSyncs syncs the item until no more syncs were requested while the last one ran,
+// then moves on to the next queued item, until the queue is empty or the syncer is closed
+func (s *TransactionSyncer) runBackgroundSyncs(itemID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for {
+		queued := s.background[itemID]
+		if queued.again && !s.closed {
+			queued.again = false
+			trigger := queued.trigger
+			s.mu.Unlock()
+			s.backgroundSyncOnce(itemID, trigger)
+			s.mu.Lock()
+			continue
+		}
+		delete(s.background, itemID)
+
+		if s.closed {
+			for _, queuedID := range s.queue {
+				delete(s.background, queuedID)
+			}
+			s.queue = nil
+		}
+		if len(s.queue) == 0 {
+			s.running--
+			return
+		}
+		itemID = s.queue[0]
+		s.queue = s.queue[1:]
+	}
+}
+
+// backgroundSyncOnce runs one sync, retrying while the item is syncing elsewhere
+func (s *TransactionSyncer) backgroundSyncOnce(itemID int, trigger string) {
+	ctx, cancel := context.WithTimeout(s.ctx, backgroundSyncTimeout)
+	defer cancel()
+
+	for {
+		result, err := s.syncItem(ctx, itemID, trigger)
+		if err != nil && s.ctx.Err() != nil {
+			log.Printf("%s sync: item %d: cancelled, shutting down", trigger, itemID)
+			return
+		}
+		if errors.Is(err, ErrSyncInProgress) {
+			select {
+			case <-ctx.Done():
+				log.Printf("%s sync: item %d: gave up waiting for the sync already in progress", trigger, itemID)
+				return
+			case <-time.After(backgroundSyncRetryDelay):
+				continue
+			}
+		}
+		if err != nil {
+			log.Printf("%s sync: item %d: sync failed: %s", trigger, itemID, redact.Error(err))
+			return
+		}
+
+		log.Printf("%s sync: item %d: %d added, %d modified, %d removed",
+			trigger, itemID, result.AddedCount, result.ModifiedCount, result.RemovedCount)
+		return
+	}
+}
+
+// syncLocked runs and records a sync, the caller must hold the item's sync lock
+func (s *TransactionSyncer) syncLocked(ctx context.Context, itemID int, trigger string) (*SyncResult, error) {
+	// retrieve item from DB to get access token and last cursor
+	item, err := s.store.GetItemByID(ctx, itemID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get item: %w", err)
+	}
+
+	run, err := s.store.CreateSyncRun(ctx, item.ID, trigger, item.TransactionsCursor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record sync run: %w", err)
+	}
+
+	result, err := s.sync(ctx, item)
+	s.finishSyncRun(run, result, err)
+
+	if plaidpkg.IsLoginRequired(err) {
+		s.markLoginRequired(item)
+	}
+
+	return result, err
+}
+
+// markLoginRequired moves an item whose credentials Plaid rejected to login_required
+// so the client knows to send the user through Link update mode
+func (s *TransactionSyncer) markLoginRequired(item *models.Item) {
+	if item.Status == models.ItemStatusLoginRequired {
+		return
+	}
+	if err := s.store.UpdateItemStatus(context.Background(), item.ID, models.ItemStatusLoginRequired); err != nil {
+		log.Printf("transactions sync: item %d: failed to mark item login required: %s", item.ID, redact.Error(err))
+		return
+	}
+	log.Printf("transactions sync: item %d: Plaid requires the user to log in again, marked login required", item.ID)
+}
+
+// finishSyncRun records the outcome of a sync, a failure to record it is logged but doesn't fail the sync
+func (s *TransactionSyncer) finishSyncRun(run *models.SyncRun, result *SyncResult, syncErr error) {
+	if result != nil {
+		run.Pages = result.Pages
+		run.AddedCount = result.AddedCount
+		run.ModifiedCount = result.ModifiedCount
+		run.RemovedCount = result.RemovedCount
+		run.CursorAfter = &result.Cursor
+	}
+	if syncErr != nil {
+		message := redact.Error(syncErr)
+		run.ErrorMessage = &message
+		if code := plaidpkg.ErrorCode(syncErr); code != "" {
+			run.ErrorCode = &code
+		}
+	}
+
+	// the sync's own context may be what ended it, the outcome is still worth recording
+	if err := s.store.FinishSyncRun(context.Background(), run); err != nil {
+		log.Printf("transactions sync: item %d: failed to record sync run %d: %s", run.ItemID, run.ID, redact.Error(err))
+	}
+}
+
+// sync fetches and applies every update since the item's cursor
+func (s *TransactionSyncer) sync(ctx context.Context, item *models.Item) (*SyncResult, error) {
+	ctx = plaidpkg.WithEventScope(ctx, item.UserID, item.ID)
+
+	updates, err := s.fetchTransactionUpdates(ctx, item.PlaidAccessToken, item.TransactionsCursor)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.store.WithTx(ctx, func(tx *db.Store) error {
+		return applyTransactionUpdates(ctx, tx, item.ID, updates)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &SyncResult{
+		AddedCount:    len(updates.added),
+		ModifiedCount: len(updates.modified),
+		RemovedCount:  len(updates.removed),
+		Pages:         updates.pages,
+		Cursor:        updates.cursor,
+	}, nil
+}
+
+// fetchTransactionUpdates pages through /transactions/sync starting at cursor
+// If Plaid reports the data changed mid-pagination, everything fetched so far is
+// discarded and pagination restarts from the original cursor
+func (s *TransactionSyncer) fetchTransactionUpdates(ctx context.Context, accessToken string, cursor *string) (*transactionUpdates, error) {
+	for attempt := 0; ; attempt++ {
+		updates, err := s.fetchAllPages(ctx, accessToken, cursor)
+		if err == nil {
+			return updates, nil
+		}
+
+		if plaidpkg.ErrorCode(err) != errMutationDuringPagination || attempt >= maxSyncRestarts {
+			return nil, err
+		}
+
+		log.Printf("transactions sync: data changed during pagination, restarting (attempt %d)", attempt+1)
+	}
+}
+
+// fetchAllPages calls /transactions/sync until HasMore is false
+func (s *TransactionSyncer) fetchAllPages(ctx context.Context, accessToken string, cursor *string) (*transactionUpdates, error) {
+	updates := &transactionUpdates{}
+	if cursor != nil {
+		updates.cursor = *cursor
+	}
+
+	hasMore := true
+	for hasMore {
+		nextCursor := updates.cursor
+		result, err := s.aggregator.SyncTransactions(ctx, accessToken, &nextCursor)
+		if err != nil {
+			return nil, err
+		}
+
+		updates.added = append(updates.added, result.Added...)
+		updates.modified = append(updates.modified, result.Modified...)
+		updates.removed = append(updates.removed, result.Removed...)
+		updates.accounts = result.Accounts
+		updates.cursor = result.NextCursor
+		updates.pages++
+		hasMore = result.HasMore
+	}
+
+	return updates, nil
+}
+
+// applyTransactionUpdates writes a full set of sync updates and the new cursor inside tx
+// The accounts that came with the updates are stored too, refreshing their balances
+func applyTransactionUpdates(ctx context.Context, tx *db.Store, itemID int, updates *transactionUpdates) error {
+	// cache plaid account ID -> DB account ID, most transactions share a handful of accounts
+	accountIDs := map[string]int{}
+
+	// store balances first, this also stores any account the transactions below belong to
+	for _, plaidAccount := range updates.accounts {
+		account, err := StoreAccount(ctx, tx, itemID, plaidAccount)
+		if err != nil {
+			return err
+		}
+		accountIDs[account.PlaidAccountID] = account.ID
+	}
+
+	for _, plaidTx := range append(updates.added, updates.modified...) {
+		accountID, ok := accountIDs[plaidTx.GetAccountId()]
+		if !ok {
+			account, err := tx.GetAccountByPlaidAccountID(ctx, itemID, plaidTx.GetAccountId())
+			if err != nil {
+				return fmt.Errorf("failed to get DB account for %s: %w", plaidTx.GetAccountId(), err)
+			}
+			accountID = account.ID
+			accountIDs[plaidTx.GetAccountId()] = accountID
+		}
+
+		// map category data
+		categoryData := map[string]interface{}{
+			"legacy":                    plaidTx.GetCategory(),
+			"personal_finance_category": plaidTx.GetPersonalFinanceCategory(),
+		}
+
+		// Convert account owner string to *string
+		var ownerPtr *string
+		if accountOwner := plaidTx.GetAccountOwner(); accountOwner != "" {
+			ownerPtr = &accountOwner
+		}
+
+		_, err := tx.CreateOrUpdateTransaction(
+			ctx,
+			accountID,
+			plaidTx.GetTransactionId(),
+			categoryData,
+			plaidTx.GetTransactionType(),
+			plaidTx.GetName(),
+			plaidTx.MerchantName.Get(),
+			models.MoneyFromFloat(plaidTx.GetAmount()),
+			plaidTx.GetIsoCurrencyCode(),
+			plaidTx.GetUnofficialCurrencyCode(),
+			plaidTx.GetDate(),
+			plaidTx.GetPending(),
+			ownerPtr,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to store transaction: %w", err)
+		}
+	}
+
+	if len(updates.removed) > 0 {
+		removedIDs := make([]string, 0, len(updates.removed))
+		for _, removed := range updates.removed {
+			removedIDs = append(removedIDs, removed.GetTransactionId())
+		}
+
+		if _, err := tx.DeleteTransactionsByPlaidIDs(ctx, itemID, removedIDs); err != nil {
+			return fmt.Errorf("failed to remove transactions: %w", err)
+		}
+	}
+
+	if err := tx.UpdateItemTransactionsCursor(ctx, itemID, updates.cursor); err != nil {
+		return fmt.Errorf("failed to update cursor: %w", err)
+	}
+
+	return nil
+}
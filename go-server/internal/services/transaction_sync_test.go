@@ -0,0 +1,500 @@
+package services
+
+import (
+	"compound/go-server/internal/db"
+	"compound/go-server/internal/db/dbtest"
+	plaidpkg "compound/go-server/internal/plaid"
+	"compound/go-server/pkg/models"
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	plaid "github.com/plaid/plaid-go/v40/plaid"
+)
+
+// testSyncSample seeds every Fake item with five updates on one account: three added, one
+// modified and one removed, so a PageSize of 2 serves them in three pages
+const testSyncSample = `{
+  "added": [
+    {"account_id": "checking", "transaction_id": "tx-1", "name": "Coffee", "amount": 4.5, "date": "2025-01-02", "iso_currency_code": "USD"},
+    {"account_id": "checking", "transaction_id": "tx-2", "name": "Groceries", "amount": 52.1, "date": "2025-01-03", "iso_currency_code": "USD"},
+    {"account_id": "checking", "transaction_id": "tx-3", "name": "Rent", "amount": 1200, "date": "2025-01-04", "iso_currency_code": "USD"}
+  ],
+  "modified": [
+    {"account_id": "checking", "transaction_id": "tx-1", "name": "Coffee Shop", "amount": 4.75, "date": "2025-01-03", "iso_currency_code": "USD"}
+  ],
+  "removed": [
+    {"account_id": "checking", "transaction_id": "tx-2"}
+  ]
+}`
+
+// scriptedFake is a plaid.Fake that records the cursor of every SyncTransactions call and fails
+// the calls fail says to, numbered from 1
+type scriptedFake struct {
+	*plaidpkg.Fake
+
+	mu      sync.Mutex
+	cursors []string
+	fail    func(call int) error
+}
+
+func newScriptedFake(t *testing.T) *scriptedFake {
+	t.Helper()
+
+	fake := plaidpkg.NewFake()
+	fake.PageSize = 2
+	if err := fake.SeedFromSyncSample(strings.NewReader(testSyncSample)); err != nil {
+		t.Fatal(err)
+	}
+	return &scriptedFake{Fake: fake}
+}
+
+func (f *scriptedFake) SyncTransactions(ctx context.Context, accessToken string, cursor *string) (plaidpkg.SyncTransactionsResult, error) {
+	f.mu.Lock()
+	f.cursors = append(f.cursors, *cursor)
+	call := len(f.cursors)
+	f.mu.Unlock()
+
+	if f.fail != nil {
+		if err := f.fail(call); err != nil {
+			return plaidpkg.SyncTransactionsResult{}, err
+		}
+	}
+	return f.Fake.SyncTransactions(ctx, accessToken, cursor)
+}
+
+func (f *scriptedFake) calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string{}, f.cursors...)
+}
+
+var errMutation = plaidpkg.NewFakeError("TRANSACTIONS_ERROR", errMutationDuringPagination, "underlying transaction data changed")
+
+func TestFetchTransactionUpdatesPages(t *testing.T) {
+	ctx := context.Background()
+	fake := newScriptedFake(t)
+	accessToken, _, err := fake.ExchangePublicToken(ctx, "public-fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	syncer := &TransactionSyncer{aggregator: fake}
+	updates, err := syncer.fetchTransactionUpdates(ctx, accessToken, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if updates.pages != 3 || updates.cursor != "5" {
+		t.Fatalf("fetched %d pages ending at cursor %q, want 3 ending at 5", updates.pages, updates.cursor)
+	}
+	if len(updates.added) != 3 || len(updates.modified) != 1 || len(updates.removed) != 1 {
+		t.Fatalf("fetched %d added, %d modified, %d removed, want 3, 1, 1", len(updates.added), len(updates.modified), len(updates.removed))
+	}
+	if len(updates.accounts) != 1 {
+		t.Fatalf("fetched %d accounts, want 1", len(updates.accounts))
+	}
+	if got, want := fake.calls(), []string{"", "2", "4"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("requested cursors %q, want %q", got, want)
+	}
+}
+
+func TestFetchTransactionUpdatesRestartsAfterMutation(t *testing.T) {
+	ctx := context.Background()
+	fake := newScriptedFake(t)
+	accessToken, _, err := fake.ExchangePublicToken(ctx, "public-fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the second page of the first attempt finds the data changed
+	fake.fail = func(call int) error {
+		if call == 2 {
+			return errMutation
+		}
+		return nil
+	}
+
+	syncer := &TransactionSyncer{aggregator: fake}
+	cursor := "1"
+	updates, err := syncer.fetchTransactionUpdates(ctx, accessToken, &cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the restart goes back to the cursor the sync started from, and keeps none of the first attempt
+	if got, want := fake.calls(), []string{"1", "3", "1", "3"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("requested cursors %q, want %q", got, want)
+	}
+	if updates.pages != 2 || updates.cursor != "5" {
+		t.Fatalf("fetched %d pages ending at cursor %q, want 2 ending at 5", updates.pages, updates.cursor)
+	}
+	if len(updates.added) != 2 || len(updates.modified) != 1 || len(updates.removed) != 1 {
+		t.Fatalf("fetched %d added, %d modified, %d removed, want 2, 1, 1", len(updates.added), len(updates.modified), len(updates.removed))
+	}
+}
+
+func TestFetchTransactionUpdatesGivesUp(t *testing.T) {
+	ctx := context.Background()
+	fake := newScriptedFake(t)
+	accessToken, _, err := fake.ExchangePublicToken(ctx, "public-fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every attempt's second page finds the data changed
+	fake.fail = func(call int) error {
+		if call%2 == 0 {
+			return errMutation
+		}
+		return nil
+	}
+
+	syncer := &TransactionSyncer{aggregator: fake}
+	if _, err := syncer.fetchTransactionUpdates(ctx, accessToken, nil); plaidpkg.ErrorCode(err) != errMutationDuringPagination {
+		t.Fatalf("got %v, want %s", err, errMutationDuringPagination)
+	}
+
+	// the first attempt and maxSyncRestarts restarts, two calls each
+	if calls := len(fake.calls()); calls != 2*(maxSyncRestarts+1) {
+		t.Fatalf("made %d calls, want %d", calls, 2*(maxSyncRestarts+1))
+	}
+}
+
+func TestFetchTransactionUpdatesOtherErrorsDoNotRestart(t *testing.T) {
+	ctx := context.Background()
+	fake := newScriptedFake(t)
+	accessToken, _, err := fake.ExchangePublicToken(ctx, "public-fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake.fail = func(call int) error {
+		return plaidpkg.NewFakeError("ITEM_ERROR", "ITEM_LOGIN_REQUIRED", "the login details of this item have changed")
+	}
+
+	syncer := &TransactionSyncer{aggregator: fake}
+	if _, err := syncer.fetchTransactionUpdates(ctx, accessToken, nil); !plaidpkg.IsLoginRequired(err) {
+		t.Fatalf("got %v, want ITEM_LOGIN_REQUIRED", err)
+	}
+	if calls := len(fake.calls()); calls != 1 {
+		t.Fatalf("made %d calls, want 1", calls)
+	}
+}
+
+// syncTestItem links a Fake item for a new user and stores it, returning the item and the
+// Fake's copy of it
+func syncTestItem(t *testing.T, store *db.Store, fake *scriptedFake) (*models.Item, *plaidpkg.FakeItem) {
+	t.Helper()
+	ctx := context.Background()
+
+	user, err := store.CreateUser(ctx, "alice", "not-a-real-hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	accessToken, plaidItemID, err := fake.ExchangePublicToken(ctx, "public-fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	item, err := store.CreateItem(ctx, user.ID, accessToken, plaidItemID, plaidpkg.FakeInstitutionID, models.ItemStatusGood)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fakeItem, _ := fake.Item(accessToken)
+	return item, fakeItem
+}
+
+// storedTransactions returns the item's transactions keyed by Plaid transaction ID
+func storedTransactions(t *testing.T, store *db.Store, item *models.Item) map[string]*models.Transaction {
+	t.Helper()
+
+	filter := db.TransactionFilter{UserID: item.UserID, ItemIDs: []int{item.ID}}
+	transactions, err := store.GetTransactions(context.Background(), filter, db.TransactionSortDateDesc, nil, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byPlaidID := map[string]*models.Transaction{}
+	for _, tx := range transactions {
+		byPlaidID[tx.PlaidTransactionID] = tx
+	}
+	return byPlaidID
+}
+
+// itemCursor returns the item's stored transactions cursor, "" if it has none
+func itemCursor(t *testing.T, store *db.Store, itemID int) string {
+	t.Helper()
+
+	item, err := store.GetItemByID(context.Background(), itemID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.TransactionsCursor == nil {
+		return ""
+	}
+	return *item.TransactionsCursor
+}
+
+func TestSyncAppliesUpdates(t *testing.T) {
+	store := dbtest.Open(t)
+	ctx := context.Background()
+	fake := newScriptedFake(t)
+	item, fakeItem := syncTestItem(t, store, fake)
+	prefix := fakeItem.ItemID + "-"
+
+	syncer := NewTransactionSyncer(store, fake)
+	result, err := syncer.SyncTransactionsForItem(ctx, item.ID, models.SyncTriggerManual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.AddedCount != 3 || result.ModifiedCount != 1 || result.RemovedCount != 1 || result.Pages != 3 {
+		t.Fatalf("got %+v, want 3 added, 1 modified, 1 removed over 3 pages", result)
+	}
+
+	stored := storedTransactions(t, store, item)
+	if len(stored) != 2 || stored[prefix+"tx-2"] != nil {
+		t.Fatalf("stored %d transactions, want tx-1 and tx-3 with the removed tx-2 gone", len(stored))
+	}
+	if coffee := stored[prefix+"tx-1"]; coffee == nil || coffee.Name != "Coffee Shop" || coffee.Amount.String() != "4.75" {
+		t.Fatalf("modified tx-1 stored as %+v, want Coffee Shop for 4.75", coffee)
+	}
+	if cursor := itemCursor(t, store, item.ID); cursor != "5" {
+		t.Fatalf("cursor is %q, want 5", cursor)
+	}
+
+	// a later sync only sees what changed since, here tx-3 being removed
+	removed := plaid.NewRemovedTransactionWithDefaults()
+	removed.SetTransactionId(prefix + "tx-3")
+	removed.SetAccountId(prefix + "checking")
+	fakeItem.Removed = append(fakeItem.Removed, *removed)
+
+	result, err = syncer.SyncTransactionsForItem(ctx, item.ID, models.SyncTriggerManual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.AddedCount != 0 || result.ModifiedCount != 0 || result.RemovedCount != 1 {
+		t.Fatalf("got %+v, want only 1 removed", result)
+	}
+	stored = storedTransactions(t, store, item)
+	if len(stored) != 1 || stored[prefix+"tx-1"] == nil {
+		t.Fatalf("stored %d transactions, want only tx-1", len(stored))
+	}
+	if cursor := itemCursor(t, store, item.ID); cursor != "6" {
+		t.Fatalf("cursor is %q, want 6", cursor)
+	}
+}
+
+// TestSyncCursorOnlyAdvancesOnCommit fails a sync once in Plaid and once while storing the
+// updates, neither may store a transaction or move the cursor
+func TestSyncCursorOnlyAdvancesOnCommit(t *testing.T) {
+	store := dbtest.Open(t)
+	ctx := context.Background()
+	fake := newScriptedFake(t)
+	item, fakeItem := syncTestItem(t, store, fake)
+	syncer := NewTransactionSyncer(store, fake)
+
+	fake.fail = func(call int) error {
+		if call == 3 {
+			return plaidpkg.NewFakeError("API_ERROR", "INTERNAL_SERVER_ERROR", "an unexpected error occurred")
+		}
+		return nil
+	}
+	if _, err := syncer.SyncTransactionsForItem(ctx, item.ID, models.SyncTriggerManual); err == nil {
+		t.Fatal("sync succeeded with Plaid failing its last page")
+	}
+	if stored := storedTransactions(t, store, item); len(stored) != 0 {
+		t.Fatalf("stored %d transactions from a failed fetch", len(stored))
+	}
+	if cursor := itemCursor(t, store, item.ID); cursor != "" {
+		t.Fatalf("cursor moved to %q after a failed fetch", cursor)
+	}
+	fake.fail = nil
+
+	// a transaction on an account Plaid never returned fails the write after the first rows went in
+	orphan := fakeItem.Added[2]
+	orphan.SetAccountId(fakeItem.ItemID + "-unknown")
+	fakeItem.Added[2] = orphan
+	if _, err := syncer.SyncTransactionsForItem(ctx, item.ID, models.SyncTriggerManual); err == nil {
+		t.Fatal("sync succeeded storing a transaction on an unknown account")
+	}
+	if stored := storedTransactions(t, store, item); len(stored) != 0 {
+		t.Fatalf("stored %d transactions from a rolled back sync", len(stored))
+	}
+	if cursor := itemCursor(t, store, item.ID); cursor != "" {
+		t.Fatalf("cursor moved to %q after a rolled back sync", cursor)
+	}
+
+	runs, err := store.GetSyncRunsByItemID(ctx, item.ID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, run := range runs {
+		if run.ErrorMessage == nil {
+			t.Fatalf("sync run %d has no error recorded", run.ID)
+		}
+	}
+	if len(runs) != 2 {
+		t.Fatalf("recorded %d sync runs, want 2", len(runs))
+	}
+}
+
+// backgroundCall is one background sync started by a blockingSyncer
+type backgroundCall struct {
+	itemID  int
+	trigger string
+}
+
+// blockingSyncer returns a syncer running up to limit background syncs at once, whose syncs
+// report on started, then block until release is sent to or closed, or the syncer is closed
+func blockingSyncer(limit int) (*TransactionSyncer, chan backgroundCall, chan struct{}) {
+	started := make(chan backgroundCall, 100)
+	release := make(chan struct{})
+
+	syncer := NewTransactionSyncer(nil, nil)
+	syncer.maxBackground = limit
+	syncer.syncItem = func(ctx context.Context, itemID int, trigger string) (*SyncResult, error) {
+		started <- backgroundCall{itemID, trigger}
+		select {
+		case <-release:
+			return &SyncResult{}, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return syncer, started, release
+}
+
+func TestSyncInBackgroundCoalesces(t *testing.T) {
+	syncer, started, release := blockingSyncer(2)
+
+	syncer.SyncInBackground(1, models.SyncTriggerWebhook)
+	if call := <-started; call != (backgroundCall{1, models.SyncTriggerWebhook}) {
+		t.Fatalf("started %+v, want item 1's webhook sync", call)
+	}
+
+	// a burst of webhooks while item 1 syncs, and one for another item, which doesn't wait
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			syncer.SyncInBackground(1, models.SyncTriggerWebhook)
+		}()
+	}
+	wg.Wait()
+	syncer.SyncInBackground(1, models.SyncTriggerRelink)
+	syncer.SyncInBackground(2, models.SyncTriggerWebhook)
+	if call := <-started; call != (backgroundCall{2, models.SyncTriggerWebhook}) {
+		t.Fatalf("started %+v, want item 2's webhook sync", call)
+	}
+
+	// item 1 syncs once more for the whole burst, as the latest request
+	release <- struct{}{}
+	if call := <-started; call != (backgroundCall{1, models.SyncTriggerRelink}) {
+		t.Fatalf("started %+v, want item 1's relink sync", call)
+	}
+
+	close(release)
+	syncer.Close()
+	if len(started) != 0 {
+		t.Fatalf("%d more syncs ran, want none", len(started))
+	}
+}
+
+func TestTransactionSyncerClose(t *testing.T) {
+	syncer, started, _ := blockingSyncer(2)
+	var finished atomic.Bool
+	blocking := syncer.syncItem
+	syncer.syncItem = func(ctx context.Context, itemID int, trigger string) (*SyncResult, error) {
+		defer finished.Store(true)
+		result, err := blocking(ctx, itemID, trigger)
+		// still holding the item's connections for a moment after being cancelled
+		time.Sleep(10 * time.Millisecond)
+		return result, err
+	}
+
+	syncer.SyncInBackground(1, models.SyncTriggerWebhook)
+	<-started
+	syncer.SyncInBackground(1, models.SyncTriggerWebhook)
+
+	syncer.Close()
+	if !finished.Load() {
+		t.Fatalf("Close returned before the background sync did")
+	}
+
+	// neither the queued sync nor a new one runs once closed
+	syncer.SyncInBackground(2, models.SyncTriggerWebhook)
+	syncer.Close()
+	if len(started) != 0 {
+		t.Fatalf("%d syncs ran after Close, want none", len(started))
+	}
+}
+
+func TestSyncInBackgroundLimitsConcurrency(t *testing.T) {
+	const items, limit = 12, 3
+	syncer, started, release := blockingSyncer(limit)
+
+	var running, peak atomic.Int32
+	blocking := syncer.syncItem
+	syncer.syncItem = func(ctx context.Context, itemID int, trigger string) (*SyncResult, error) {
+		now := running.Add(1)
+		defer running.Add(-1)
+		for {
+			highest := peak.Load()
+			if now <= highest || peak.CompareAndSwap(highest, now) {
+				break
+			}
+		}
+		return blocking(ctx, itemID, trigger)
+	}
+
+	for itemID := 1; itemID <= items; itemID++ {
+		syncer.SyncInBackground(itemID, models.SyncTriggerWebhook)
+	}
+
+	// only limit syncs start, the rest wait for a slot rather than a goroutine each
+	for range limit {
+		<-started
+	}
+	select {
+	case call := <-started:
+		t.Fatalf("started %+v beyond the limit of %d", call, limit)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// releasing one sync at a time lets exactly one queued item start, oldest first
+	for next := limit + 1; next <= items; next++ {
+		release <- struct{}{}
+		if call := <-started; call.itemID != next {
+			t.Fatalf("started item %d, want queued item %d", call.itemID, next)
+		}
+	}
+	for range limit {
+		release <- struct{}{}
+	}
+	syncer.Close()
+
+	if got := peak.Load(); got > limit {
+		t.Fatalf("%d syncs ran at once, want at most %d", got, limit)
+	}
+	if len(started) != 0 {
+		t.Fatalf("%d more syncs ran, want none", len(started))
+	}
+}
+
+func TestSyncInBackgroundDropsQueueOnClose(t *testing.T) {
+	syncer, started, _ := blockingSyncer(1)
+
+	syncer.SyncInBackground(1, models.SyncTriggerWebhook)
+	<-started
+	syncer.SyncInBackground(2, models.SyncTriggerWebhook)
+	syncer.SyncInBackground(3, models.SyncTriggerWebhook)
+
+	syncer.Close()
+	if len(started) != 0 {
+		t.Fatalf("%d queued syncs ran after Close, want none", len(started))
+	}
+}
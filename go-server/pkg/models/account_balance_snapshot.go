@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// AccountBalanceSnapshot is an account's balances at the end of one day
+type AccountBalanceSnapshot struct {
+	ID                     int       `db:"id" json:"id"`
+	AccountID              int       `db:"account_id" json:"account_id"`
+	Date                   time.Time `db:"date" json:"date"`
+	CurrentBalance         *Money    `db:"current_balance" json:"current_balance"`
+	AvailableBalance       *Money    `db:"available_balance" json:"available_balance"`
+	IsoCurrencyCode        *string   `db:"iso_currency_code" json:"iso_currency_code"`
+	UnofficialCurrencyCode *string   `db:"unofficial_currency_code" json:"unofficial_currency_code"`
+	CreatedAt              time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt              time.Time `db:"updated_at" json:"updated_at"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Asset is something the user owns that no linked account tracks, e.g. a house or a car
+// Values are in the server's net worth currency
+type Asset struct {
+	ID          int       `db:"id" json:"id"`
+	UserID      int       `db:"user_id" json:"user_id"`
+	Value       Money     `db:"value" json:"value"`
+	Description *string   `db:"description" json:"description"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt   time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// AssetValue is a manual asset's value as of one day
+type AssetValue struct {
+	ID        int       `db:"id" json:"id"`
+	AssetID   int       `db:"asset_id" json:"asset_id"`
+	Date      time.Time `db:"date" json:"date"`
+	Value     Money     `db:"value" json:"value"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
@@ -2,14 +2,28 @@ package models
 
 import "time"
 
+// Item statuses stored in items_table.status
+const (
+	ItemStatusLinked            = "linked"
+	ItemStatusGood              = "good"
+	ItemStatusBad               = "bad"
+	ItemStatusLoginRequired     = "login_required"
+	ItemStatusPendingExpiration = "pending_expiration"
+	ItemStatusPendingDisconnect = "pending_disconnect"
+	ItemStatusRevoked           = "revoked"
+)
+
 type Item struct {
-	ID                 int       `db:"id" json:"id"`
-	UserID             int       `db:"user_id" json:"user_id"`
-	PlaidAccessToken   string    `db:"plaid_access_token" json:"plaid_access_token"`
-	PlaidItemID        string    `db:"plaid_item_id" json:"plaid_item_id"`
-	PlaidInstitutionID string    `db:"plaid_institution_id" json:"plaid_institution_id"`
-	Status             string    `db:"status" json:"status"`
-	CreatedAt          time.Time `db:"created_at" json:"created_at"`
-	UpdatedAt          time.Time `db:"updated_at" json:"updated_at"`
-	TransactionsCursor *string   `db:"transactions_cursor" json:"transactions_cursor"`
+	ID                 int        `db:"id" json:"id"`
+	UserID             int        `db:"user_id" json:"user_id"`
+	PlaidAccessToken   string     `db:"plaid_access_token" json:"-"` // never serialized, see handlers.ItemResponse
+	PlaidItemID        string     `db:"plaid_item_id" json:"plaid_item_id"`
+	PlaidInstitutionID string     `db:"plaid_institution_id" json:"plaid_institution_id"`
+	Status             string     `db:"status" json:"status"`
+	CreatedAt          time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt          time.Time  `db:"updated_at" json:"updated_at"`
+	TransactionsCursor *string    `db:"transactions_cursor" json:"transactions_cursor"`
+	LastSyncedAt       *time.Time `db:"last_synced_at" json:"last_synced_at"`
+	// NewAccountsAvailable is set when the user can share more accounts through account selection update mode
+	NewAccountsAvailable bool `db:"new_accounts_available" json:"new_accounts_available"`
 }
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// ItemRemoval is the audit record of an item a user removed
+// PlaidAlreadyRemoved is set when Plaid no longer knew the item, e.g. a retried removal.
+// PlaidRemovedAt is nil while the item, already deleted here, still has to be removed from Plaid;
+// PlaidAccessToken is only set until then. ItemID is 0 for an item that was exchanged but never
+// stored, e.g. one linked under a different institution than the client claimed.
+type ItemRemoval struct {
+	ID                  int        `db:"id" json:"id"`
+	ItemID              int        `db:"item_id" json:"item_id"`
+	UserID              int        `db:"user_id" json:"user_id"`
+	PlaidItemID         string     `db:"plaid_item_id" json:"plaid_item_id"`
+	PlaidInstitutionID  *string    `db:"plaid_institution_id" json:"plaid_institution_id"`
+	PlaidAccessToken    string     `db:"plaid_access_token" json:"-"` // never serialized
+	PlaidAlreadyRemoved bool       `db:"plaid_already_removed" json:"plaid_already_removed"`
+	RemovedAt           time.Time  `db:"removed_at" json:"removed_at"`
+	PlaidRemovedAt      *time.Time `db:"plaid_removed_at" json:"plaid_removed_at"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+type LinkEvent struct {
+	ID            int       `db:"id" json:"id"`
+	Type          string    `db:"type" json:"type"`
+	UserID        *int      `db:"user_id" json:"user_id"`
+	LinkSessionID *string   `db:"link_session_id" json:"link_session_id"`
+	RequestID     *string   `db:"request_id" json:"request_id"`
+	ErrorType     *string   `db:"error_type" json:"error_type"`
+	ErrorCode     *string   `db:"error_code" json:"error_code"`
+	Status        *string   `db:"status" json:"status"`
+	CreatedAt     time.Time `db:"created_at" json:"created_at"`
+}
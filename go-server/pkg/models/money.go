@@ -0,0 +1,225 @@
+package models
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// MoneyScale is the number of decimal places Money keeps, the scale of the numeric(28,10) amount columns
+const MoneyScale = 10
+
+// Money is an exact decimal amount with MoneyScale decimal places
+// Amounts are numeric in Postgres and summing them as float64 drifts by cents, so every amount
+// that's stored, summed or compared is a Money. It scans from and encodes to numeric without
+// loss and marshals to JSON as a number with its exact decimal digits. The zero value is 0.
+// Money is immutable, arithmetic returns a new value.
+type Money struct {
+	// units is the amount times 10^MoneyScale, nil for 0
+	units *big.Int
+}
+
+var moneyScaleFactor = new(big.Int).Exp(big.NewInt(10), big.NewInt(MoneyScale), nil)
+
+// ParseMoney parses a plain decimal such as "-12.34", rounding it to MoneyScale places
+// Exponents aren't accepted, "1e999999999" would take big.Rat minutes to expand
+func ParseMoney(s string) (Money, error) {
+	if !isDecimal(s) {
+		return Money{}, fmt.Errorf("invalid amount %q", s)
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return Money{}, fmt.Errorf("invalid amount %q", s)
+	}
+	return moneyFromRat(r), nil
+}
+
+// isDecimal reports whether s is an optionally signed run of digits with at most one decimal point
+func isDecimal(s string) bool {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" {
+		return false
+	}
+	for _, r := range whole + frac {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// MustParseMoney is ParseMoney for constants, it panics if s isn't a decimal
+func MustParseMoney(s string) Money {
+	m, err := ParseMoney(s)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// MoneyFromFloat converts an amount the Plaid API sent as a float64
+// The float's shortest decimal representation is used, so 12.34 becomes exactly 12.34.
+// NaN and infinities become 0.
+func MoneyFromFloat(f float64) Money {
+	m, err := ParseMoney(strconv.FormatFloat(f, 'f', -1, 64))
+	if err != nil {
+		return Money{}
+	}
+	return m
+}
+
+// MoneyFromFloatPtr converts an optional amount the Plaid API sent as a float64, nil stays nil
+func MoneyFromFloatPtr(f *float64) *Money {
+	if f == nil {
+		return nil
+	}
+	m := MoneyFromFloat(*f)
+	return &m
+}
+
+// moneyFromRat rounds r to MoneyScale places, halves away from zero
+func moneyFromRat(r *big.Rat) Money {
+	scaled := new(big.Rat).Mul(r, new(big.Rat).SetInt(moneyScaleFactor))
+	num, den := scaled.Num(), scaled.Denom()
+
+	units, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if rem.Sign() != 0 {
+		twice := new(big.Int).Abs(rem)
+		twice.Lsh(twice, 1)
+		if twice.Cmp(den) >= 0 {
+			units.Add(units, big.NewInt(int64(num.Sign())))
+		}
+	}
+	return Money{units: units}
+}
+
+func (m Money) int() *big.Int {
+	if m.units == nil {
+		return new(big.Int)
+	}
+	return m.units
+}
+
+// Add returns m + o
+func (m Money) Add(o Money) Money {
+	return Money{units: new(big.Int).Add(m.int(), o.int())}
+}
+
+// Sub returns m - o
+func (m Money) Sub(o Money) Money {
+	return Money{units: new(big.Int).Sub(m.int(), o.int())}
+}
+
+// Neg returns -m
+func (m Money) Neg() Money {
+	return Money{units: new(big.Int).Neg(m.int())}
+}
+
+// Mul returns m * o rounded to MoneyScale places, e.g. to apply an exchange rate
+func (m Money) Mul(o Money) Money {
+	scale := new(big.Int).Mul(moneyScaleFactor, moneyScaleFactor)
+	return moneyFromRat(new(big.Rat).SetFrac(new(big.Int).Mul(m.int(), o.int()), scale))
+}
+
+// Cmp returns -1, 0 or +1 as m is less than, equal to or greater than o
+func (m Money) Cmp(o Money) int {
+	return m.int().Cmp(o.int())
+}
+
+// Sign returns -1, 0 or +1 as m is negative, zero or positive
+func (m Money) Sign() int {
+	return m.int().Sign()
+}
+
+// Places returns the number of decimal places m needs, from 0 for whole amounts to MoneyScale
+func (m Money) Places() int {
+	if m.Sign() == 0 {
+		return 0
+	}
+
+	units := new(big.Int).Set(m.int())
+	rem := new(big.Int)
+	ten := big.NewInt(10)
+	places := MoneyScale
+	for places > 0 {
+		if units.QuoRem(units, ten, rem); rem.Sign() != 0 {
+			break
+		}
+		places--
+	}
+	return places
+}
+
+// String formats m with at least two decimal places and no trailing zeros past them, e.g. "-12.30"
+func (m Money) String() string {
+	digits := new(big.Int).Abs(m.int()).String()
+	if len(digits) <= MoneyScale {
+		digits = strings.Repeat("0", MoneyScale-len(digits)+1) + digits
+	}
+
+	whole := digits[:len(digits)-MoneyScale]
+	frac := strings.TrimRight(digits[len(digits)-MoneyScale:], "0")
+	for len(frac) < 2 {
+		frac += "0"
+	}
+
+	if m.Sign() < 0 {
+		return "-" + whole + "." + frac
+	}
+	return whole + "." + frac
+}
+
+// MarshalJSON writes m as a JSON number with its exact decimal digits
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON reads a JSON number or a string holding one
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	s := string(data)
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+
+	parsed, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// ScanNumeric implements pgtype.NumericScanner, rounding values with more than MoneyScale places
+// Scan nullable columns into a *Money
+func (m *Money) ScanNumeric(n pgtype.Numeric) error {
+	if !n.Valid {
+		return fmt.Errorf("cannot scan NULL into Money")
+	}
+	if n.NaN || n.InfinityModifier != pgtype.Finite {
+		return fmt.Errorf("cannot scan NaN or infinity into Money")
+	}
+
+	r := new(big.Rat).SetInt(n.Int)
+	exp := big.NewInt(int64(n.Exp))
+	pow := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), exp.Abs(exp), nil))
+	if n.Exp < 0 {
+		r.Quo(r, pow)
+	} else {
+		r.Mul(r, pow)
+	}
+	*m = moneyFromRat(r)
+	return nil
+}
+
+// NumericValue implements pgtype.NumericValuer
+func (m Money) NumericValue() (pgtype.Numeric, error) {
+	return pgtype.Numeric{Int: new(big.Int).Set(m.int()), Exp: -MoneyScale, Valid: true}, nil
+}
@@ -0,0 +1,223 @@
+package models
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"0", "0.00"},
+		{"12", "12.00"},
+		{"12.3", "12.30"},
+		{"12.345", "12.345"},
+		{"+12.34", "12.34"},
+		{"-12.34", "-12.34"},
+		{"-0.005", "-0.005"},
+		{".5", "0.50"},
+		{"5.", "5.00"},
+		{"-0", "0.00"},
+		{"0.00000000004", "0.00"},
+		{"-0.00000000004", "0.00"},
+		{"0.00000000005", "0.0000000001"},
+		{"-0.00000000005", "-0.0000000001"},
+		{"1.23456789015", "1.2345678902"},
+		{"-1.23456789014", "-1.2345678901"},
+		{"99999999999999999999999999999999.99", "99999999999999999999999999999999.99"},
+		{"-123456789012345678901234567890.0123456789", "-123456789012345678901234567890.0123456789"},
+	}
+
+	for _, tt := range tests {
+		m, err := ParseMoney(tt.in)
+		if err != nil {
+			t.Errorf("ParseMoney(%q): %v", tt.in, err)
+			continue
+		}
+		if got := m.String(); got != tt.want {
+			t.Errorf("ParseMoney(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseMoneyInvalid(t *testing.T) {
+	for _, in := range []string{"", ".", "-", "abc", "1e5", "1E-2", "1.2.3", "1,000", " 1", "1 ", "--1", "-+1", "0x10", "NaN", "Inf", "1/2"} {
+		if m, err := ParseMoney(in); err == nil {
+			t.Errorf("ParseMoney(%q) = %s, want an error", in, m)
+		}
+	}
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	a, b := MustParseMoney("10.10"), MustParseMoney("-0.2")
+
+	if got := a.Add(b).String(); got != "9.90" {
+		t.Errorf("Add = %s, want 9.90", got)
+	}
+	if got := a.Sub(b).String(); got != "10.30" {
+		t.Errorf("Sub = %s, want 10.30", got)
+	}
+	if got := b.Neg().String(); got != "0.20" {
+		t.Errorf("Neg = %s, want 0.20", got)
+	}
+	if got := a.Mul(b).String(); got != "-2.02" {
+		t.Errorf("Mul = %s, want -2.02", got)
+	}
+	// 1 / 3 at ten places, times 2, rounds the twenty-place product back to ten
+	if got := MustParseMoney("0.3333333333").Mul(MustParseMoney("2")).String(); got != "0.6666666666" {
+		t.Errorf("Mul = %s, want 0.6666666666", got)
+	}
+	if got := MustParseMoney("0.0000000001").Mul(MustParseMoney("0.5")).String(); got != "0.0000000001" {
+		t.Errorf("Mul = %s, want 0.0000000001 (half rounds away from zero)", got)
+	}
+	if a.Cmp(b) != 1 || b.Cmp(a) != -1 || a.Cmp(MustParseMoney("10.1")) != 0 {
+		t.Errorf("Cmp is inconsistent")
+	}
+	if (Money{}).Sign() != 0 || a.Sign() != 1 || b.Sign() != -1 {
+		t.Errorf("Sign is inconsistent")
+	}
+
+	// float sums drift, Money sums don't
+	var sum Money
+	for range 10 {
+		sum = sum.Add(MoneyFromFloat(0.1))
+	}
+	if sum.Cmp(MustParseMoney("1")) != 0 {
+		t.Errorf("ten 0.1s sum to %s, want 1.00", sum)
+	}
+}
+
+func TestMoneyPlaces(t *testing.T) {
+	tests := map[string]int{
+		"0":             0,
+		"12":            0,
+		"-100":          0,
+		"12.3":          1,
+		"12.30":         1,
+		"12.34":         2,
+		"-0.005":        3,
+		"-0.0000000001": 10,
+	}
+	for in, want := range tests {
+		if got := MustParseMoney(in).Places(); got != want {
+			t.Errorf("%s has %d places, want %d", in, got, want)
+		}
+	}
+}
+
+func TestMoneyFromFloat(t *testing.T) {
+	tests := []struct {
+		in   float64
+		want string
+	}{
+		{12.34, "12.34"},
+		{-0.1, "-0.10"},
+		{1e20, "100000000000000000000.00"},
+		{1e-11, "0.00"},
+	}
+	for _, tt := range tests {
+		if got := MoneyFromFloat(tt.in).String(); got != tt.want {
+			t.Errorf("MoneyFromFloat(%v) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+
+	if MoneyFromFloatPtr(nil) != nil {
+		t.Errorf("MoneyFromFloatPtr(nil) isn't nil")
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`12.34`, `12.34`},
+		{`"12.34"`, `12.34`},
+		{`-0.005`, `-0.005`},
+		{`"-0.005"`, `-0.005`},
+		{`12`, `12.00`},
+		{`123456789012345678901234567890.12`, `123456789012345678901234567890.12`},
+	}
+
+	for _, tt := range tests {
+		var m Money
+		if err := json.Unmarshal([]byte(tt.in), &m); err != nil {
+			t.Errorf("Unmarshal(%s): %v", tt.in, err)
+			continue
+		}
+		out, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != tt.want {
+			t.Errorf("Unmarshal(%s) then Marshal = %s, want %s", tt.in, out, tt.want)
+		}
+	}
+
+	for _, in := range []string{`"abc"`, `1e5`, `""`, `true`, `{}`} {
+		var m Money
+		if err := json.Unmarshal([]byte(in), &m); err == nil {
+			t.Errorf("Unmarshal(%s) = %s, want an error", in, m)
+		}
+	}
+
+	var optional struct {
+		Value *Money `json:"value"`
+	}
+	if err := json.Unmarshal([]byte(`{"value": null}`), &optional); err != nil || optional.Value != nil {
+		t.Errorf("Unmarshal null = %v, %v, want nil", optional.Value, err)
+	}
+}
+
+func TestMoneyNumeric(t *testing.T) {
+	tests := []struct {
+		in   pgtype.Numeric
+		want string
+	}{
+		{pgtype.Numeric{Int: big.NewInt(1234), Exp: -2, Valid: true}, "12.34"},
+		{pgtype.Numeric{Int: big.NewInt(-5), Exp: -3, Valid: true}, "-0.005"},
+		{pgtype.Numeric{Int: big.NewInt(12), Exp: 3, Valid: true}, "12000.00"},
+		{pgtype.Numeric{Int: big.NewInt(0), Exp: 5, Valid: true}, "0.00"},
+		{pgtype.Numeric{Int: big.NewInt(15), Exp: -11, Valid: true}, "0.0000000002"},
+		{pgtype.Numeric{Int: big.NewInt(-15), Exp: -11, Valid: true}, "-0.0000000002"},
+	}
+
+	for _, tt := range tests {
+		var m Money
+		if err := m.ScanNumeric(tt.in); err != nil {
+			t.Errorf("ScanNumeric(%v e%d): %v", tt.in.Int, tt.in.Exp, err)
+			continue
+		}
+		if got := m.String(); got != tt.want {
+			t.Errorf("ScanNumeric(%v e%d) = %s, want %s", tt.in.Int, tt.in.Exp, got, tt.want)
+		}
+
+		n, err := m.NumericValue()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var back Money
+		if err := back.ScanNumeric(n); err != nil {
+			t.Fatal(err)
+		}
+		if back.Cmp(m) != 0 {
+			t.Errorf("%s round-trips through numeric as %s", m, back)
+		}
+	}
+
+	for _, in := range []pgtype.Numeric{
+		{},
+		{NaN: true, Valid: true},
+		{InfinityModifier: pgtype.Infinity, Valid: true},
+	} {
+		var m Money
+		if err := m.ScanNumeric(in); err == nil {
+			t.Errorf("ScanNumeric(%+v) = %s, want an error", in, m)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+type PlaidAPIEvent struct {
+	ID           int       `db:"id" json:"id"`
+	ItemID       *int      `db:"item_id" json:"item_id"`
+	UserID       *int      `db:"user_id" json:"user_id"`
+	PlaidMethod  string    `db:"plaid_method" json:"plaid_method"`
+	Arguments    *string   `db:"arguments" json:"arguments"`
+	RequestID    *string   `db:"request_id" json:"request_id"`
+	ErrorType    *string   `db:"error_type" json:"error_type"`
+	ErrorCode    *string   `db:"error_code" json:"error_code"`
+	ErrorMessage *string   `db:"error_message" json:"error_message"`
+	DurationMs   *int      `db:"duration_ms" json:"duration_ms"`
+	CreatedAt    time.Time `db:"created_at" json:"created_at"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Sync run triggers stored in sync_runs_table.trigger
+const (
+	SyncTriggerManual    = "manual"
+	SyncTriggerWebhook   = "webhook"
+	SyncTriggerScheduler = "scheduler"
+	SyncTriggerRelink    = "relink"
+)
+
+type SyncRun struct {
+	ID            int        `db:"id" json:"id"`
+	ItemID        int        `db:"item_id" json:"item_id"`
+	Trigger       string     `db:"trigger" json:"trigger"`
+	StartedAt     time.Time  `db:"started_at" json:"started_at"`
+	FinishedAt    *time.Time `db:"finished_at" json:"finished_at"`
+	Pages         int        `db:"pages" json:"pages"`
+	AddedCount    int        `db:"added_count" json:"added_count"`
+	ModifiedCount int        `db:"modified_count" json:"modified_count"`
+	RemovedCount  int        `db:"removed_count" json:"removed_count"`
+	CursorBefore  *string    `db:"cursor_before" json:"cursor_before"`
+	CursorAfter   *string    `db:"cursor_after" json:"cursor_after"`
+	ErrorCode     *string    `db:"error_code" json:"error_code"`
+	ErrorMessage  *string    `db:"error_message" json:"error_message"`
+}
@@ -10,7 +10,9 @@ type Transaction struct {
 	Category               *string   `db:"category" json:"category"`
 	Type                   string    `db:"type" json:"type"`
 	Name                   string    `db:"name" json:"name"`
-	Amount                 float64   `db:"amount" json:"amount"`
+	MerchantName           *string   `db:"merchant_name" json:"merchant_name"`
+	Notes                  *string   `db:"notes" json:"notes"`
+	Amount                 Money     `db:"amount" json:"amount"`
 	IsoCurrencyCode        *string   `db:"iso_currency_code" json:"iso_currency_code"`
 	UnofficialCurrencyCode *string   `db:"unofficial_currency_code" json:"unofficial_currency_code"`
 	Date                   time.Time `db:"date" json:"date"`
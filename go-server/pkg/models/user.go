@@ -3,8 +3,10 @@ package models
 import "time"
 
 type User struct {
-	ID        int       `db:"id" json:"id"`
-	Username  string    `db:"username" json:"username"`
-	CreatedAt time.Time `db:"created_at" json:"created_at"`
-	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+	ID           int       `db:"id" json:"id"`
+	Username     string    `db:"username" json:"username"`
+	CreatedAt    time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt    time.Time `db:"updated_at" json:"updated_at"`
+	PasswordHash *string   `db:"password_hash" json:"-"` // bcrypt hash, nil for users without a password
+	IsAdmin      bool      `db:"is_admin" json:"is_admin"`
 }